@@ -4,6 +4,8 @@ package main_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -54,3 +56,97 @@ func TestCLI_Smoke_RealCustomersCSV(t *testing.T) {
 		}
 	}
 }
+
+func TestCLI_ExitCodesAndSummaryJSON(t *testing.T) {
+	tmp := t.TempDir()
+	// go run reports every failure as exit status 1, so run a built binary.
+	bin := filepath.Join(tmp, "edc")
+	if out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, out)
+	}
+	in := filepath.Join(tmp, "in.csv")
+	if err := os.WriteFile(in, []byte("name,email\nA,a@x.com\nB\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		status   string
+	}{
+		{"Success", []string{"-path", in}, 0, "ok"},
+		{"Usage", []string{"-path", in, "-strict", "-tolerant"}, 2, "usage_error"},
+		{"Missing_input", []string{"-path", filepath.Join(tmp, "missing.csv")}, 3, "input_error"},
+		{"Missing_header", []string{"-path", in, "-email-header", "mail"}, 4, "header_missing"},
+		{"Bad_rows", []string{"-path", in, "-max-bad-rows", "0"}, 5, "bad_rows_exceeded"},
+		{"Output", []string{"-path", in, "-out", tmp}, 6, "output_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summaryPath := filepath.Join(tmp, tt.name+".json")
+			cmd := exec.Command(bin, append(tt.args, "-summary-json", summaryPath)...)
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			if code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d\nstderr:\n%s", code, tt.wantCode, stderr.String())
+			}
+
+			b, err := os.ReadFile(summaryPath)
+			if err != nil {
+				t.Fatalf("summary not written: %v", err)
+			}
+			var sum struct {
+				Status   string `json:"status"`
+				ExitCode int    `json:"exit_code"`
+			}
+			if err := json.Unmarshal(b, &sum); err != nil {
+				t.Fatalf("summary is not JSON: %v\n%s", err, b)
+			}
+			if sum.Status != tt.status || sum.ExitCode != tt.wantCode {
+				t.Fatalf("summary status=%q exit_code=%d, want %q %d", sum.Status, sum.ExitCode, tt.status, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCLI_ConfigPrecedence(t *testing.T) {
+	tmp := t.TempDir()
+	cfgPath := filepath.Join(tmp, "job.toml")
+	cfg := "email_header = \"mail\"\nmax-bad-rows = 3\nencoding = \"latin1\"\nmbox-headers = [\"From\", \"To\"]\n"
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("go", "run", ".", "config", "print", "-config", cfgPath, "-encoding", "utf-8")
+	cmd.Env = append(os.Environ(), "EDC_MAX_BAD_ROWS=7", "EDC_EMAIL_COLUMN=2")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("config print failed: %v\nstderr:\n%s", err, stderr.String())
+	}
+
+	wantLines := []string{
+		"email-header: mail # config",
+		"max-bad-rows: 7 # env",
+		"email-column: 2 # env",
+		"encoding: utf-8 # flag",
+		"mbox-headers: From,To # config",
+		"out: \"\" # default",
+	}
+	for _, line := range wantLines {
+		if !bytes.Contains(stdout.Bytes(), []byte(line+"\n")) {
+			t.Errorf("expected %q in output:\n%s", line, stdout.String())
+		}
+	}
+}
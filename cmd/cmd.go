@@ -0,0 +1,207 @@
+// Package cmd implements the email-domain-counter command line: a set of
+// subcommands sharing the input, logging and configuration flags.
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Exit codes. Scripts can tell a rejected invocation from a bad input file or
+// a failed write without parsing the log.
+const (
+	exitOK          = 0
+	exitFatal       = 1   // unexpected failure
+	exitUsage       = 2   // invalid flags or flag combinations, as flag.Parse uses
+	exitInput       = 3   // input missing, unreadable or malformed
+	exitHeader      = 4   // email header or column not found
+	exitBadRows     = 5   // -max-bad-rows or -max-bad-ratio exceeded
+	exitOutput      = 6   // results or summary could not be written
+	exitInterrupted = 130 // 128 + SIGINT, as shells report it
+)
+
+// env holds what a command writes to. Logs go through slog, which setupLogging
+// points at stderr.
+type env struct {
+	prog   string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// A command registers its flags in setup and gets back the function that runs
+// it once the flags, environment and config file have been applied.
+type command struct {
+	name     string
+	synopsis string
+	summary  string
+	// help follows the flag list in "<command> -h": notes and examples.
+	help  string
+	setup func(fs *flag.FlagSet) func(e *env, args []string) int
+}
+
+// commands lists the subcommands in help order. It is a function so commands
+// can refer to the list (config print, knownOption) without an init cycle.
+func commands() []*command {
+	return []*command{countCommand, watchCommand, serveCommand, validateCommand, diffCommand, mergeCommand, statsCommand, versionCommand}
+}
+
+func lookupCommand(name string) *command {
+	for _, c := range commands() {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Main runs the command line with the process arguments and standard streams
+// and returns the exit code.
+func Main() int {
+	return Run(os.Args[1:], os.Stdout, os.Stderr)
+}
+
+// Run dispatches args to a subcommand. Arguments that start with a flag are
+// the original flag-only invocation and run count.
+func Run(args []string, stdout, stderr io.Writer) int {
+	e := &env{prog: filepath.Base(os.Args[0]), stdout: stdout, stderr: stderr}
+	// Errors found before a command has read its logging flags go to stderr
+	// at the default level.
+	setupLogging(stderr, "info", "text", false)
+
+	if len(args) == 0 {
+		return e.run(countCommand, args)
+	}
+	switch name := args[0]; name {
+	case "-h", "-help", "--help", "help":
+		if len(args) > 1 && name == "help" {
+			if c := lookupCommand(args[1]); c != nil {
+				return e.run(c, []string{"-h"})
+			}
+		}
+		e.usage()
+		return exitOK
+	case "config":
+		return e.config(args[1:])
+	default:
+		if strings.HasPrefix(name, "-") {
+			return e.run(countCommand, args)
+		}
+		if c := lookupCommand(name); c != nil {
+			return e.run(c, args[1:])
+		}
+		fmt.Fprintf(e.stderr, "unknown command %q\n\n", name)
+		e.usage()
+		return exitUsage
+	}
+}
+
+func (e *env) usage() {
+	w := e.stderr
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n", e.prog)
+	fmt.Fprintf(w, "       %s [count flags]\n\n", e.prog)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-9s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "  %-9s %s\n", "config", "Print the effective configuration: config print [command] [flags]")
+	fmt.Fprintf(w, `
+Run "%s <command> -h" for the flags of a command. Without a command, the
+flags are passed to count.
+
+Configuration:
+Options not given as flags are read from EDC_<FLAG> environment variables
+(EDC_EMAIL_HEADER for -email-header), then from the -config file, whose keys
+are flag names. "config print" shows the effective values and where each one
+came from.
+
+Exit codes:
+0 success, 1 unexpected failure, 2 usage error, 3 unreadable input,
+4 email header/column missing, 5 bad-row threshold exceeded,
+6 output failure, 130 interrupted
+`, e.prog)
+}
+
+// newFlagSet builds the flag set of c, including the -config flag every
+// command shares. The returned pointer receives the -config value.
+func (e *env) newFlagSet(c *command) (*flag.FlagSet, *string, func(*env, []string) int) {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	configPath := fs.String("config", "", "Optional: YAML, TOML or JSON file with option defaults (env EDC_CONFIG)")
+	runner := c.setup(fs)
+	fs.Usage = func() {
+		w := fs.Output()
+		synopsis := strings.TrimSpace(fmt.Sprintf("%s %s %s", e.prog, c.name, c.synopsis))
+		fmt.Fprintf(w, "Usage: %s\n\n%s.\n\nFlags:\n", synopsis, c.summary)
+		fs.PrintDefaults()
+		if c.help != "" {
+			fmt.Fprintf(w, "\n%s", strings.ReplaceAll(c.help, "{prog}", e.prog))
+		}
+	}
+	return fs, configPath, runner
+}
+
+// parse parses args into fs and fills unset flags from the environment and the
+// config file. ok is false when the command must not run; code is then the
+// exit code.
+func (e *env) parse(fs *flag.FlagSet, configPath *string, args []string) (sources map[string]string, code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, exitOK, false
+		}
+		return nil, exitUsage, false
+	}
+
+	path := *configPath
+	if path == "" {
+		path = os.Getenv(envName("config"))
+	}
+	sources, err := applyConfig(fs, path)
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		return nil, exitUsage, false
+	}
+	return sources, exitOK, true
+}
+
+func (e *env) run(c *command, args []string) int {
+	fs, configPath, runner := e.newFlagSet(c)
+	if _, code, ok := e.parse(fs, configPath, args); !ok {
+		return code
+	}
+	return runner(e, fs.Args())
+}
+
+// config implements "config print [command] [flags]".
+func (e *env) config(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintf(e.stderr, "Usage: %s config print [command] [flags]\n", e.prog)
+		return exitUsage
+	}
+	args = args[1:]
+
+	c := countCommand
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if c = lookupCommand(args[0]); c == nil {
+			fmt.Fprintf(e.stderr, "unknown command %q\n", args[0])
+			return exitUsage
+		}
+		args = args[1:]
+	}
+
+	fs, configPath, _ := e.newFlagSet(c)
+	sources, code, ok := e.parse(fs, configPath, args)
+	if !ok {
+		return code
+	}
+	if err := printConfig(e.stdout, fs, sources); err != nil {
+		slog.Error("failed printing configuration", "error", err)
+		return exitOutput
+	}
+	return exitOK
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// run calls Run and returns its exit code, stdout and stderr.
+func run(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func mustWriteFile(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return p
+}
+
+func appendTo(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun_Dispatch(t *testing.T) {
+	in := mustWriteFile(t, "in.csv", "email\na@x.com\nb@y.com\nc@x.com\n")
+	const counted = "domain,number_of_customers\nx.com,2\ny.com,1\n"
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{"Flags_only_run_count", []string{"-path", in}, exitOK, counted, "INFO summary"},
+		{"Count_command", []string{"count", "-path", in}, exitOK, counted, "total_rows=3"},
+		{"No_arguments", nil, exitUsage, "", "input is required"},
+		{"Help", []string{"-h"}, exitOK, "", "Commands:"},
+		{"Help_for_command", []string{"help", "diff"}, exitOK, "", "<previous> <current>"},
+		{"Command_help_flag", []string{"merge", "-h"}, exitOK, "", "Usage:"},
+		{"Unknown_command", []string{"tally"}, exitUsage, "", `unknown command "tally"`},
+		{"Unknown_flag", []string{"stats", "-bogus"}, exitUsage, "", "flag provided but not defined"},
+		{"Config_without_print", []string{"config"}, exitUsage, "", "config print"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := run(t, tt.args...)
+			if code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d\nstderr:\n%s", code, tt.wantCode, stderr)
+			}
+			if stdout != tt.wantStdout {
+				t.Fatalf("stdout = %q, want %q", stdout, tt.wantStdout)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Fatalf("stderr does not contain %q:\n%s", tt.wantStderr, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_CountExitCodes(t *testing.T) {
+	in := mustWriteFile(t, "in.csv", "name,email\nA,a@x.com\nB\n")
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+	}{
+		{"Strict_and_tolerant", []string{"-path", in, "-strict", "-tolerant"}, exitUsage},
+		{"Bad_log_level", []string{"-path", in, "-log-level", "loud"}, exitUsage},
+		{"Missing_input", []string{"-path", filepath.Join(dir, "missing.csv")}, exitInput},
+		{"Missing_header", []string{"-path", in, "-email-header", "mail"}, exitHeader},
+		{"Bad_rows", []string{"-path", in, "-max-bad-rows", "0"}, exitBadRows},
+		{"Output_is_directory", []string{"-path", in, "-out", dir}, exitOutput},
+		{"Unknown_out_format", []string{"-path", in, "-out-format", "xml"}, exitUsage},
+		// Checked with the flags, before the input is even opened.
+		{"Unknown_out_format_before_import", []string{"-path", filepath.Join(dir, "missing.csv"), "-out-format", "xml"}, exitUsage},
+		{"Sqlite_to_stdout", []string{"-path", in, "-out-format", "sqlite"}, exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, stderr := run(t, tt.args...); code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d\nstderr:\n%s", code, tt.wantCode, stderr)
+			}
+		})
+	}
+
+	t.Run("Unknown_out_format_keeps_output", func(t *testing.T) {
+		out := mustWriteFile(t, "result.csv", "old")
+		if code, _, _ := run(t, "count", "-path", in, "-out", out, "-out-format", "xml"); code != exitUsage {
+			t.Fatalf("exit code %d, want %d", code, exitUsage)
+		}
+		if b, _ := os.ReadFile(out); string(b) != "old" {
+			t.Fatalf("result.csv = %q, want it untouched", b)
+		}
+	})
+}
+
+func TestRun_Version(t *testing.T) {
+	old := Version
+	Version = "v1.2.3"
+	defer func() { Version = old }()
+
+	code, stdout, _ := run(t, "version")
+	if code != exitOK || !strings.Contains(stdout, " v1.2.3 (go") {
+		t.Fatalf("version: code=%d stdout=%q", code, stdout)
+	}
+}
+
+func TestRun_CountState(t *testing.T) {
+	in := mustWriteFile(t, "in.csv", "email\na@x.com\nb@y.com\n")
+	state := filepath.Join(t.TempDir(), "in.state.json")
+
+	code, stdout, stderr := run(t, "count", "-path", in, "-state", state)
+	if code != exitOK || stdout != "domain,number_of_customers\nx.com,1\ny.com,1\n" {
+		t.Fatalf("first run: exit code %d, stdout %q\nstderr:\n%s", code, stdout, stderr)
+	}
+	if !strings.Contains(stderr, "resumed=false") {
+		t.Fatalf("first run stderr:\n%s", stderr)
+	}
+
+	appendTo(t, in, "c@x.com\n")
+
+	code, stdout, stderr = run(t, "count", "-path", in, "-state", state)
+	if code != exitOK || stdout != "domain,number_of_customers\nx.com,2\ny.com,1\n" {
+		t.Fatalf("second run: exit code %d, stdout %q\nstderr:\n%s", code, stdout, stderr)
+	}
+	if !strings.Contains(stderr, "resumed=true from_offset=22 to_offset=30") || !strings.Contains(stderr, "total_rows=3") {
+		t.Fatalf("second run stderr:\n%s", stderr)
+	}
+
+	if err := os.WriteFile(in, []byte("email\nz@z.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, stderr = run(t, "count", "-path", in, "-state", state)
+	if code != exitOK || stdout != "domain,number_of_customers\nz.com,1\n" || !strings.Contains(stderr, "checkpoint not used") {
+		t.Fatalf("rewritten input: exit code %d, stdout %q\nstderr:\n%s", code, stdout, stderr)
+	}
+
+	ndjson := mustWriteFile(t, "in.ndjson", `{"email":"a@x.com"}`+"\n")
+	if code, _, stderr := run(t, "count", "-path", ndjson, "-state", state); code != exitUsage {
+		t.Fatalf("-state with NDJSON: exit code %d, want %d\nstderr:\n%s", code, exitUsage, stderr)
+	}
+}
+
+func TestRun_CountMetrics(t *testing.T) {
+	in := mustWriteFile(t, "in.csv", "email\na@x.com\nb@y.com\nc@x.com\nbroken\n")
+	prom := filepath.Join(t.TempDir(), "edc.prom")
+
+	if code, _, stderr := run(t, "count", "-path", in, "-metrics-textfile", prom, "-metrics-top-domains", "1"); code != exitOK {
+		t.Fatalf("exit code %d\nstderr:\n%s", code, stderr)
+	}
+	b, err := os.ReadFile(prom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`edc_runs_total{outcome="success"} 1`,
+		"edc_rows_total 4",
+		`edc_bad_rows_total{reason="invalid_address"} 1`,
+		"edc_unique_domains 2",
+		`edc_domain_customers{domain="x.com"} 2`,
+	} {
+		if !strings.Contains(string(b), line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, b)
+		}
+	}
+
+	if code, _, _ := run(t, "count", "-path", in, "-max-bad-rows", "0", "-metrics-textfile", prom); code != exitBadRows {
+		t.Fatalf("exit code %d, want %d", code, exitBadRows)
+	}
+	if b, _ := os.ReadFile(prom); !strings.Contains(string(b), `edc_runs_total{outcome="failure"} 1`) {
+		t.Fatalf("failed run not recorded:\n%s", b)
+	}
+
+	// A run that fails after counting, here on -summary-json, is only a
+	// failure.
+	badSummary := filepath.Join(t.TempDir(), "missing", "run.json")
+	if code, _, _ := run(t, "count", "-path", in, "-summary-json", badSummary, "-metrics-textfile", prom); code != exitOutput {
+		t.Fatalf("unwritable summary: exit code %d, want %d", code, exitOutput)
+	}
+	if b, _ := os.ReadFile(prom); !strings.Contains(string(b), `edc_runs_total{outcome="failure"} 1`) || strings.Contains(string(b), `edc_runs_total{outcome="success"} 1`) {
+		t.Fatalf("unwritable summary recorded as:\n%s", b)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing", "edc.prom")
+	if code, _, stderr := run(t, "count", "-path", in, "-metrics-textfile", missing); code != exitOutput || !strings.Contains(stderr, "failed writing metrics") {
+		t.Fatalf("unwritable textfile: exit code %d\nstderr:\n%s", code, stderr)
+	}
+}
+
+func TestRun_CountReports(t *testing.T) {
+	in := mustWriteFile(t, "in.csv", "email\na@x.com\nb@y.com\nc@x.com\nbroken\n")
+
+	code, stdout, stderr := run(t, "count", "-path", in, "-out-format", "table")
+	want := "#  DOMAIN  CUSTOMERS  SHARE\n1  x.com           2  66.7%\n2  y.com           1  33.3%\n\n2 domains, 3 customers from 4 rows (1 bad, 0 malformed)\n"
+	if code != exitOK || stdout != want {
+		t.Fatalf("table: exit code %d, stdout %q\nstderr:\n%s", code, stdout, stderr)
+	}
+	if _, stdout, _ := run(t, "count", "-path", in, "-out-format", "table", "-color", "always"); !strings.Contains(stdout, "\033[1mDOMAIN\033[0m") {
+		t.Fatalf("-color always: %q", stdout)
+	}
+	if code, _, stderr := run(t, "count", "-path", in, "-out-format", "table", "-color", "yes"); code != exitUsage || !strings.Contains(stderr, "invalid -color") {
+		t.Fatalf("-color yes: exit code %d\nstderr:\n%s", code, stderr)
+	}
+
+	if _, stdout, _ := run(t, "count", "-path", in, "-out-format", "markdown", "-top", "1"); !strings.Contains(stdout, "| 1 | x.com | 2 | 66.67% | 66.67% |\n\n1 more domain has") {
+		t.Fatalf("markdown:\n%s", stdout)
+	}
+	report := filepath.Join(t.TempDir(), "report.md")
+	if code, _, _ := run(t, "count", "-path", in, "-out", report); code != exitOK {
+		t.Fatalf("-out report.md: exit code %d", code)
+	}
+	if b, _ := os.ReadFile(report); !strings.HasPrefix(string(b), "# Email domain report\n") {
+		t.Fatalf("report.md:\n%s", b)
+	}
+
+	report = filepath.Join(t.TempDir(), "report.html")
+	if code, _, _ := run(t, "count", "-path", in, "-out", report, "-top", "1"); code != exitOK {
+		t.Fatalf("-out report.html: exit code %d", code)
+	}
+	if b, _ := os.ReadFile(report); !strings.Contains(string(b), "<h2>Top 1 domains</h2>") || !strings.Contains(string(b), "Source: "+in) {
+		t.Fatalf("report.html:\n%s", b)
+	}
+}
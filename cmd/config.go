@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix prefixes the environment variable of every flag: -email-header is
+// EDC_EMAIL_HEADER.
+const envPrefix = "EDC_"
+
+// Where an effective option value came from, lowest precedence first.
+const (
+	sourceDefault = "default"
+	sourceConfig  = "config"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+var errUnknownConfigFormat = errors.New("config file must be .yaml, .yml, .toml or .json")
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyConfig fills every flag not given on the command line from the
+// environment or, failing that, from the config file at path (skipped if
+// empty). It returns the source of each flag's value.
+func applyConfig(fs *flag.FlagSet, path string) (map[string]string, error) {
+	sources := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) { sources[f.Name] = sourceDefault })
+	fs.Visit(func(f *flag.Flag) { sources[f.Name] = sourceFlag })
+
+	var file map[string]string
+	if path != "" {
+		var err error
+		if file, err = loadConfig(path); err != nil {
+			return nil, err
+		}
+		for name := range file {
+			if name == "config" {
+				return nil, fmt.Errorf("%s: a config file cannot name another config file", path)
+			}
+			// One file can serve several commands; only names no command
+			// knows are mistakes.
+			if fs.Lookup(name) == nil && !knownOption(name) {
+				return nil, fmt.Errorf("%s: unknown option %q", path, name)
+			}
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || sources[f.Name] == sourceFlag || f.Name == "config" {
+			return
+		}
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			if err = fs.Set(f.Name, v); err != nil {
+				err = fmt.Errorf("%s=%q: %w", envName(f.Name), v, err)
+			}
+			sources[f.Name] = sourceEnv
+		} else if v, ok := file[f.Name]; ok {
+			if err = fs.Set(f.Name, v); err != nil {
+				err = fmt.Errorf("%s: %s=%q: %w", path, f.Name, v, err)
+			}
+			sources[f.Name] = sourceConfig
+		}
+	})
+	return sources, err
+}
+
+// knownOption reports whether any command has a flag called name.
+func knownOption(name string) bool {
+	for _, c := range commands() {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.setup(fs)
+		if fs.Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// loadConfig reads a flat YAML, TOML or JSON document whose keys are flag
+// names (underscores are accepted for dashes). Lists become comma-separated
+// values, as -mbox-headers expects.
+func loadConfig(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &raw)
+	case ".toml":
+		err = toml.Unmarshal(b, &raw)
+	case ".json":
+		err = json.Unmarshal(b, &raw)
+	default:
+		return nil, fmt.Errorf("%s: %w", path, errUnknownConfigFormat)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	out := make(map[string]string, len(raw))
+	for key, v := range raw {
+		name := strings.ReplaceAll(key, "_", "-")
+		switch v := v.(type) {
+		case map[string]any:
+			return nil, fmt.Errorf("%s: option %q must be a value, not a table", path, key)
+		case []any:
+			parts := make([]string, len(v))
+			for i, item := range v {
+				parts[i] = fmt.Sprint(item)
+			}
+			out[name] = strings.Join(parts, ",")
+		case nil:
+			out[name] = ""
+		default:
+			out[name] = fmt.Sprint(v)
+		}
+	}
+	return out, nil
+}
+
+// printConfig writes the effective options as YAML, usable as a -config file,
+// with the source of each value as a line comment.
+func printConfig(w io.Writer, fs *flag.FlagSet, sources map[string]string) error {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name != "config" {
+			names = append(names, f.Name)
+		}
+	})
+	sort.Strings(names)
+
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range names {
+		var value yaml.Node
+		if err := value.Encode(fs.Lookup(name).Value.(flag.Getter).Get()); err != nil {
+			return err
+		}
+		value.LineComment = sources[name]
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, &value)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
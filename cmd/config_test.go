@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigPrint_Precedence(t *testing.T) {
+	cfg := mustWriteFile(t, "job.toml", "email_header = \"mail\"\nmax-bad-rows = 3\nencoding = \"latin1\"\nmbox-headers = [\"From\", \"To\"]\n")
+	t.Setenv("EDC_MAX_BAD_ROWS", "7")
+	t.Setenv("EDC_EMAIL_COLUMN", "2")
+
+	code, stdout, stderr := run(t, "config", "print", "-config", cfg, "-encoding", "utf-8")
+	if code != exitOK {
+		t.Fatalf("exit code %d\nstderr:\n%s", code, stderr)
+	}
+	for _, line := range []string{
+		"email-header: mail # config",
+		"max-bad-rows: 7 # env",
+		"email-column: 2 # env",
+		"encoding: utf-8 # flag",
+		"mbox-headers: From,To # config",
+		"out: \"\" # default",
+	} {
+		if !strings.Contains(stdout, line+"\n") {
+			t.Errorf("expected %q in output:\n%s", line, stdout)
+		}
+	}
+}
+
+func TestConfig_SharedAcrossCommands(t *testing.T) {
+	// summary-json only exists on count.
+	cfg := mustWriteFile(t, "job.yaml", "summary-json: run.json\ntop: 3\nlog-format: json\n")
+
+	code, stdout, stderr := run(t, "config", "print", "stats", "-config", cfg)
+	if code != exitOK {
+		t.Fatalf("exit code %d\nstderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "top: 3 # config\n") || strings.Contains(stdout, "summary-json") {
+		t.Fatalf("stats config:\n%s", stdout)
+	}
+
+	typo := mustWriteFile(t, "typo.yaml", "emial-header: mail\n")
+	if code, _, stderr := run(t, "config", "print", "-config", typo); code != exitUsage || !strings.Contains(stderr, `unknown option \"emial-header\"`) {
+		t.Fatalf("typo: exit code %d\nstderr:\n%s", code, stderr)
+	}
+}
+
+func TestLoadConfig_Formats(t *testing.T) {
+	files := map[string]string{
+		"job.yaml": "email-header: mail\nquiet: true\nmax-bad-ratio: 0.5\n",
+		"job.toml": "email-header = \"mail\"\nquiet = true\nmax-bad-ratio = 0.5\n",
+		"job.json": `{"email-header": "mail", "quiet": true, "max-bad-ratio": 0.5}`,
+	}
+	for name, content := range files {
+		got, err := loadConfig(mustWriteFile(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got["email-header"] != "mail" || got["quiet"] != "true" || got["max-bad-ratio"] != "0.5" {
+			t.Fatalf("%s: got %v", name, got)
+		}
+	}
+
+	if _, err := loadConfig(mustWriteFile(t, "job.ini", "x=1")); err == nil {
+		t.Fatal("expected an error for an unknown extension")
+	}
+	if _, err := loadConfig(mustWriteFile(t, "nested.yaml", "input:\n  path: x\n")); err == nil {
+		t.Fatal("expected an error for a nested table")
+	}
+}
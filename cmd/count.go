@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"log/slog"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/exporter"
+	"github.com/daveteshome/email-domain-counter/metrics"
+)
+
+// rejectedSampleSize is how many rejected rows are logged at debug level.
+const rejectedSampleSize = 20
+
+var countCommand = &command{
+	name:     "count",
+	synopsis: "-path=<file> [flags]",
+	summary:  "Count customers per email domain and write the sorted result",
+	help: `Examples:
+  # Run and print to console (the command name may be left out)
+  {prog} count -path ./customers.csv
+  {prog} -path ./customers.csv
+
+  # Save to a file
+  {prog} count -path ./customers.csv -out ./result.csv
+
+  # Parquet snapshot in, Parquet results out
+  {prog} count -path ./snapshot.parquet -out ./result.parquet
+
+  # Count a SQLite table and append the run to a results database
+  {prog} count -path ./crm.db -query "SELECT email FROM customers" -out ./runs.sqlite
+
+  # Count senders only in a mail archive
+  {prog} count -path ./archive.mbox -mbox-headers From
+
+  # Aggregate every CSV inside a partner bundle
+  {prog} count -path ./partner.tar.gz -members "*.csv"
+
+  # Read the "Customers" sheet of a workbook
+  {prog} count -path ./customers.xlsx -sheet Customers
+
+  # NDJSON with a nested email field
+  {prog} count -path ./events.ndjson -email-path "contact.emails[0]"
+
+  # Headerless input, email in the 3rd column
+  {prog} count -path ./raw.csv -no-header -email-column=3
+
+  # Skip unparsable lines but fail if more than 1% of rows are bad
+  {prog} count -path ./customers.csv -tolerant -max-bad-ratio=0.01
+
+  # Nightly count of an append-only file: only new lines are read
+  {prog} count -path ./customers.csv -state ./customers.state.json -out ./result.csv
+
+  # Machine-readable summary for a scheduler
+  {prog} count -path ./customers.csv -out ./result.csv -summary-json ./run.json
+
+  # Metrics for the node_exporter textfile collector, with the top 10 domains
+  {prog} count -path ./customers.csv -out ./result.csv -metrics-textfile /var/lib/node_exporter/edc.prom -metrics-top-domains 10
+
+  # Read the result in the terminal, or as a Markdown report for a ticket
+  {prog} count -path ./customers.csv -out-format table
+  {prog} count -path ./customers.csv -out ./report.md -top 20
+
+  # Self-contained HTML report with charts, to email to stakeholders
+  {prog} count -path ./customers.csv -out ./report.html
+
+  # Cron job: errors only, as JSON
+  {prog} count -path ./customers.csv -out ./result.csv -quiet -log-format json
+
+  # Shared job settings in a file, one override from the environment
+  EDC_MAX_BAD_RATIO=0.05 {prog} count -config ./jobs/nightly.yaml -path ./customers.csv
+`,
+	setup: func(fs *flag.FlagSet) func(*env, []string) int {
+		o := &countOptions{}
+		fs.StringVar(&o.path, "path", "", "Path to the file with customer data (required)")
+		fs.StringVar(&o.outFile, "out", "", "Optional: output file path (stdout if empty)")
+		fs.StringVar(&o.outFormat, "out-format", "", "Output format: csv, json, parquet, sqlite, table, markdown or html (detected from -out extension if empty, csv for stdout)")
+		fs.StringVar(&o.summaryJSON, "summary-json", "", "Optional: write a JSON run summary (stats, timing, input, options) to this file")
+		fs.StringVar(&o.metricsTextfile, "metrics-textfile", "", "Optional: write Prometheus metrics of the run to this file (name it *.prom for the node_exporter textfile collector)")
+		fs.IntVar(&o.metricsTop, "metrics-top-domains", 0, "Add a metric per domain for this many largest domains to -metrics-textfile")
+		fs.StringVar(&o.state, "state", "", "Optional: checkpoint file for a CSV that only grows at the end; later runs count just the appended lines")
+		o.input.register(fs)
+		o.malformed.register(fs)
+		o.threshold.register(fs, -1)
+		o.report.register(fs)
+		o.log.register(fs)
+		return func(e *env, _ []string) int {
+			sum := newRunSummary(fs, o.path)
+			code, err := o.run(e, fs, sum)
+			if o.summaryJSON != "" {
+				if werr := sum.write(o.summaryJSON, code, err); werr != nil {
+					slog.Error("failed writing summary", "summary_json", o.summaryJSON, "error", werr)
+					if code == exitOK {
+						code = exitOutput
+					}
+				}
+			}
+			if o.metricsTextfile != "" {
+				// Recorded once, from the final code: a run whose summary
+				// could not be written is a failure.
+				reg := metrics.NewRegistry(o.metricsTop)
+				if code == exitOK {
+					reg.Observe(o.result, o.exportTime)
+				} else {
+					reg.ObserveFailure()
+				}
+				if werr := metrics.WriteTextfile(o.metricsTextfile, reg); werr != nil {
+					slog.Error("failed writing metrics", "metrics_textfile", o.metricsTextfile, "error", werr)
+					if code == exitOK {
+						code = exitOutput
+					}
+				}
+			}
+			return code
+		}
+	},
+}
+
+type countOptions struct {
+	path            string
+	outFile         string
+	outFormat       string
+	summaryJSON     string
+	metricsTextfile string
+	metricsTop      int
+	state           string
+	input           inputOptions
+	malformed       malformedOptions
+	threshold       thresholdOptions
+	report          reportOptions
+	log             logOptions
+
+	// result and exportTime are set by a run that got as far as writing
+	// its output, for -metrics-textfile.
+	result     customerimporter.Result
+	exportTime time.Duration
+}
+
+// run does the work of count and returns the exit code, plus the error that
+// caused it for the run summary. Failures are logged where they happen.
+func (o *countOptions) run(e *env, fs *flag.FlagSet, sum *runSummary) (int, error) {
+	if err := o.log.setup(e); err != nil {
+		slog.Error(err.Error())
+		return exitUsage, err
+	}
+
+	if o.path == "" {
+		err := errors.New("input is required: pass -path=<file>")
+		slog.Error(err.Error())
+		fs.Usage()
+		return exitUsage, err
+	}
+	cfg, err := o.input.config(o.path)
+	if err == nil {
+		cfg.Malformed, err = o.malformed.policy()
+	}
+	if err == nil {
+		err = o.report.check()
+	}
+	if err == nil {
+		err = checkOutFormat(o.outFile, o.outFormat)
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage, err
+	}
+
+	info, err := statInput(o.path)
+	if err != nil {
+		return exitInput, err
+	}
+	sum.Input.SizeBytes = info.Size()
+	sum.Input.ModifiedAt = info.ModTime()
+
+	debug := debugEnabled()
+	if debug {
+		cfg.RejectedSample = rejectedSampleSize
+	}
+	cfg.StatePath = o.state
+	result, code, err := e.importInput(cfg, o.log.quiet)
+	sum.setResult(result)
+	if o.state != "" {
+		sum.setIncremental(result.Incremental)
+	}
+	if err != nil {
+		return code, err
+	}
+	if o.state != "" {
+		logIncremental(o.state, result.Incremental)
+	}
+
+	logBadRows(result)
+	if err := customerimporter.CheckBadRows(result.Stats, o.threshold.maxBadRows, o.threshold.maxBadRatio); err != nil {
+		slog.Error("too many bad rows, results were not written",
+			"file", o.path,
+			"total_rows", result.Stats.TotalRows,
+			"bad_rows", result.Stats.BadRows,
+			"error", err,
+		)
+		return exitBadRows, err
+	}
+
+	exportStart := time.Now()
+	if o.outFile == "" {
+		if err := o.report.write(e.stdout, o.outFormat, result); err != nil {
+			slog.Error("failed writing to stdout", "error", err)
+			return outputExitCode(err), err
+		}
+	} else {
+		exp := exporter.NewCustomerExporter(o.outFile).WithFormat(o.outFormat).WithSource(o.path).WithTop(o.report.top)
+		if err := exp.ExportResult(result); err != nil {
+			slog.Error("failed writing file", "out", o.outFile, "error", err)
+			return outputExitCode(err), err
+		}
+	}
+	exportTime := time.Since(exportStart)
+	sum.setExport(exportTime)
+	o.result, o.exportTime = result, exportTime
+
+	if debug {
+		logPhases(result.Timings, exportTime)
+	}
+
+	for _, m := range result.Members {
+		slog.Info("member",
+			"name", m.Name,
+			"format", m.Format,
+			"total_rows", m.Stats.TotalRows,
+			"bad_rows", m.Stats.BadRows,
+			"unique_domains", m.Stats.UniqueDomains,
+		)
+	}
+
+	slog.Info("summary",
+		"file", o.path,
+		"total_rows", result.Stats.TotalRows,
+		"bad_rows", result.Stats.BadRows,
+		"malformed_rows", result.Stats.MalformedRows,
+		"unique_domains", result.Stats.UniqueDomains,
+		"sorted", "count desc, domain asc",
+		"single_label_allowed", o.input.allowSingleLabelDomain,
+	)
+
+	return exitOK, nil
+}
+
+// logIncremental reports whether a -state checkpoint was resumed. A state
+// that could not be used is worth a warning: the run read the whole input.
+func logIncremental(path string, inc customerimporter.Incremental) {
+	if inc.Reason != "" {
+		slog.Warn("checkpoint not used, counted the whole input", "state", path, "reason", inc.Reason)
+	}
+	slog.Info("checkpoint",
+		"state", path,
+		"resumed", inc.Resumed,
+		"from_offset", inc.Offset,
+		"to_offset", inc.End,
+	)
+}
+
+func logPhases(t customerimporter.Timings, export time.Duration) {
+	for _, p := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"open", t.Open},
+		{"parse", t.Parse},
+		{"sort", t.Sort},
+		{"export", export},
+	} {
+		slog.Debug("phase", "name", p.name, "duration", p.d)
+	}
+}
+
+// outputExitCode maps an export error to an exit code; an unknown or
+// stream-incompatible -out-format is a usage error.
+func outputExitCode(err error) int {
+	if errors.Is(err, exporter.ErrUnsupportedFormat) || errors.Is(err, exporter.ErrFileRequired) {
+		return exitUsage
+	}
+	return exitOutput
+}
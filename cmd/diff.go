@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/exporter"
+)
+
+// Diff input types.
+const (
+	inputAuto   = "auto"
+	inputRaw    = "raw"
+	inputResult = "result"
+)
+
+var diffCommand = &command{
+	name:     "diff",
+	synopsis: "[flags] <previous> <current>",
+	summary:  "Compare the per-domain customer counts of two inputs",
+	help: `Each input is a customer file, counted with the input flags, or a result
+previously written by count as CSV or JSON. With -input-type auto, .csv and
+.json files with the result layout are read as results and anything else is
+counted.
+
+The output has one line per domain found in either input:
+domain,previous,current,change,percent_change,status. percent_change is empty
+for new domains; status is new, disappeared, up, down or unchanged.
+
+Examples:
+  {prog} diff ./customers-2025-08.csv ./customers-2025-09.csv
+  {prog} diff -sort percent ./results-2025-08.csv ./customers-2025-09.xlsx
+  {prog} diff -out ./changes.json -email-header contact_email ./old.xlsx ./new.xlsx
+`,
+	setup: func(fs *flag.FlagSet) func(*env, []string) int {
+		o := &diffOptions{}
+		fs.StringVar(&o.outFile, "out", "", "Optional: output file path (stdout if empty)")
+		fs.StringVar(&o.outFormat, "out-format", "", "Output format: csv or json (detected from -out's extension, csv if empty)")
+		fs.StringVar(&o.inputType, "input-type", inputAuto, "How inputs are read: auto, raw (customer files) or result (exported CSV/JSON)")
+		fs.StringVar(&o.sort, "sort", customerimporter.DiffOrderMagnitude, "Order: magnitude (largest absolute change first), change, percent or domain")
+		o.input.register(fs)
+		o.malformed.register(fs)
+		o.log.register(fs)
+		return func(e *env, args []string) int { return o.run(e, fs, args) }
+	},
+}
+
+type diffOptions struct {
+	outFile   string
+	outFormat string
+	inputType string
+	sort      string
+	input     inputOptions
+	malformed malformedOptions
+	log       logOptions
+}
+
+func (o *diffOptions) run(e *env, fs *flag.FlagSet, args []string) int {
+	if err := o.log.setup(e); err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	if len(args) != 2 {
+		slog.Error("diff needs two inputs: <previous> <current>")
+		fs.Usage()
+		return exitUsage
+	}
+	policy, err := o.malformed.policy()
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	switch o.inputType {
+	case inputAuto, inputRaw, inputResult:
+	default:
+		slog.Error("invalid -input-type, want auto, raw or result", "input_type", o.inputType)
+		return exitUsage
+	}
+	format := o.outFormat
+	if format == "" {
+		format = exporter.FormatForPath(o.outFile)
+	}
+	if format != exporter.FormatCSV && format != exporter.FormatJSON {
+		slog.Error("invalid -out-format for diff, want csv or json", "out_format", format)
+		return exitUsage
+	}
+
+	var results [2]customerimporter.Result
+	for i, path := range args {
+		res, code, err := o.load(e, path, policy)
+		if err != nil {
+			return code
+		}
+		results[i] = res
+	}
+
+	changes := customerimporter.Diff(results[0].Data, results[1].Data)
+	if err := customerimporter.SortChanges(changes, o.sort); err != nil {
+		slog.Error("invalid -sort, want magnitude, change, percent or domain", "sort", o.sort)
+		return exitUsage
+	}
+
+	w, closeOut, err := e.openOutput(o.outFile)
+	if err == nil {
+		if format == exporter.FormatJSON {
+			err = writeDiffJSON(w, changes)
+		} else {
+			err = writeDiffCSV(w, changes)
+		}
+		if cerr := closeOut(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		slog.Error("failed writing diff", "error", err)
+		return exitOutput
+	}
+
+	var added, gone int
+	for _, c := range changes {
+		switch c.Status() {
+		case customerimporter.ChangeNew:
+			added++
+		case customerimporter.ChangeDisappeared:
+			gone++
+		}
+	}
+	slog.Info("diff",
+		"previous", args[0],
+		"current", args[1],
+		"previous_domains", results[0].Stats.UniqueDomains,
+		"current_domains", results[1].Stats.UniqueDomains,
+		"new_domains", added,
+		"disappeared_domains", gone,
+	)
+	return exitOK
+}
+
+// load reads one diff input as an exported result or counts it, depending on
+// -input-type.
+func (o *diffOptions) load(e *env, path string, policy customerimporter.MalformedPolicy) (customerimporter.Result, int, error) {
+	if _, err := statInput(path); err != nil {
+		return customerimporter.Result{}, exitInput, err
+	}
+
+	if o.inputType == inputResult || o.inputType == inputAuto && o.input.format == "" && resultExt(path) {
+		res, err := exporter.ReadResultFile(path)
+		switch {
+		case err == nil:
+			slog.Debug("read exported result", "path", path, "unique_domains", res.Stats.UniqueDomains)
+			return res, exitOK, nil
+		case o.inputType == inputAuto && errors.Is(err, exporter.ErrNotResult):
+			// A customer file: count it below.
+		default:
+			slog.Error("cannot read result", "path", path, "error", err)
+			return res, exitInput, err
+		}
+	}
+
+	cfg, err := o.input.config(path)
+	if err != nil {
+		slog.Error(err.Error())
+		return customerimporter.Result{}, exitUsage, err
+	}
+	cfg.Malformed = policy
+	res, code, err := e.importInput(cfg, o.log.quiet)
+	if err != nil {
+		return res, code, err
+	}
+	logBadRows(res)
+	return res, exitOK, nil
+}
+
+// resultExt reports whether path has the extension of a result format
+// exporter.ReadResult understands.
+func resultExt(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".json":
+		return true
+	}
+	return false
+}
+
+// percentChange formats DomainChange.Percent with two decimals, empty for new
+// domains.
+func percentChange(c customerimporter.DomainChange) string {
+	pct, ok := c.Percent()
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(pct, 'f', 2, 64)
+}
+
+func writeDiffCSV(w io.Writer, changes []customerimporter.DomainChange) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"domain", "previous", "current", "change", "percent_change", "status"})
+	for _, c := range changes {
+		cw.Write([]string{
+			c.Domain,
+			strconv.Itoa(c.Previous),
+			strconv.Itoa(c.Current),
+			strconv.Itoa(c.Delta()),
+			percentChange(c),
+			c.Status(),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// diffLine is the JSON form of a DomainChange; PercentChange is null for new
+// domains.
+type diffLine struct {
+	Domain        string   `json:"domain"`
+	Previous      int      `json:"previous"`
+	Current       int      `json:"current"`
+	Change        int      `json:"change"`
+	PercentChange *float64 `json:"percent_change"`
+	Status        string   `json:"status"`
+}
+
+func writeDiffJSON(w io.Writer, changes []customerimporter.DomainChange) error {
+	lines := make([]diffLine, len(changes))
+	for i, c := range changes {
+		lines[i] = diffLine{Domain: c.Domain, Previous: c.Previous, Current: c.Current, Change: c.Delta(), Status: c.Status()}
+		if pct, ok := c.Percent(); ok {
+			lines[i].PercentChange = &pct
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(lines); err != nil {
+		return fmt.Errorf("encode diff: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	previous := mustWriteFile(t, "previous.csv", "email\na@x.com\nb@x.com\nc@y.com\ne@gone.com\n")
+	current := mustWriteFile(t, "current.csv", "email\na@x.com\nc@y.com\nd@z.com\n")
+	const want = "domain,previous,current,change,percent_change,status\n" +
+		"gone.com,1,0,-1,-100.00,disappeared\n" +
+		"x.com,2,1,-1,-50.00,down\n" +
+		"z.com,0,1,1,,new\n" +
+		"y.com,1,1,0,0.00,unchanged\n"
+
+	code, stdout, stderr := run(t, "diff", previous, current)
+	if code != exitOK {
+		t.Fatalf("exit code %d\nstderr:\n%s", code, stderr)
+	}
+	if stdout != want {
+		t.Fatalf("diff:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	out := filepath.Join(t.TempDir(), "changes.csv")
+	if code, _, stderr := run(t, "diff", "-out", out, previous, current); code != exitOK {
+		t.Fatalf("diff -out: exit code %d\nstderr:\n%s", code, stderr)
+	}
+	if b, err := os.ReadFile(out); err != nil || string(b) != want {
+		t.Fatalf("diff -out wrote %q (err=%v)", b, err)
+	}
+
+	if code, _, _ := run(t, "diff", previous); code != exitUsage {
+		t.Fatalf("diff with one input: exit code %d, want %d", code, exitUsage)
+	}
+}
+
+func TestDiff_ResultInputs(t *testing.T) {
+	previous := mustWriteFile(t, "previous-result.csv", "domain,number_of_customers\nx.com,4\ny.com,1\n")
+	current := mustWriteFile(t, "current.csv", "email\na@x.com\nb@x.com\nc@y.com\nd@y.com\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "Result_and_raw",
+			args: []string{"diff", previous, current},
+			want: "domain,previous,current,change,percent_change,status\n" +
+				"x.com,4,2,-2,-50.00,down\n" +
+				"y.com,1,2,1,100.00,up\n",
+		},
+		{
+			name: "Sort_by_percent",
+			args: []string{"diff", "-sort", "percent", previous, current},
+			want: "domain,previous,current,change,percent_change,status\n" +
+				"y.com,1,2,1,100.00,up\n" +
+				"x.com,4,2,-2,-50.00,down\n",
+		},
+	}
+	for _, tt := range tests {
+		code, stdout, stderr := run(t, tt.args...)
+		if code != exitOK {
+			t.Fatalf("[%s] exit code %d\nstderr:\n%s", tt.name, code, stderr)
+		}
+		if stdout != tt.want {
+			t.Errorf("[%s] diff:\n%s\nwant:\n%s", tt.name, stdout, tt.want)
+		}
+	}
+
+	// A result read as a customer file has no email column.
+	if code, _, _ := run(t, "diff", "-input-type", "raw", previous, current); code != exitHeader {
+		t.Fatalf("-input-type raw: exit code %d, want %d", code, exitHeader)
+	}
+	// A customer file is not a result.
+	if code, _, _ := run(t, "diff", "-input-type", "result", previous, current); code != exitInput {
+		t.Fatalf("-input-type result: exit code %d, want %d", code, exitInput)
+	}
+	if code, _, _ := run(t, "diff", "-sort", "size", previous, current); code != exitUsage {
+		t.Fatalf("-sort size: exit code %d, want %d", code, exitUsage)
+	}
+}
+
+func TestDiff_JSON(t *testing.T) {
+	previous := mustWriteFile(t, "previous.csv", "email\na@x.com\n")
+	current := mustWriteFile(t, "current.csv", "email\na@x.com\nb@x.com\nc@y.com\n")
+
+	code, stdout, stderr := run(t, "diff", "-out-format", "json", previous, current)
+	if code != exitOK {
+		t.Fatalf("exit code %d\nstderr:\n%s", code, stderr)
+	}
+	var got []struct {
+		Domain        string   `json:"domain"`
+		Change        int      `json:"change"`
+		PercentChange *float64 `json:"percent_change"`
+		Status        string   `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("decode %q: %v", stdout, err)
+	}
+	if len(got) != 2 || got[0].Domain != "x.com" || got[0].Change != 1 || got[0].PercentChange == nil || *got[0].PercentChange != 100 {
+		t.Fatalf("first change = %+v", got)
+	}
+	if got[1].Domain != "y.com" || got[1].Status != "new" || got[1].PercentChange != nil {
+		t.Fatalf("second change = %+v", got[1])
+	}
+}
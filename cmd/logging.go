@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// logHandler is slog's initial handler, which writes through the standard log
+// package with its date and time prefix. It is kept so the text format can be
+// installed again after another one in the same process.
+var logHandler = slog.Default().Handler()
+
+// setupLogging installs the default slog logger. The text format keeps the
+// standard log prefix the tool has always printed; -quiet raises the level to
+// error.
+func setupLogging(w io.Writer, level, format string, quiet bool) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("-log-level must be debug, info, warn or error, got %q", level)
+	}
+	if quiet {
+		lvl = slog.LevelError
+	}
+
+	switch strings.ToLower(format) {
+	case "text", "":
+		log.SetOutput(w)
+		log.SetFlags(log.LstdFlags)
+		slog.SetLogLoggerLevel(lvl)
+		slog.SetDefault(slog.New(logHandler))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})))
+	default:
+		return fmt.Errorf("-log-format must be text or json, got %q", format)
+	}
+	return nil
+}
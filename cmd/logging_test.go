@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"regexp"
+	"testing"
+)
+
+// TestSetupLogging_TextAfterJSON checks that the text format does not keep a
+// JSON handler installed earlier in the process.
+func TestSetupLogging_TextAfterJSON(t *testing.T) {
+	t.Cleanup(func() { setupLogging(os.Stderr, "info", "text", false) })
+	var b bytes.Buffer
+	if err := setupLogging(&b, "info", "json", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := setupLogging(&b, "info", "text", false); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	slog.Info("count", "total_rows", 3)
+	slog.Debug("hidden")
+	if !regexp.MustCompile(`^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d INFO count total_rows=3\n$`).MatchString(b.String()) {
+		t.Fatalf("text log = %q", b.String())
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"flag"
+	"log/slog"
+	"strings"
+
+	"github.com/daveteshome/email-domain-counter/exporter"
+)
+
+var mergeCommand = &command{
+	name:     "merge",
+	synopsis: "[flags] <result>...",
+	summary:  "Sum previously exported result files into one result",
+	help: `Each input is a result written by count, in any output format: CSV
+(domain,number_of_customers), JSON, Parquet or SQLite (the latest run). The
+format is taken from the file extension. Counts are summed per domain and
+written in the usual order: count descending, then domain ascending.
+
+Examples:
+  {prog} merge -out ./all.csv ./north/result.csv ./south/result.csv
+  {prog} merge -out ./all.parquet ./north/result.parquet ./south/result.json
+`,
+	setup: func(fs *flag.FlagSet) func(*env, []string) int {
+		o := &mergeOptions{}
+		fs.StringVar(&o.outFile, "out", "", "Optional: output file path (stdout if empty)")
+		fs.StringVar(&o.outFormat, "out-format", "", "Output format: csv, json, parquet, sqlite, table, markdown or html (detected from -out extension if empty, csv for stdout)")
+		o.log.register(fs)
+		return func(e *env, args []string) int { return o.run(e, fs, args) }
+	},
+}
+
+type mergeOptions struct {
+	outFile   string
+	outFormat string
+	log       logOptions
+}
+
+func (o *mergeOptions) run(e *env, fs *flag.FlagSet, args []string) int {
+	if err := o.log.setup(e); err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	if len(args) == 0 {
+		slog.Error("merge needs at least one result file")
+		fs.Usage()
+		return exitUsage
+	}
+	if err := checkOutFormat(o.outFile, o.outFormat); err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+
+	for _, path := range args {
+		if _, err := statInput(path); err != nil {
+			return exitInput
+		}
+	}
+	merged, err := exporter.MergeFiles(args...)
+	if err != nil {
+		slog.Error("cannot read result file", "error", err)
+		return exitInput
+	}
+
+	if o.outFile == "" {
+		if err := exporter.Write(e.stdout, o.outFormat, merged); err != nil {
+			slog.Error("failed writing to stdout", "error", err)
+			return outputExitCode(err)
+		}
+	} else {
+		exp := exporter.NewCustomerExporter(o.outFile).WithFormat(o.outFormat).WithSource(strings.Join(args, ","))
+		if err := exp.ExportResult(merged); err != nil {
+			slog.Error("failed writing file", "out", o.outFile, "error", err)
+			return outputExitCode(err)
+		}
+	}
+	slog.Info("merge", "files", len(args), "unique_domains", merged.Stats.UniqueDomains)
+	return exitOK
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daveteshome/email-domain-counter/exporter"
+)
+
+func TestMerge(t *testing.T) {
+	north := mustWriteFile(t, "north.csv", "domain,number_of_customers\nx.com,2\ny.com,5\n")
+	south := mustWriteFile(t, "south.csv", "domain,number_of_customers\nx.com,3\nz.com,5\n")
+
+	code, stdout, stderr := run(t, "merge", north, south)
+	if code != exitOK {
+		t.Fatalf("exit code %d\nstderr:\n%s", code, stderr)
+	}
+	want := "domain,number_of_customers\nx.com,5\ny.com,5\nz.com,5\n"
+	if stdout != want {
+		t.Fatalf("merge:\n%s\nwant:\n%s", stdout, want)
+	}
+}
+
+func TestMerge_Formats(t *testing.T) {
+	north := mustWriteFile(t, "north.csv", "domain,number_of_customers\nx.com,2\ny.com,5\n")
+	south := filepath.Join(t.TempDir(), "south.parquet")
+	if code, _, stderr := run(t, "merge", "-out", south, mustWriteFile(t, "s.csv", "domain,number_of_customers\nx.com,3\nz.com,1\n")); code != exitOK {
+		t.Fatalf("merge to parquet: exit code %d\nstderr:\n%s", code, stderr)
+	}
+
+	out := filepath.Join(t.TempDir(), "all.json")
+	if code, _, stderr := run(t, "merge", "-out", out, north, south); code != exitOK {
+		t.Fatalf("merge csv+parquet: exit code %d\nstderr:\n%s", code, stderr)
+	}
+	got, err := exporter.ReadResultFile(out)
+	if err != nil {
+		t.Fatalf("read merged result: %v", err)
+	}
+	if len(got.Data) != 3 || got.Data[0].Domain != "x.com" || got.Data[0].CustomerQuantity != 5 || got.Stats.UniqueDomains != 3 {
+		t.Fatalf("merged result = %+v", got)
+	}
+}
+
+func TestMerge_RejectsBadInputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "Bad_count",
+			content: "domain,number_of_customers\nx.com,2\ny.com,many\n",
+			want:    `line 3: invalid count \"many\"`,
+		},
+		{
+			name:    "Customer_file",
+			content: "email\nada@x.com\n",
+			want:    "not an exported result",
+		},
+	}
+	for _, tt := range tests {
+		path := mustWriteFile(t, tt.name+".csv", tt.content)
+		code, _, stderr := run(t, "merge", path)
+		if code != exitInput || !strings.Contains(stderr, tt.want) {
+			t.Fatalf("[%s] exit code %d\nstderr:\n%s", tt.name, code, stderr)
+		}
+	}
+}
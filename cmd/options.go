@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/exporter"
+)
+
+// inputOptions are the flags that describe how an input file is read.
+type inputOptions struct {
+	emailHeader            string
+	allowSingleLabelDomain bool
+	noHeader               bool
+	emailColumn            int
+	encoding               string
+	format                 string
+	sheet                  string
+	emailPath              string
+	query                  string
+	mboxHeaders            string
+	members                string
+}
+
+func (o *inputOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.emailHeader, "email-header", "email", "Email column header (case-insensitive)")
+	fs.BoolVar(&o.allowSingleLabelDomain, "allow-single-label-domain", false, "Accept domains without a dot (e.g., user@corp)")
+	fs.BoolVar(&o.noHeader, "no-header", false, "Treat the first row as data (requires -email-column)")
+	fs.StringVar(&o.encoding, "encoding", "auto", "Input encoding: auto, utf-8, utf-16, utf-16le, utf-16be, latin1, windows-1252")
+	fs.StringVar(&o.format, "format", "", "Input format: csv, xlsx, json, ndjson, parquet, sqlite, mbox, vcard, ldif, zip, tar or tar.gz (detected from the file extension if empty)")
+	fs.StringVar(&o.sheet, "sheet", "", "XLSX worksheet name or 1-based position (first sheet if empty)")
+	fs.StringVar(&o.emailPath, "email-path", "", "JSON path to the email field, e.g. contact.emails[0] (defaults to -email-header)")
+	fs.StringVar(&o.query, "query", "", "SELECT to run against SQLite input; the email comes from the -email-header column")
+	fs.StringVar(&o.mboxHeaders, "mbox-headers", "From,To,Cc", "Comma-separated mbox headers whose addresses are counted")
+	fs.StringVar(&o.members, "members", "", "Glob selecting archive members, e.g. *.csv (all recognised files if empty)")
+	fs.IntVar(&o.emailColumn, "email-column", 0, "Email column position, 1-based (overrides -email-header)")
+}
+
+// config validates the flag combination and builds the importer config for path.
+func (o *inputOptions) config(path string) (customerimporter.Config, error) {
+	if o.emailColumn < 0 {
+		return customerimporter.Config{}, fmt.Errorf("-email-column must be a positive position, got %d", o.emailColumn)
+	}
+	if o.noHeader && o.emailColumn == 0 {
+		return customerimporter.Config{}, errors.New("-no-header requires -email-column=<n>")
+	}
+	return customerimporter.Config{
+		Path:                   path,
+		EmailHeader:            o.emailHeader,
+		AllowSingleLabelDomain: o.allowSingleLabelDomain,
+		NoHeader:               o.noHeader,
+		EmailColumn:            o.emailColumn,
+		Encoding:               o.encoding,
+		Format:                 o.format,
+		Sheet:                  o.sheet,
+		EmailPath:              o.emailPath,
+		Query:                  o.query,
+		MboxHeaders:            strings.Split(o.mboxHeaders, ","),
+		Members:                o.members,
+	}, nil
+}
+
+// malformedOptions select the customerimporter.MalformedPolicy.
+type malformedOptions struct {
+	strict   bool
+	tolerant bool
+}
+
+func (o *malformedOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.strict, "strict", false, "Fail on the first malformed row (unparsable or too short)")
+	fs.BoolVar(&o.tolerant, "tolerant", false, "Skip malformed rows, including unparsable CSV, and count them as bad")
+}
+
+func (o *malformedOptions) policy() (customerimporter.MalformedPolicy, error) {
+	switch {
+	case o.strict && o.tolerant:
+		return 0, errors.New("-strict and -tolerant are mutually exclusive")
+	case o.strict:
+		return customerimporter.MalformedStrict, nil
+	case o.tolerant:
+		return customerimporter.MalformedSkip, nil
+	}
+	return customerimporter.MalformedDefault, nil
+}
+
+// thresholdOptions fail a run with exitBadRows; see customerimporter.CheckBadRows.
+type thresholdOptions struct {
+	maxBadRows  int
+	maxBadRatio float64
+}
+
+func (o *thresholdOptions) register(fs *flag.FlagSet, defaultRows int) {
+	fs.IntVar(&o.maxBadRows, "max-bad-rows", defaultRows, "Fail with exit code 5 if more rows than this are bad (-1 disables)")
+	fs.Float64Var(&o.maxBadRatio, "max-bad-ratio", -1, "Fail with exit code 5 if a larger share of rows (0..1) is bad (-1 disables)")
+}
+
+// reportOptions shape the human-readable output formats: table, markdown and
+// html.
+type reportOptions struct {
+	top   int
+	color string
+}
+
+func (o *reportOptions) register(fs *flag.FlagSet) {
+	fs.IntVar(&o.top, "top", exporter.DefaultMarkdownTop, "Domains listed by -out-format markdown and charted by -out-format html (0 means all)")
+	fs.StringVar(&o.color, "color", "auto", "Color -out-format table on stdout: auto (if a terminal and NO_COLOR is unset), always or never")
+}
+
+func (o *reportOptions) check() error {
+	switch o.color {
+	case "auto", "always", "never":
+		return nil
+	}
+	return fmt.Errorf("invalid -color %q, want auto, always or never", o.color)
+}
+
+// write writes res to w in format. A table on a terminal is fitted to its
+// width (or COLUMNS) and colored per -color.
+func (o *reportOptions) write(w io.Writer, format string, res customerimporter.Result) error {
+	switch strings.ToLower(format) {
+	case exporter.FormatMarkdown:
+		return exporter.WriteMarkdown(w, res, exporter.MarkdownOptions{Top: o.top})
+	case exporter.FormatHTML:
+		return exporter.WriteHTML(w, res, exporter.HTMLOptions{Top: o.top, Generated: time.Now()})
+	case exporter.FormatTable:
+		var opts exporter.TableOptions
+		f, ok := w.(*os.File)
+		tty := ok && isTerminal(f)
+		if tty {
+			opts.Width = terminalWidth(f)
+			if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+				opts.Width = n
+			}
+		}
+		_, noColor := os.LookupEnv("NO_COLOR")
+		opts.Color = o.color == "always" || o.color == "auto" && tty && !noColor
+		return exporter.WriteTable(w, res, opts)
+	}
+	return exporter.Write(w, format, res)
+}
+
+// checkOutFormat fails early on an -out-format that cannot be written, and
+// on one that needs a file when there is no -out.
+func checkOutFormat(outFile, format string) error {
+	if err := exporter.CheckFormat(format); err != nil {
+		return fmt.Errorf("invalid -out-format: %w", err)
+	}
+	if outFile == "" && strings.EqualFold(format, exporter.FormatSQLite) {
+		return fmt.Errorf("invalid -out-format: %w: %s needs -out", exporter.ErrFileRequired, format)
+	}
+	return nil
+}
+
+// logOptions configure the default slog logger; see setupLogging.
+type logOptions struct {
+	level  string
+	format string
+	quiet  bool
+}
+
+func (o *logOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.level, "log-level", "info", "Log level: debug, info, warn or error (debug adds phase timings and sampled rejected rows)")
+	fs.StringVar(&o.format, "log-format", "text", "Log format on stderr: text or json")
+	fs.BoolVar(&o.quiet, "quiet", false, "Only log errors and hide the progress bar (overrides -log-level)")
+}
+
+func (o *logOptions) setup(e *env) error {
+	return setupLogging(e.stderr, o.level, o.format, o.quiet)
+}
+
+func debugEnabled() bool {
+	return slog.Default().Enabled(context.Background(), slog.LevelDebug)
+}
+
+// statInput fails early if the input does not exist or is a directory.
+func statInput(path string) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		slog.Error("cannot access input file", "path", path, "error", err)
+		return nil, err
+	}
+	if info.IsDir() {
+		slog.Error("input path is a directory, expected a file", "path", path)
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	return info, nil
+}
+
+// openOutput returns stdout for an empty path and a newly created file
+// otherwise. The returned close must always be called; its error matters for
+// files.
+func (e *env) openOutput(path string) (io.Writer, func() error, error) {
+	if path == "" {
+		return e.stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// importInput runs the import with a progress bar on an interactive stderr and
+// stops it on SIGINT/SIGTERM. On failure it logs the error and returns the
+// exit code for it together with the partial result.
+func (e *env) importInput(cfg customerimporter.Config, quiet bool) (customerimporter.Result, int, error) {
+	// First Ctrl-C stops the import and reports what was counted so far;
+	// stop() restores the default handler so a second one kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return e.importInputContext(ctx, cfg, quiet)
+}
+
+// importInputContext is importInput for callers that handle signals
+// themselves.
+func (e *env) importInputContext(ctx context.Context, cfg customerimporter.Config, quiet bool) (customerimporter.Result, int, error) {
+	if f, ok := e.stderr.(*os.File); ok && !quiet && isTerminal(f) {
+		bar := &progressBar{w: f}
+		cfg.Progress = bar.update
+	}
+
+	result, err := customerimporter.New(cfg).ImportDomainDataContext(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			slog.Warn("interrupted, results are partial and were not written",
+				"file", cfg.Path,
+				"total_rows", result.Stats.TotalRows,
+				"bad_rows", result.Stats.BadRows,
+				"unique_domains", result.Stats.UniqueDomains,
+			)
+			return result, exitInterrupted, err
+		}
+		slog.Error("failed to import", "file", cfg.Path, "error", err)
+		return result, importExitCode(err), err
+	}
+	return result, exitOK, nil
+}
+
+// importExitCode maps an import error to an exit code. Errors caused by flag
+// values count as usage errors; everything else is blamed on the input.
+func importExitCode(err error) int {
+	switch {
+	case errors.Is(err, customerimporter.ErrEmailHeaderMissing),
+		errors.Is(err, customerimporter.ErrEmailColumnMissing),
+		errors.Is(err, customerimporter.ErrEmailColumnType):
+		return exitHeader
+	case errors.Is(err, customerimporter.ErrUnsupportedFormat),
+		errors.Is(err, customerimporter.ErrUnsupportedEncoding),
+		errors.Is(err, customerimporter.ErrInvalidJSONPath),
+		errors.Is(err, customerimporter.ErrQueryMissing),
+		errors.Is(err, customerimporter.ErrStateUnsupported):
+		return exitUsage
+	}
+	return exitInput
+}
+
+// logBadRows logs the malformed rows the import skipped and, at debug level,
+// the sampled rejected rows.
+func logBadRows(res customerimporter.Result) {
+	// Only the first customerimporter.MaxRecordedRowErrors are kept; the
+	// summary's malformed_rows has the full count.
+	for _, re := range res.RowErrors {
+		slog.Warn("malformed row", "error", &re)
+	}
+	for _, rj := range res.Rejected {
+		slog.Debug("rejected row", "row", rj.Row, "email", rj.Email, "reason", rj.Reason)
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+const progressBarWidth = 30
+
+// isTerminal reports whether f is a character device, i.e. an interactive terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// progressBar redraws a single status line on w; the final report clears it so
+// the summary log starts on a clean line.
+type progressBar struct {
+	w io.Writer
+}
+
+func (b *progressBar) update(p customerimporter.Progress) {
+	if p.Done {
+		fmt.Fprint(b.w, "\r\033[K")
+		return
+	}
+
+	if p.TotalBytes <= 0 {
+		fmt.Fprintf(b.w, "\r\033[K%d rows  %.0f rows/s", p.Rows, p.RowsPerSecond())
+		return
+	}
+
+	frac := float64(p.BytesRead) / float64(p.TotalBytes)
+	if frac > 1 {
+		frac = 1
+	}
+	filled := int(frac * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	fmt.Fprintf(b.w, "\r\033[K[%s] %5.1f%%  %s / %s  %d rows  %s/s",
+		bar, frac*100, humanBytes(float64(p.BytesRead)), humanBytes(float64(p.TotalBytes)),
+		p.Rows, humanBytes(p.BytesPerSecond()))
+}
+
+func humanBytes(n float64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	suffixes := "KMGTPE"
+	i := 0
+	for n /= unit; n >= unit && i < len(suffixes)-1; i++ {
+		n /= unit
+	}
+	return fmt.Sprintf("%.1f %ciB", n, suffixes[i])
+}
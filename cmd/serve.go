@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/daveteshome/email-domain-counter/countpb"
+	"github.com/daveteshome/email-domain-counter/grpcserver"
+	"github.com/daveteshome/email-domain-counter/metrics"
+	"github.com/daveteshome/email-domain-counter/server"
+)
+
+// shutdownTimeout is how long running requests get to finish after a signal.
+const shutdownTimeout = 30 * time.Second
+
+var serveCommand = &command{
+	name:     "serve",
+	synopsis: "[-addr=<host:port>] [flags]",
+	summary:  "Count uploads over HTTP, synchronously or as background jobs",
+	help: `POST /count takes the customer file as the raw request body or as the file
+part of a multipart/form-data form and answers with the counts as CSV, JSON
+or Parquet, as requested by the Accept header (CSV if it accepts anything).
+The stats are returned in X-Total-Rows, X-Bad-Rows, X-Malformed-Rows and
+X-Unique-Domains headers.
+
+The input flags below are defaults. A request overrides them with query
+parameters or form fields named like the flags (email-header, format, sheet,
+strict, ...), except query, which only -query sets; form fields must come
+before the file part. The input format is taken from format, else the file
+name (the part's file name or a filename parameter), else the Content-Type.
+
+Errors are JSON, {"error": {"code": "...", "message": "..."}}: 400 for invalid
+options, 413 above -max-body, 415 for unsupported formats, 422 when the input
+is rejected (e.g. email_header_missing, malformed_row with its line,
+bad_rows_exceeded) and 503 when -max-concurrent imports are running.
+
+With -jobs-dir, POST /jobs accepts the same uploads but answers 202 at once
+with a job ID. GET /jobs/{id} shows the job's state and progress (bytes and
+rows read, bad rows) and, once it succeeded, its stats; the result is at
+GET /jobs/{id}/result in the Accept format and DELETE /jobs/{id} removes the
+job. Uploads, job state and results live in the directory, so queued and
+interrupted jobs run again after a restart. At most -job-queue jobs wait;
+further submissions get 503. Finished jobs are deleted after -job-retention.
+
+GET / serves a web UI: pick a file and its email column, then see the stats,
+a chart of the largest domains, a long-tail summary and a sortable table of
+all domains, and download the result as CSV, JSON or Parquet. -ui=false
+turns it off for API-only deployments.
+
+With -metrics, GET /metrics serves Prometheus metrics of the counts and jobs
+run since the start: runs by outcome, rows, bad rows by reason, bytes read and
+time per phase, plus the unique domains of the last run and, with
+-metrics-top-domains, the customers of its largest domains.
+
+With -grpc-addr, the edc.v1.DomainCounter gRPC service (countpb/count.proto)
+listens there too: Count takes the input as a client stream of byte chunks
+or single email addresses, with the options in the first message, and
+WatchProgress streams the progress of a Count by its id. -max-body caps the
+input of a Count and -max-concurrent the Counts running at once, besides the
+HTTP imports; beyond either a Count fails with ResourceExhausted.
+
+SIGINT or SIGTERM stops accepting requests and lets running ones finish;
+running jobs are interrupted and resume on the next start.
+
+Examples:
+  {prog} serve -addr :8080 -max-body 500000000
+  curl --data-binary @customers.csv localhost:8080/count
+  curl -F email-header=mail -F file=@customers.xlsx -H "Accept: application/json" localhost:8080/count
+
+  {prog} serve -jobs-dir /var/lib/edc/jobs -job-retention 72h
+  curl -i -X POST -T huge.csv localhost:8080/jobs   # 202, Location: /jobs/<id>
+  curl localhost:8080/jobs/<id>
+  curl -o result.parquet -H "Accept: application/vnd.apache.parquet" localhost:8080/jobs/<id>/result
+`,
+	setup: func(fs *flag.FlagSet) func(*env, []string) int {
+		o := &serveOptions{}
+		fs.StringVar(&o.addr, "addr", "localhost:8080", "Address to listen on")
+		fs.StringVar(&o.grpcAddr, "grpc-addr", "", "Optional: also serve the gRPC API on this address")
+		fs.Int64Var(&o.maxBody, "max-body", server.DefaultMaxBodyBytes, "Largest accepted request body in bytes (-1 disables the limit)")
+		fs.IntVar(&o.maxConcurrent, "max-concurrent", server.DefaultMaxConcurrent, "Imports running at once; further requests get 503")
+		fs.StringVar(&o.jobsDir, "jobs-dir", "", "Optional: directory for asynchronous jobs; enables POST /jobs")
+		fs.IntVar(&o.jobQueue, "job-queue", server.DefaultJobQueue, "Jobs that may wait to run; further submissions get 503")
+		fs.IntVar(&o.jobWorkers, "job-workers", 1, "Jobs running at once")
+		fs.DurationVar(&o.jobRetention, "job-retention", server.DefaultJobRetention, "How long finished jobs and their results are kept")
+		fs.BoolVar(&o.ui, "ui", true, "Serve the web UI at GET /")
+		fs.BoolVar(&o.metrics, "metrics", false, "Serve Prometheus metrics at GET /metrics")
+		fs.IntVar(&o.metricsTop, "metrics-top-domains", 0, "Add a metric per domain for this many largest domains of the last run")
+		o.input.register(fs)
+		o.malformed.register(fs)
+		o.threshold.register(fs, -1)
+		o.log.register(fs)
+		return func(e *env, _ []string) int { return o.run(e) }
+	},
+}
+
+type serveOptions struct {
+	addr          string
+	grpcAddr      string
+	maxBody       int64
+	maxConcurrent int
+	jobsDir       string
+	jobQueue      int
+	jobWorkers    int
+	jobRetention  time.Duration
+	ui            bool
+	metrics       bool
+	metricsTop    int
+	input         inputOptions
+	malformed     malformedOptions
+	threshold     thresholdOptions
+	log           logOptions
+}
+
+func (o *serveOptions) run(e *env) int {
+	if err := o.log.setup(e); err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	cfg, err := o.config()
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+
+	s := server.New(cfg)
+	if err := s.Start(); err != nil {
+		slog.Error("cannot start jobs", "jobs_dir", o.jobsDir, "error", err)
+		return exitFatal
+	}
+	defer s.Stop()
+
+	ln, err := net.Listen("tcp", o.addr)
+	if err != nil {
+		slog.Error("cannot listen", "addr", o.addr, "error", err)
+		return exitFatal
+	}
+	if o.grpcAddr != "" {
+		gln, err := net.Listen("tcp", o.grpcAddr)
+		if err != nil {
+			ln.Close()
+			slog.Error("cannot listen", "grpc_addr", o.grpcAddr, "error", err)
+			return exitFatal
+		}
+		gs := grpc.NewServer()
+		countpb.RegisterDomainCounterServer(gs, grpcserver.New(grpcserver.Config{
+			Import:        cfg.Import,
+			MaxBadRows:    cfg.MaxBadRows,
+			MaxBadRatio:   cfg.MaxBadRatio,
+			MaxBytes:      cfg.MaxBodyBytes,
+			MaxConcurrent: cfg.MaxConcurrent,
+			Metrics:       cfg.Metrics,
+		}))
+		defer serveGRPC(gln, gs)()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return serve(ctx, ln, s)
+}
+
+// serveGRPC runs gs on ln in the background. The returned function stops it,
+// letting running calls finish for up to shutdownTimeout.
+func serveGRPC(ln net.Listener, gs *grpc.Server) func() {
+	go func() {
+		if err := gs.Serve(ln); err != nil {
+			slog.Error("grpc server failed", "error", err)
+		}
+	}()
+	slog.Info("listening", "grpc_addr", ln.Addr().String())
+	return func() {
+		done := make(chan struct{})
+		go func() {
+			gs.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout):
+			slog.Error("grpc shutdown incomplete")
+			gs.Stop()
+		}
+	}
+}
+
+func (o *serveOptions) config() (server.Config, error) {
+	if o.maxBody == 0 || o.maxConcurrent <= 0 {
+		return server.Config{}, errors.New("-max-body must not be 0 and -max-concurrent must be positive")
+	}
+	if o.jobQueue <= 0 || o.jobWorkers <= 0 || o.jobRetention <= 0 {
+		return server.Config{}, errors.New("-job-queue, -job-workers and -job-retention must be positive")
+	}
+	imp, err := o.input.config("")
+	if err != nil {
+		return server.Config{}, err
+	}
+	if imp.Malformed, err = o.malformed.policy(); err != nil {
+		return server.Config{}, err
+	}
+	var reg *metrics.Registry
+	if o.metrics {
+		reg = metrics.NewRegistry(o.metricsTop)
+	}
+	return server.Config{
+		Import:        imp,
+		MaxBadRows:    o.threshold.maxBadRows,
+		MaxBadRatio:   o.threshold.maxBadRatio,
+		MaxBodyBytes:  o.maxBody,
+		MaxConcurrent: o.maxConcurrent,
+		Version:       version(),
+		JobsDir:       o.jobsDir,
+		JobQueue:      o.jobQueue,
+		JobWorkers:    o.jobWorkers,
+		JobRetention:  o.jobRetention,
+		Metrics:       reg,
+		UI:            o.ui,
+	}, nil
+}
+
+// serve runs h on ln until ctx is done, then shuts down gracefully.
+func serve(ctx context.Context, ln net.Listener, h http.Handler) int {
+	srv := &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	slog.Info("listening", "addr", ln.Addr().String())
+
+	select {
+	case err := <-errc:
+		slog.Error("server failed", "error", err)
+		return exitFatal
+	case <-ctx.Done():
+	}
+	slog.Info("shutting down, waiting for running requests", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("shutdown incomplete", "error", err)
+		return exitFatal
+	}
+	slog.Info("stopped")
+	return exitOK
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/server"
+)
+
+func TestServe(t *testing.T) {
+	o := &serveOptions{maxBody: 1 << 20, maxConcurrent: 1, jobQueue: 1, jobWorkers: 1, jobRetention: time.Hour, metrics: true, ui: true}
+	o.input.register(flag.NewFlagSet("serve", flag.ContinueOnError))
+	cfg, err := o.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int, 1)
+	go func() { done <- serve(ctx, ln, server.New(cfg)) }()
+
+	url := "http://" + ln.Addr().String()
+	resp, err := http.Post(url+"/count?email-header=mail", "text/csv", strings.NewReader("mail\na@x.com\nb@x.com\nc@y.com\n"))
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := "domain,number_of_customers\nx.com,2\ny.com,1\n"; resp.StatusCode != http.StatusOK || string(body) != want {
+		t.Errorf("POST /count = %d %q, want 200 %q", resp.StatusCode, body, want)
+	}
+
+	resp, err = http.Get(url + "/metrics")
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "edc_rows_total 3\n") {
+		t.Errorf("GET /metrics = %d %q", resp.StatusCode, body)
+	}
+
+	resp, err = http.Get(url + "/")
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("GET / = %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	cancel()
+	if code := <-done; code != exitOK {
+		t.Fatalf("serve exit code = %d, want %d", code, exitOK)
+	}
+	if _, err := http.Get(url + "/healthz"); err == nil {
+		t.Fatal("server still accepting requests after shutdown")
+	}
+}
+
+func TestRun_ServeUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{"No_concurrency", []string{"serve", "-max-concurrent", "0"}, exitUsage, "-max-concurrent must be positive"},
+		{"Strict_and_tolerant", []string{"serve", "-strict", "-tolerant"}, exitUsage, "mutually exclusive"},
+		{"No_header_without_column", []string{"serve", "-no-header"}, exitUsage, "-no-header requires"},
+		{"No_job_workers", []string{"serve", "-job-workers", "0"}, exitUsage, "must be positive"},
+		{"Bad_address", []string{"serve", "-addr", "256.0.0.1:http"}, exitFatal, "cannot listen"},
+		{"Bad_grpc_address", []string{"serve", "-addr", "127.0.0.1:0", "-grpc-addr", "256.0.0.1:http"}, exitFatal, "cannot listen"},
+	}
+	for _, tt := range tests {
+		code, _, stderr := run(t, tt.args...)
+		if code != tt.code || !strings.Contains(stderr, tt.want) {
+			t.Errorf("[%s] exit code %d, want %d\nstderr:\n%s", tt.name, code, tt.code, stderr)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"text/tabwriter"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+var statsCommand = &command{
+	name:     "stats",
+	synopsis: "-path=<file> [flags]",
+	summary:  "Print row statistics and the largest domains of an input",
+	help: `Examples:
+  # Totals and the ten largest domains
+  {prog} stats -path ./customers.csv
+
+  # Totals only, as JSON
+  {prog} stats -path ./customers.csv -top 0 -json
+`,
+	setup: func(fs *flag.FlagSet) func(*env, []string) int {
+		o := &statsOptions{}
+		fs.StringVar(&o.path, "path", "", "Path to the file with customer data (required)")
+		fs.IntVar(&o.top, "top", 10, "Number of largest domains to list")
+		fs.BoolVar(&o.json, "json", false, "Print the statistics as JSON")
+		o.input.register(fs)
+		o.malformed.register(fs)
+		o.log.register(fs)
+		return func(e *env, _ []string) int { return o.run(e, fs) }
+	},
+}
+
+type statsOptions struct {
+	path      string
+	top       int
+	json      bool
+	input     inputOptions
+	malformed malformedOptions
+	log       logOptions
+}
+
+// domainShare is one of the largest domains with its share of the valid rows.
+type domainShare struct {
+	Domain  string  `json:"domain"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+type statsReport struct {
+	File       string                 `json:"file"`
+	Format     string                 `json:"format"`
+	Stats      customerimporter.Stats `json:"stats"`
+	TopDomains []domainShare          `json:"top_domains"`
+}
+
+func (o *statsOptions) run(e *env, fs *flag.FlagSet) int {
+	if err := o.log.setup(e); err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	if o.path == "" {
+		slog.Error("input is required: pass -path=<file>")
+		fs.Usage()
+		return exitUsage
+	}
+	cfg, err := o.input.config(o.path)
+	if err == nil {
+		cfg.Malformed, err = o.malformed.policy()
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	if _, err := statInput(o.path); err != nil {
+		return exitInput
+	}
+
+	result, code, err := e.importInput(cfg, o.log.quiet)
+	if err != nil {
+		return code
+	}
+	logBadRows(result)
+
+	report := statsReport{File: o.path, Format: result.Format, Stats: result.Stats, TopDomains: []domainShare{}}
+	valid := result.Stats.TotalRows - result.Stats.BadRows
+	for i, d := range result.Data {
+		if i == o.top {
+			break
+		}
+		report.TopDomains = append(report.TopDomains, domainShare{
+			Domain:  d.Domain,
+			Count:   d.CustomerQuantity,
+			Percent: float64(d.CustomerQuantity) * 100 / float64(valid),
+		})
+	}
+
+	if o.json {
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = writeStatsText(e, report)
+	}
+	if err != nil {
+		slog.Error("failed writing stats", "error", err)
+		return exitOutput
+	}
+	return exitOK
+}
+
+func writeStatsText(e *env, r statsReport) error {
+	tw := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "file\t%s\n", r.File)
+	fmt.Fprintf(tw, "format\t%s\n", r.Format)
+	fmt.Fprintf(tw, "total_rows\t%d\n", r.Stats.TotalRows)
+	fmt.Fprintf(tw, "bad_rows\t%d\n", r.Stats.BadRows)
+	fmt.Fprintf(tw, "malformed_rows\t%d\n", r.Stats.MalformedRows)
+	fmt.Fprintf(tw, "unique_domains\t%d\n", r.Stats.UniqueDomains)
+	if len(r.TopDomains) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "domain\tcustomers\tshare")
+		for _, d := range r.TopDomains {
+			fmt.Fprintf(tw, "%s\t%d\t%.1f%%\n", d.Domain, d.Count, d.Percent)
+		}
+	}
+	return tw.Flush()
+}
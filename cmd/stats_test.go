@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	in := mustWriteFile(t, "in.csv", "email\na@x.com\nb@x.com\nc@x.com\nd@y.com\nbad\n")
+
+	code, stdout, stderr := run(t, "stats", "-path", in, "-top", "1")
+	if code != exitOK {
+		t.Fatalf("exit code %d\nstderr:\n%s", code, stderr)
+	}
+	for _, want := range []string{"total_rows      5\n", "bad_rows        1\n", "x.com   3          75.0%\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Fatalf("stats output lacks %q:\n%s", want, stdout)
+		}
+	}
+	if strings.Contains(stdout, "y.com") {
+		t.Fatalf("-top 1 listed more than one domain:\n%s", stdout)
+	}
+
+	_, stdout, _ = run(t, "stats", "-path", in, "-json", "-top", "0")
+	var report statsReport
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("stats -json output is not JSON: %v\n%s", err, stdout)
+	}
+	if report.Format != "csv" || report.Stats.TotalRows != 5 || report.Stats.UniqueDomains != 2 || len(report.TopDomains) != 0 {
+		t.Fatalf("report=%+v", report)
+	}
+}
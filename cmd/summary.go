@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// runSummary is the document written by -summary-json. Its keys match the
+// attributes of the summary log line.
+type runSummary struct {
+	Status      string                  `json:"status"`
+	ExitCode    int                     `json:"exit_code"`
+	Error       string                  `json:"error,omitempty"`
+	StartedAt   time.Time               `json:"started_at"`
+	FinishedAt  time.Time               `json:"finished_at"`
+	DurationMS  int64                   `json:"duration_ms"`
+	Input       inputSummary            `json:"input"`
+	Stats       *customerimporter.Stats `json:"stats,omitempty"`
+	Members     []memberSummary         `json:"members,omitempty"`
+	PhasesMS    map[string]float64      `json:"phases_ms,omitempty"`
+	Incremental *incrementalSummary     `json:"incremental,omitempty"`
+	Options     map[string]any          `json:"options"`
+}
+
+type inputSummary struct {
+	Path       string    `json:"path"`
+	Format     string    `json:"format,omitempty"`
+	SizeBytes  int64     `json:"size_bytes,omitempty"`
+	ModifiedAt time.Time `json:"modified_at,omitzero"`
+}
+
+type incrementalSummary struct {
+	Resumed    bool   `json:"resumed"`
+	FromOffset int64  `json:"from_offset"`
+	ToOffset   int64  `json:"to_offset"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+type memberSummary struct {
+	Name   string                 `json:"name"`
+	Format string                 `json:"format"`
+	Stats  customerimporter.Stats `json:"stats"`
+}
+
+// exitStatus names each exit code in the summary so callers need not keep
+// their own table.
+var exitStatus = map[int]string{
+	exitOK:          "ok",
+	exitFatal:       "error",
+	exitUsage:       "usage_error",
+	exitInput:       "input_error",
+	exitHeader:      "header_missing",
+	exitBadRows:     "bad_rows_exceeded",
+	exitOutput:      "output_error",
+	exitInterrupted: "interrupted",
+}
+
+func newRunSummary(fs *flag.FlagSet, path string) *runSummary {
+	s := &runSummary{
+		StartedAt: time.Now(),
+		Input:     inputSummary{Path: path},
+		Options:   make(map[string]any),
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if g, ok := f.Value.(flag.Getter); ok {
+			s.Options[f.Name] = g.Get()
+		}
+	})
+	return s
+}
+
+func (s *runSummary) setResult(res customerimporter.Result) {
+	s.Input.Format = res.Format
+	s.Stats = &res.Stats
+	for _, m := range res.Members {
+		s.Members = append(s.Members, memberSummary{Name: m.Name, Format: m.Format, Stats: m.Stats})
+	}
+	s.PhasesMS = map[string]float64{
+		"open":  milliseconds(res.Timings.Open),
+		"parse": milliseconds(res.Timings.Parse),
+		"sort":  milliseconds(res.Timings.Sort),
+	}
+}
+
+func (s *runSummary) setIncremental(inc customerimporter.Incremental) {
+	s.Incremental = &incrementalSummary{
+		Resumed:    inc.Resumed,
+		FromOffset: inc.Offset,
+		ToOffset:   inc.End,
+		Reason:     inc.Reason,
+	}
+}
+
+func (s *runSummary) setExport(d time.Duration) {
+	s.PhasesMS["export"] = milliseconds(d)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// write stamps the outcome and writes the summary to path.
+func (s *runSummary) write(path string, code int, err error) error {
+	s.FinishedAt = time.Now()
+	s.DurationMS = s.FinishedAt.Sub(s.StartedAt).Milliseconds()
+	s.ExitCode = code
+	s.Status = exitStatus[code]
+	if err != nil {
+		s.Error = err.Error()
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
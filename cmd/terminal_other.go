@@ -0,0 +1,8 @@
+//go:build !linux
+
+package cmd
+
+import "os"
+
+// terminalWidth is only known on Linux; elsewhere set COLUMNS.
+func terminalWidth(*os.File) int { return 0 }
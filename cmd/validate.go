@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+var validateCommand = &command{
+	name:     "validate",
+	synopsis: "-path=<file> [flags]",
+	summary:  "Check an input and list its bad rows without writing results",
+	help: `Malformed records are skipped and listed with their line (and column for
+CSV parse errors); records without a valid email are listed with their record
+number. By default any bad row fails the run with exit code 5; raise
+-max-bad-rows or set -max-bad-ratio to tolerate some.
+
+Examples:
+  # Gate a nightly import on a clean file
+  {prog} validate -path ./customers.csv
+
+  # Allow up to 0.5% bad rows, list at most 10
+  {prog} validate -path ./customers.csv -max-bad-rows=-1 -max-bad-ratio=0.005 -limit 10
+`,
+	setup: func(fs *flag.FlagSet) func(*env, []string) int {
+		o := &validateOptions{}
+		fs.StringVar(&o.path, "path", "", "Path to the file with customer data (required)")
+		fs.IntVar(&o.limit, "limit", customerimporter.MaxRecordedRowErrors, fmt.Sprintf("List at most this many malformed and this many rejected rows; only the first %d malformed rows are recorded", customerimporter.MaxRecordedRowErrors))
+		o.input.register(fs)
+		o.threshold.register(fs, 0)
+		o.log.register(fs)
+		return func(e *env, _ []string) int { return o.run(e, fs) }
+	},
+}
+
+type validateOptions struct {
+	path      string
+	limit     int
+	input     inputOptions
+	threshold thresholdOptions
+	log       logOptions
+}
+
+func (o *validateOptions) run(e *env, fs *flag.FlagSet) int {
+	if err := o.log.setup(e); err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	if o.limit < 0 {
+		slog.Error("-limit must not be negative")
+		return exitUsage
+	}
+	if o.path == "" {
+		slog.Error("input is required: pass -path=<file>")
+		fs.Usage()
+		return exitUsage
+	}
+	cfg, err := o.input.config(o.path)
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	if _, err := statInput(o.path); err != nil {
+		return exitInput
+	}
+
+	cfg.Malformed = customerimporter.MalformedSkip
+	cfg.RejectedSample = o.limit
+	result, code, err := e.importInput(cfg, o.log.quiet)
+	if err != nil {
+		return code
+	}
+
+	if err := writeBadRows(e, result, o.limit); err != nil {
+		slog.Error("failed writing report", "error", err)
+		return exitOutput
+	}
+
+	slog.Info("validate",
+		"file", o.path,
+		"total_rows", result.Stats.TotalRows,
+		"bad_rows", result.Stats.BadRows,
+		"malformed_rows", result.Stats.MalformedRows,
+	)
+	if err := customerimporter.CheckBadRows(result.Stats, o.threshold.maxBadRows, o.threshold.maxBadRatio); err != nil {
+		slog.Error("validation failed", "file", o.path, "error", err)
+		return exitBadRows
+	}
+	return exitOK
+}
+
+// writeBadRows lists up to limit malformed and limit rejected rows on stdout,
+// one per line, and notes how many more there were.
+func writeBadRows(e *env, res customerimporter.Result, limit int) error {
+	bw := bufio.NewWriter(e.stdout)
+	for i, re := range res.RowErrors {
+		if i == limit {
+			break
+		}
+		fmt.Fprintf(bw, "malformed  %v\n", &re)
+	}
+	for _, rj := range res.Rejected {
+		fmt.Fprintf(bw, "rejected   row %d: %s %q\n", rj.Row, rj.Reason, rj.Email)
+	}
+	listed := min(len(res.RowErrors), limit) + len(res.Rejected)
+	if more := res.Stats.BadRows - listed; more > 0 {
+		fmt.Fprintf(bw, "... and %d more bad rows\n", more)
+	}
+	return bw.Flush()
+}
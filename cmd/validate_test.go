@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	in := mustWriteFile(t, "in.csv", "name,email\n"+
+		"A,a@x.com\n"+
+		"B\"ad,b@x.com\n"+
+		"C,not-an-email\n"+
+		"D,d@y.com\n")
+
+	code, stdout, stderr := run(t, "validate", "-path", in)
+	if code != exitBadRows {
+		t.Fatalf("exit code %d, want %d\nstderr:\n%s", code, exitBadRows, stderr)
+	}
+	want := "malformed  line 3, column 2: bare \" in non-quoted-field\n" +
+		"rejected   row 3: invalid address \"not-an-email\"\n"
+	if stdout != want {
+		t.Fatalf("report:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	code, _, stderr = run(t, "validate", "-path", in, "-max-bad-rows", "2")
+	if code != exitOK || !strings.Contains(stderr, "bad_rows=2 malformed_rows=1") {
+		t.Fatalf("within threshold: code=%d stderr:\n%s", code, stderr)
+	}
+}
+
+func TestValidate_LimitNotesTheRest(t *testing.T) {
+	in := mustWriteFile(t, "in.csv", "email\nx\ny\nz\na@x.com\n")
+
+	_, stdout, _ := run(t, "validate", "-path", in, "-limit", "1")
+	want := "rejected   row 1: invalid address \"x\"\n... and 2 more bad rows\n"
+	if stdout != want {
+		t.Fatalf("report:\n%s\nwant:\n%s", stdout, want)
+	}
+}
+
+func TestValidate_NegativeLimit(t *testing.T) {
+	in := mustWriteFile(t, "in.csv", "email\nx\n")
+
+	code, stdout, stderr := run(t, "validate", "-path", in, "-limit", "-1")
+	if code != exitUsage || stdout != "" || !strings.Contains(stderr, "-limit must not be negative") {
+		t.Fatalf("code=%d stdout=%q stderr:\n%s", code, stdout, stderr)
+	}
+}
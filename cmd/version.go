@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is the release version, set at build time with
+// -ldflags "-X github.com/daveteshome/email-domain-counter/cmd.Version=v1.2.3".
+// Without it the module version from the build info is used.
+var Version = "dev"
+
+var versionCommand = &command{
+	name:     "version",
+	synopsis: "",
+	summary:  "Print the version and build information",
+	setup: func(fs *flag.FlagSet) func(*env, []string) int {
+		return func(e *env, _ []string) int {
+			fmt.Fprintf(e.stdout, "%s %s\n", e.prog, version())
+			return exitOK
+		}
+	},
+}
+
+// version reports Version (or the module version) with the Go version and,
+// when built from a checkout, the VCS revision.
+func version() string {
+	v := Version
+	revision, modified := "", false
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+	}
+
+	if revision == "" {
+		return fmt.Sprintf("%s (%s)", v, runtime.Version())
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return fmt.Sprintf("%s (%s, revision %s)", v, runtime.Version(), revision)
+}
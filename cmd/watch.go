@@ -0,0 +1,365 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/exporter"
+)
+
+var watchCommand = &command{
+	name:     "watch",
+	synopsis: "-path=<file|dir> -out=<file> [flags]",
+	summary:  "Keep counting an input file or directory and rewrite the output when it changes",
+	help: `The output is written once at start and again after every change, each
+time to a temporary file that is renamed over -out, so readers never see a
+partial result. Changes closer together than -debounce are handled as one run.
+
+A file is watched through its directory, so it may be replaced or appear
+later. With -state only the lines appended since the last run are read.
+
+For a directory, every file with a recognised input extension (or matching
+-pattern) is counted and the counts are summed. Files that did not change
+since the last run are not read again. Hidden files, -out and -state are
+ignored. A file that fails to import is logged and left out of the output
+until it changes; the output is only kept as it was if every file fails.
+
+Changes are picked up with inotify on Linux and by polling the directory
+elsewhere, or with -poll (e.g. on network file systems).
+
+Examples:
+  {prog} watch -path ./customers.csv -out ./dashboard/result.json -state ./customers.state.json
+  {prog} watch -path ./incoming -pattern "*.csv" -out ./dashboard/result.csv
+`,
+	setup: func(fs *flag.FlagSet) func(*env, []string) int {
+		o := &watchOptions{}
+		fs.StringVar(&o.path, "path", "", "File or directory to watch (required)")
+		fs.StringVar(&o.outFile, "out", "", "Output file, replaced atomically on every run (required)")
+		fs.StringVar(&o.outFormat, "out-format", "", "Output format: csv, json, parquet, sqlite, table, markdown or html (detected from -out extension if empty)")
+		fs.StringVar(&o.pattern, "pattern", "", "Glob selecting the files of a watched directory, e.g. *.csv (recognised input extensions if empty)")
+		fs.StringVar(&o.state, "state", "", "Optional: checkpoint file, so a watched CSV that grows at the end is counted incrementally")
+		fs.DurationVar(&o.debounce, "debounce", 500*time.Millisecond, "Wait this long after the last change before counting")
+		fs.BoolVar(&o.poll, "poll", false, "Poll for changes instead of using file notifications")
+		fs.DurationVar(&o.pollInterval, "poll-interval", 2*time.Second, "How often to poll with -poll or where notifications are unavailable")
+		o.input.register(fs)
+		o.malformed.register(fs)
+		o.threshold.register(fs, -1)
+		o.log.register(fs)
+		return func(e *env, _ []string) int { return o.run(e, fs) }
+	},
+}
+
+type watchOptions struct {
+	path         string
+	outFile      string
+	outFormat    string
+	pattern      string
+	state        string
+	debounce     time.Duration
+	poll         bool
+	pollInterval time.Duration
+	input        inputOptions
+	malformed    malformedOptions
+	threshold    thresholdOptions
+	log          logOptions
+
+	policy customerimporter.MalformedPolicy
+	isDir  bool
+	// cache holds the last result of each file of a watched directory.
+	cache map[string]watchedFile
+}
+
+// watchedFile is a directory member's result and the file version it is for.
+type watchedFile struct {
+	stamp fileStamp
+	res   customerimporter.Result
+}
+
+func (o *watchOptions) run(e *env, fs *flag.FlagSet) int {
+	if err := o.log.setup(e); err != nil {
+		slog.Error(err.Error())
+		return exitUsage
+	}
+	if code, ok := o.validate(fs); !ok {
+		return code
+	}
+
+	// Ctrl-C or SIGTERM ends the watch; a run in progress is abandoned and
+	// leaves the previous output in place.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dir := o.path
+	if !o.isDir {
+		dir = filepath.Dir(o.path)
+	}
+	w := newWatcher(dir, o.poll, o.pollInterval)
+	defer w.Close()
+	return o.watch(ctx, e, w)
+}
+
+// validate checks the flags and resolves whether -path is a directory.
+func (o *watchOptions) validate(fs *flag.FlagSet) (int, bool) {
+	if o.path == "" || o.outFile == "" {
+		slog.Error("watch needs -path=<file|dir> and -out=<file>")
+		fs.Usage()
+		return exitUsage, false
+	}
+	if _, err := o.input.config(o.path); err != nil {
+		slog.Error(err.Error())
+		return exitUsage, false
+	}
+	if err := checkOutFormat(o.outFile, o.outFormat); err != nil {
+		slog.Error(err.Error())
+		return exitUsage, false
+	}
+	policy, err := o.malformed.policy()
+	if err != nil {
+		slog.Error(err.Error())
+		return exitUsage, false
+	}
+	o.policy = policy
+	if o.debounce < 0 || o.pollInterval <= 0 {
+		slog.Error("-debounce must not be negative and -poll-interval must be positive")
+		return exitUsage, false
+	}
+	if o.pattern != "" {
+		if _, err := filepath.Match(o.pattern, ""); err != nil {
+			slog.Error("invalid -pattern", "pattern", o.pattern, "error", err)
+			return exitUsage, false
+		}
+	}
+
+	o.path = filepath.Clean(o.path)
+	if info, err := os.Stat(o.path); err == nil && info.IsDir() {
+		o.isDir = true
+		o.cache = make(map[string]watchedFile)
+		if o.state != "" {
+			slog.Error("-state is only supported when watching a single file")
+			return exitUsage, false
+		}
+	} else if _, err := os.Stat(filepath.Dir(o.path)); err != nil {
+		// A missing file is fine, it may appear later; its directory must exist.
+		slog.Error("cannot watch input", "path", o.path, "error", err)
+		return exitInput, false
+	}
+	return exitOK, true
+}
+
+// watch counts once, then again each time the input settles after a change,
+// until ctx is done.
+func (o *watchOptions) watch(ctx context.Context, e *env, w watcher) int {
+	runs := 1
+	o.runOnce(ctx, e, runs, "start")
+
+	debounce := time.NewTimer(o.debounce)
+	debounce.Stop()
+	trigger := ""
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("watch stopped", "path", o.path, "runs", runs)
+			return exitOK
+		case err := <-w.Errors():
+			// The watcher has stopped, e.g. the directory was removed.
+			slog.Error("watch failed", "path", o.path, "error", err)
+			return exitInput
+		case name := <-w.Events():
+			if !o.relevant(name) {
+				continue
+			}
+			slog.Debug("change", "file", name)
+			if trigger == "" {
+				trigger = name
+			}
+			debounce.Reset(o.debounce)
+		case <-debounce.C:
+			runs++
+			o.runOnce(ctx, e, runs, trigger)
+			trigger = ""
+		}
+	}
+}
+
+// relevant reports whether a change to path can change the output. The
+// watched directory itself stands for "rescan everything".
+func (o *watchOptions) relevant(path string) bool {
+	if !o.isDir {
+		return path == o.path || path == filepath.Dir(o.path)
+	}
+	return path == o.path || o.isInput(path)
+}
+
+// isInput reports whether path in a watched directory is counted.
+func (o *watchOptions) isInput(path string) bool {
+	base := filepath.Base(path)
+	if strings.HasPrefix(base, ".") || samePath(path, o.outFile) || samePath(path, o.state) {
+		return false
+	}
+	if o.pattern != "" {
+		ok, _ := filepath.Match(o.pattern, base)
+		return ok
+	}
+	if o.input.format != "" {
+		return true
+	}
+	_, ok := customerimporter.FormatForPath(base)
+	return ok
+}
+
+func samePath(a, b string) bool {
+	if b == "" {
+		return false
+	}
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
+
+// runOnce counts the input and replaces the output. Failures are logged and
+// leave the previous output in place; the next change retries. Bad rows are
+// logged when a file is read, not again for cached results.
+func (o *watchOptions) runOnce(ctx context.Context, e *env, n int, trigger string) {
+	start := time.Now()
+	var (
+		res customerimporter.Result
+		err error
+	)
+	if o.isDir {
+		res, err = o.countDir(ctx, e)
+	} else {
+		res, err = o.countFile(ctx, e)
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("run failed, output not updated", "run", n, "trigger", trigger, "error", err)
+		}
+		return
+	}
+
+	if err := customerimporter.CheckBadRows(res.Stats, o.threshold.maxBadRows, o.threshold.maxBadRatio); err != nil {
+		slog.Error("too many bad rows, output not updated", "run", n, "error", err)
+		return
+	}
+	exp := exporter.NewCustomerExporter(o.outFile).WithFormat(o.outFormat).WithSource(o.path).Atomic()
+	if err := exp.ExportResult(res); err != nil {
+		slog.Error("failed writing file, output not updated", "run", n, "out", o.outFile, "error", err)
+		return
+	}
+
+	for _, m := range res.Members {
+		slog.Info("member",
+			"name", m.Name,
+			"format", m.Format,
+			"total_rows", m.Stats.TotalRows,
+			"bad_rows", m.Stats.BadRows,
+			"unique_domains", m.Stats.UniqueDomains,
+		)
+	}
+	slog.Info("summary",
+		"run", n,
+		"trigger", trigger,
+		"file", o.path,
+		"total_rows", res.Stats.TotalRows,
+		"bad_rows", res.Stats.BadRows,
+		"malformed_rows", res.Stats.MalformedRows,
+		"unique_domains", res.Stats.UniqueDomains,
+		"duration", time.Since(start),
+	)
+}
+
+func (o *watchOptions) importConfig(path string) customerimporter.Config {
+	// The flag combination was checked by validate.
+	cfg, _ := o.input.config(path)
+	cfg.Malformed = o.policy
+	return cfg
+}
+
+func (o *watchOptions) countFile(ctx context.Context, e *env) (customerimporter.Result, error) {
+	if _, err := statInput(o.path); err != nil {
+		return customerimporter.Result{}, err
+	}
+	cfg := o.importConfig(o.path)
+	cfg.StatePath = o.state
+	res, _, err := e.importInputContext(ctx, cfg, true)
+	if err != nil {
+		return res, err
+	}
+	logBadRows(res)
+	if o.state != "" {
+		logIncremental(o.state, res.Incremental)
+	}
+	return res, nil
+}
+
+// countDir counts the inputs of a watched directory, reusing the results of
+// files whose size and modification time did not change. A file that fails
+// to import is logged and left out until it changes; the run only fails if
+// every input did.
+func (o *watchOptions) countDir(ctx context.Context, e *env) (customerimporter.Result, error) {
+	entries, err := os.ReadDir(o.path)
+	if err != nil {
+		return customerimporter.Result{}, err
+	}
+
+	seen := make(map[string]bool, len(entries))
+	var failed []string
+	var results []customerimporter.Result
+	var members []customerimporter.MemberStats
+	for _, entry := range entries {
+		path := filepath.Join(o.path, entry.Name())
+		if !entry.Type().IsRegular() || !o.isInput(path) {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return customerimporter.Result{}, err
+		}
+		stamp := fileStamp{size: info.Size(), modTime: info.ModTime()}
+		seen[path] = true
+
+		cached, ok := o.cache[path]
+		if !ok || cached.stamp != stamp {
+			res, _, err := e.importInputContext(ctx, o.importConfig(path), true)
+			if ctx.Err() != nil {
+				return customerimporter.Result{}, ctx.Err()
+			}
+			if err != nil {
+				slog.Warn("file left out of the output", "file", path)
+				delete(o.cache, path)
+				failed = append(failed, entry.Name())
+				continue
+			}
+			logBadRows(res)
+			cached = watchedFile{stamp: stamp, res: res}
+			o.cache[path] = cached
+		}
+		results = append(results, cached.res)
+		members = append(members, customerimporter.MemberStats{Name: entry.Name(), Format: cached.res.Format, Stats: cached.res.Stats})
+	}
+	for path := range o.cache {
+		if !seen[path] {
+			delete(o.cache, path)
+		}
+	}
+
+	if len(results) == 0 && len(failed) > 0 {
+		return customerimporter.Result{}, fmt.Errorf("no input could be imported: %s", strings.Join(failed, ", "))
+	}
+	merged := customerimporter.Merge(results...)
+	merged.Members = members
+	return merged, nil
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeWatcher lets a test deliver change events by hand.
+type fakeWatcher struct {
+	events chan string
+	errors chan error
+}
+
+func newFakeWatcher() *fakeWatcher {
+	return &fakeWatcher{events: make(chan string), errors: make(chan error, 1)}
+}
+
+func (w *fakeWatcher) Events() <-chan string { return w.events }
+func (w *fakeWatcher) Errors() <-chan error  { return w.errors }
+func (w *fakeWatcher) Close() error          { return nil }
+
+// startWatch runs o.watch until the test ends and returns the exit code channel.
+func startWatch(t *testing.T, o *watchOptions, w watcher) (context.CancelFunc, <-chan int) {
+	t.Helper()
+	fs := flagSetFor(t, watchCommand)
+	if code, ok := o.validate(fs); !ok {
+		t.Fatalf("validate: exit code %d", code)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int, 1)
+	e := &env{prog: "edc", stdout: io.Discard, stderr: io.Discard}
+	finished := make(chan struct{})
+	go func() {
+		done <- o.watch(ctx, e, w)
+		close(finished)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-finished
+	})
+	return cancel, done
+}
+
+func flagSetFor(t *testing.T, c *command) *flag.FlagSet {
+	t.Helper()
+	e := &env{prog: "edc", stdout: io.Discard, stderr: io.Discard}
+	fs, _, _ := e.newFlagSet(c)
+	return fs
+}
+
+// waitFile waits until path holds want.
+func waitFile(t *testing.T, path, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := os.ReadFile(path)
+		if string(b) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s holds %q, want %q", path, b, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatch_File(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "customers.csv")
+	if err := os.WriteFile(in, []byte("email\na@x.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "result.csv")
+	o := &watchOptions{
+		path:         in,
+		outFile:      out,
+		state:        filepath.Join(dir, "customers.state.json"),
+		debounce:     10 * time.Millisecond,
+		pollInterval: time.Second,
+		input:        inputOptions{emailHeader: "email", encoding: "auto"},
+		threshold:    thresholdOptions{maxBadRows: -1, maxBadRatio: -1},
+	}
+	w := newFakeWatcher()
+	cancel, done := startWatch(t, o, w)
+	waitFile(t, out, "domain,number_of_customers\nx.com,1\n")
+
+	// Unrelated files do not trigger a run.
+	w.events <- filepath.Join(dir, "notes.txt")
+
+	appendTo(t, in, "b@y.com\nc@x.com\n")
+	w.events <- in
+	w.events <- in
+	waitFile(t, out, "domain,number_of_customers\nx.com,2\ny.com,1\n")
+
+	cancel()
+	if code := <-done; code != exitOK {
+		t.Fatalf("exit code %d after cancel, want %d", code, exitOK)
+	}
+}
+
+func TestWatch_Directory(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "result.csv")
+	north := filepath.Join(dir, "north.csv")
+	if err := os.WriteFile(north, []byte("email\na@x.com\nb@y.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.txt"), []byte("not counted"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	o := &watchOptions{
+		path:         dir,
+		outFile:      out,
+		debounce:     10 * time.Millisecond,
+		pollInterval: time.Second,
+		input:        inputOptions{emailHeader: "email", encoding: "auto"},
+		threshold:    thresholdOptions{maxBadRows: -1, maxBadRatio: -1},
+	}
+	w := newFakeWatcher()
+	startWatch(t, o, w)
+	waitFile(t, out, "domain,number_of_customers\nx.com,1\ny.com,1\n")
+
+	if o.relevant(out) || o.relevant(filepath.Join(dir, ".result.csv.123.tmp")) || o.relevant(filepath.Join(dir, "README.txt")) {
+		t.Fatal("output, temporary or unrecognised files must not trigger runs")
+	}
+
+	south := filepath.Join(dir, "south.ndjson")
+	if err := os.WriteFile(south, []byte(`{"email":"c@x.com"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.events <- south
+	waitFile(t, out, "domain,number_of_customers\nx.com,2\ny.com,1\n")
+
+	if err := os.Remove(north); err != nil {
+		t.Fatal(err)
+	}
+	w.events <- north
+	waitFile(t, out, "domain,number_of_customers\nx.com,1\n")
+}
+
+func TestWatch_KeepsOutputOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "customers.csv")
+	if err := os.WriteFile(in, []byte("email\na@x.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "result.csv")
+	o := &watchOptions{
+		path:         in,
+		outFile:      out,
+		debounce:     10 * time.Millisecond,
+		pollInterval: time.Second,
+		input:        inputOptions{emailHeader: "email", encoding: "auto"},
+		threshold:    thresholdOptions{maxBadRows: -1, maxBadRatio: -1},
+	}
+	w := newFakeWatcher()
+	_, done := startWatch(t, o, w)
+	const first = "domain,number_of_customers\nx.com,1\n"
+	waitFile(t, out, first)
+
+	if err := os.WriteFile(in, []byte("id\n1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.events <- in
+	time.Sleep(100 * time.Millisecond)
+	waitFile(t, out, first)
+
+	// The watch carries on after a failed run.
+	if err := os.WriteFile(in, []byte("email\nb@y.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.events <- in
+	waitFile(t, out, "domain,number_of_customers\ny.com,1\n")
+
+	w.errors <- os.ErrClosed
+	if code := <-done; code != exitInput {
+		t.Fatalf("exit code %d after a watcher error, want %d", code, exitInput)
+	}
+}
+
+// TestWatch_DirectorySkipsFailingFile checks that one unreadable file does not
+// hold back the output of the others in the directory.
+func TestWatch_DirectorySkipsFailingFile(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "result.csv")
+	north := filepath.Join(dir, "north.csv")
+	south := filepath.Join(dir, "south.csv")
+	if err := os.WriteFile(north, []byte("email\na@x.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(south, []byte("id\n1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	o := &watchOptions{
+		path:         dir,
+		outFile:      out,
+		debounce:     10 * time.Millisecond,
+		pollInterval: time.Second,
+		input:        inputOptions{emailHeader: "email", encoding: "auto"},
+		threshold:    thresholdOptions{maxBadRows: -1, maxBadRatio: -1},
+	}
+	w := newFakeWatcher()
+	startWatch(t, o, w)
+	waitFile(t, out, "domain,number_of_customers\nx.com,1\n")
+
+	// Fixing the file brings it in.
+	if err := os.WriteFile(south, []byte("email\nb@y.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.events <- south
+	waitFile(t, out, "domain,number_of_customers\nx.com,1\ny.com,1\n")
+
+	// With every input failing, the output is kept.
+	for _, path := range []string{north, south} {
+		if err := os.WriteFile(path, []byte("id\n2\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.events <- south
+	time.Sleep(100 * time.Millisecond)
+	waitFile(t, out, "domain,number_of_customers\nx.com,1\ny.com,1\n")
+}
+
+func TestRun_WatchUsage(t *testing.T) {
+	in := mustWriteFile(t, "in.csv", "email\n")
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"No_out", []string{"watch", "-path", in}, "-out=<file>"},
+		{"State_with_directory", []string{"watch", "-path", filepath.Dir(in), "-out", "x.csv", "-state", "s.json"}, "single file"},
+		{"Bad_pattern", []string{"watch", "-path", in, "-out", "x.csv", "-pattern", "["}, "invalid -pattern"},
+	}
+	for _, tt := range tests {
+		code, _, stderr := run(t, tt.args...)
+		if code != exitUsage || !strings.Contains(stderr, tt.want) {
+			t.Errorf("[%s] exit code %d\nstderr:\n%s", tt.name, code, stderr)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A watcher reports changes in one directory: files created, written,
+// renamed or removed. Events carry the changed file's path, or the directory
+// itself when the watcher lost track and everything should be rescanned.
+// Errors delivers an error only when the watcher has stopped for good;
+// transient failures are logged and retried by the watcher itself.
+type watcher interface {
+	Events() <-chan string
+	Errors() <-chan error
+	Close() error
+}
+
+// newWatcher watches dir with the platform's notification API, or by polling
+// every interval when poll is set or the API is unavailable.
+func newWatcher(dir string, poll bool, interval time.Duration) watcher {
+	if !poll {
+		w, err := newNativeWatcher(dir)
+		if err == nil {
+			slog.Debug("watching", "dir", dir, "method", "inotify")
+			return w
+		}
+		if errors.Is(err, errors.ErrUnsupported) {
+			slog.Debug("file notifications unavailable, polling", "dir", dir, "interval", interval)
+		} else {
+			slog.Warn("file notifications failed, polling instead", "dir", dir, "interval", interval, "error", err)
+		}
+	}
+	return newPollWatcher(dir, interval)
+}
+
+// fileStamp is what the poll watcher compares between scans.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// pollWatcher rescans a directory on a timer. It works on any file system,
+// including network mounts that do not deliver inotify events.
+type pollWatcher struct {
+	dir    string
+	events chan string
+	errors chan error
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newPollWatcher(dir string, interval time.Duration) *pollWatcher {
+	w := &pollWatcher{
+		dir:    dir,
+		events: make(chan string),
+		errors: make(chan error, 1),
+		done:   make(chan struct{}),
+	}
+	prev, _ := scanDir(dir)
+	go w.loop(prev, interval)
+	return w
+}
+
+func (w *pollWatcher) Events() <-chan string { return w.events }
+func (w *pollWatcher) Errors() <-chan error  { return w.errors }
+
+func (w *pollWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+func (w *pollWatcher) loop(prev map[string]fileStamp, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	failing := false
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-t.C:
+		}
+
+		cur, err := scanDir(w.dir)
+		if err != nil {
+			// The directory may be being replaced; warn once and keep trying.
+			if !failing {
+				slog.Warn("cannot scan watched directory, retrying", "dir", w.dir, "error", err)
+				failing = true
+			}
+			continue
+		}
+		if failing {
+			slog.Info("watched directory is readable again", "dir", w.dir)
+			failing = false
+		}
+		var changed []string
+		for name, st := range cur {
+			if old, ok := prev[name]; !ok || old != st {
+				changed = append(changed, name)
+			}
+		}
+		for name := range prev {
+			if _, ok := cur[name]; !ok {
+				changed = append(changed, name)
+			}
+		}
+		prev = cur
+		for _, name := range changed {
+			select {
+			case w.events <- filepath.Join(w.dir, name):
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func scanDir(dir string) (map[string]fileStamp, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	stamps := make(map[string]fileStamp, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || info.IsDir() {
+			continue
+		}
+		stamps[e.Name()] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+	}
+	return stamps, nil
+}
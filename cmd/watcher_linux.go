@@ -0,0 +1,107 @@
+//go:build linux
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// inotifyMask selects the events that can change an input. IN_CLOSE_WRITE and
+// IN_MOVED_TO mark finished writes; IN_MODIFY also catches files that are
+// appended to and kept open.
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyWatcher reads inotify events for one directory. The descriptor is
+// non-blocking and wrapped in an *os.File, so reads go through the runtime
+// poller and Close interrupts a pending read.
+type inotifyWatcher struct {
+	dir    string
+	f      *os.File
+	events chan string
+	errors chan error
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newNativeWatcher(dir string) (watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("watch %s: %w", dir, os.NewSyscallError("inotify_add_watch", err))
+	}
+	w := &inotifyWatcher{
+		dir:    dir,
+		f:      os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan string),
+		errors: make(chan error, 1),
+		done:   make(chan struct{}),
+	}
+	go w.read()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Events() <-chan string { return w.events }
+func (w *inotifyWatcher) Errors() <-chan error  { return w.errors }
+
+func (w *inotifyWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.f.Close()
+	})
+	return err
+}
+
+func (w *inotifyWatcher) read() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.fail(fmt.Errorf("read inotify events: %w", err))
+			}
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			// struct inotify_event: wd, mask, cookie, len, then len bytes of
+			// NUL-padded name.
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+nameLen]
+			off += syscall.SizeofInotifyEvent + nameLen
+
+			if mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+				w.fail(fmt.Errorf("watched directory %s was removed or moved", w.dir))
+				return
+			}
+			path := w.dir
+			if mask&syscall.IN_Q_OVERFLOW == 0 && len(name) > 0 {
+				path = filepath.Join(w.dir, string(bytes.TrimRight(name, "\x00")))
+			}
+			select {
+			case w.events <- path:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *inotifyWatcher) fail(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+)
+
+// newNativeWatcher has no implementation outside Linux; watch polls instead.
+func newNativeWatcher(dir string) (watcher, error) {
+	return nil, fmt.Errorf("watch %s: %w", dir, errors.ErrUnsupported)
+}
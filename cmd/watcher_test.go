@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitEvent waits for an event for want, skipping events for other files.
+func waitEvent(t *testing.T, w watcher, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-w.Events():
+			if got == want {
+				return
+			}
+		case err := <-w.Errors():
+			t.Fatalf("watcher error: %v", err)
+		case <-timeout:
+			t.Fatalf("no event for %s", want)
+		}
+	}
+}
+
+func testWatcher(t *testing.T, dir string, w watcher) {
+	t.Helper()
+	defer w.Close()
+
+	path := filepath.Join(dir, "in.csv")
+	if err := os.WriteFile(path, []byte("email\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, path)
+
+	// Replaced by rename, the way editors and exporters save.
+	tmp := filepath.Join(dir, ".in.csv.tmp")
+	if err := os.WriteFile(tmp, []byte("email\na@x.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, path)
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, path)
+}
+
+func TestPollWatcher(t *testing.T) {
+	dir := t.TempDir()
+	testWatcher(t, dir, newPollWatcher(dir, 10*time.Millisecond))
+}
+
+// TestPollWatcher_DirectoryReplaced checks that a directory that is briefly
+// missing, as when it is replaced, does not stop the watcher.
+func TestPollWatcher_DirectoryReplaced(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "in")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	w := newPollWatcher(dir, 10*time.Millisecond)
+	defer w.Close()
+
+	if err := os.Rename(dir, dir+".old"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "in.csv")
+	if err := os.WriteFile(path, []byte("email\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, w, path)
+}
+
+func TestNativeWatcher(t *testing.T) {
+	dir := t.TempDir()
+	w, err := newNativeWatcher(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("no native watcher on this platform")
+	}
+	if err != nil {
+		t.Fatalf("newNativeWatcher: %v", err)
+	}
+	testWatcher(t, dir, w)
+}
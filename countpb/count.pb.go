@@ -0,0 +1,785 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: count.proto
+
+package countpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Malformed says what happens to rows that cannot be parsed.
+type Malformed int32
+
+const (
+	// Count them as bad rows.
+	Malformed_MALFORMED_COUNT Malformed = 0
+	// Fail the count on the first one.
+	Malformed_MALFORMED_STRICT Malformed = 1
+	// Skip them, including unparsable CSV records, and count them as bad.
+	Malformed_MALFORMED_SKIP Malformed = 2
+)
+
+// Enum value maps for Malformed.
+var (
+	Malformed_name = map[int32]string{
+		0: "MALFORMED_COUNT",
+		1: "MALFORMED_STRICT",
+		2: "MALFORMED_SKIP",
+	}
+	Malformed_value = map[string]int32{
+		"MALFORMED_COUNT":  0,
+		"MALFORMED_STRICT": 1,
+		"MALFORMED_SKIP":   2,
+	}
+)
+
+func (x Malformed) Enum() *Malformed {
+	p := new(Malformed)
+	*p = x
+	return p
+}
+
+func (x Malformed) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Malformed) Descriptor() protoreflect.EnumDescriptor {
+	return file_count_proto_enumTypes[0].Descriptor()
+}
+
+func (Malformed) Type() protoreflect.EnumType {
+	return &file_count_proto_enumTypes[0]
+}
+
+func (x Malformed) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Malformed.Descriptor instead.
+func (Malformed) EnumDescriptor() ([]byte, []int) {
+	return file_count_proto_rawDescGZIP(), []int{0}
+}
+
+type CountRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*CountRequest_Options
+	//	*CountRequest_Chunk
+	//	*CountRequest_Email
+	Payload       isCountRequest_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountRequest) Reset() {
+	*x = CountRequest{}
+	mi := &file_count_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountRequest) ProtoMessage() {}
+
+func (x *CountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_count_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountRequest.ProtoReflect.Descriptor instead.
+func (*CountRequest) Descriptor() ([]byte, []int) {
+	return file_count_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CountRequest) GetPayload() isCountRequest_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *CountRequest) GetOptions() *CountOptions {
+	if x != nil {
+		if x, ok := x.Payload.(*CountRequest_Options); ok {
+			return x.Options
+		}
+	}
+	return nil
+}
+
+func (x *CountRequest) GetChunk() []byte {
+	if x != nil {
+		if x, ok := x.Payload.(*CountRequest_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+func (x *CountRequest) GetEmail() string {
+	if x != nil {
+		if x, ok := x.Payload.(*CountRequest_Email); ok {
+			return x.Email
+		}
+	}
+	return ""
+}
+
+type isCountRequest_Payload interface {
+	isCountRequest_Payload()
+}
+
+type CountRequest_Options struct {
+	Options *CountOptions `protobuf:"bytes,1,opt,name=options,proto3,oneof"`
+}
+
+type CountRequest_Chunk struct {
+	// Next bytes of the input file, in order.
+	Chunk []byte `protobuf:"bytes,2,opt,name=chunk,proto3,oneof"`
+}
+
+type CountRequest_Email struct {
+	// One email address; the addresses are counted as a one-column CSV.
+	Email string `protobuf:"bytes,3,opt,name=email,proto3,oneof"`
+}
+
+func (*CountRequest_Options) isCountRequest_Payload() {}
+
+func (*CountRequest_Chunk) isCountRequest_Payload() {}
+
+func (*CountRequest_Email) isCountRequest_Payload() {}
+
+// CountOptions mirror the input flags of the count command. Unset fields
+// keep the server's defaults.
+type CountOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Optional: lets WatchProgress follow this count. Must be unique among
+	// running counts.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Name of the streamed file; its extension selects the input format when
+	// format is empty.
+	Filename string `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	// Input format: csv, xlsx, json, ndjson, parquet, sqlite, mbox, vcard,
+	// ldif, zip, tar or tar.gz.
+	Format      string `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`
+	EmailHeader string `protobuf:"bytes,4,opt,name=email_header,json=emailHeader,proto3" json:"email_header,omitempty"`
+	// 1-based email column position; overrides email_header.
+	EmailColumn int32  `protobuf:"varint,5,opt,name=email_column,json=emailColumn,proto3" json:"email_column,omitempty"`
+	NoHeader    bool   `protobuf:"varint,6,opt,name=no_header,json=noHeader,proto3" json:"no_header,omitempty"`
+	Encoding    string `protobuf:"bytes,7,opt,name=encoding,proto3" json:"encoding,omitempty"`
+	Sheet       string `protobuf:"bytes,8,opt,name=sheet,proto3" json:"sheet,omitempty"`
+	EmailPath   string `protobuf:"bytes,9,opt,name=email_path,json=emailPath,proto3" json:"email_path,omitempty"`
+	// Rejected with InvalidArgument when set: a query could ATTACH other
+	// databases on the server, so SQLite input uses the server's -query.
+	Query                  string    `protobuf:"bytes,10,opt,name=query,proto3" json:"query,omitempty"`
+	MboxHeaders            []string  `protobuf:"bytes,11,rep,name=mbox_headers,json=mboxHeaders,proto3" json:"mbox_headers,omitempty"`
+	Members                string    `protobuf:"bytes,12,opt,name=members,proto3" json:"members,omitempty"`
+	Malformed              Malformed `protobuf:"varint,13,opt,name=malformed,proto3,enum=edc.v1.Malformed" json:"malformed,omitempty"`
+	AllowSingleLabelDomain bool      `protobuf:"varint,14,opt,name=allow_single_label_domain,json=allowSingleLabelDomain,proto3" json:"allow_single_label_domain,omitempty"`
+	// Expected input size in bytes, reported as Progress.total_bytes.
+	SizeBytes     int64 `protobuf:"varint,15,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountOptions) Reset() {
+	*x = CountOptions{}
+	mi := &file_count_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountOptions) ProtoMessage() {}
+
+func (x *CountOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_count_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountOptions.ProtoReflect.Descriptor instead.
+func (*CountOptions) Descriptor() ([]byte, []int) {
+	return file_count_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CountOptions) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CountOptions) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *CountOptions) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *CountOptions) GetEmailHeader() string {
+	if x != nil {
+		return x.EmailHeader
+	}
+	return ""
+}
+
+func (x *CountOptions) GetEmailColumn() int32 {
+	if x != nil {
+		return x.EmailColumn
+	}
+	return 0
+}
+
+func (x *CountOptions) GetNoHeader() bool {
+	if x != nil {
+		return x.NoHeader
+	}
+	return false
+}
+
+func (x *CountOptions) GetEncoding() string {
+	if x != nil {
+		return x.Encoding
+	}
+	return ""
+}
+
+func (x *CountOptions) GetSheet() string {
+	if x != nil {
+		return x.Sheet
+	}
+	return ""
+}
+
+func (x *CountOptions) GetEmailPath() string {
+	if x != nil {
+		return x.EmailPath
+	}
+	return ""
+}
+
+func (x *CountOptions) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *CountOptions) GetMboxHeaders() []string {
+	if x != nil {
+		return x.MboxHeaders
+	}
+	return nil
+}
+
+func (x *CountOptions) GetMembers() string {
+	if x != nil {
+		return x.Members
+	}
+	return ""
+}
+
+func (x *CountOptions) GetMalformed() Malformed {
+	if x != nil {
+		return x.Malformed
+	}
+	return Malformed_MALFORMED_COUNT
+}
+
+func (x *CountOptions) GetAllowSingleLabelDomain() bool {
+	if x != nil {
+		return x.AllowSingleLabelDomain
+	}
+	return false
+}
+
+func (x *CountOptions) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+type CountResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Sorted by customers descending, then domain ascending.
+	Domains       []*DomainData `protobuf:"bytes,1,rep,name=domains,proto3" json:"domains,omitempty"`
+	Stats         *Stats        `protobuf:"bytes,2,opt,name=stats,proto3" json:"stats,omitempty"`
+	InputFormat   string        `protobuf:"bytes,3,opt,name=input_format,json=inputFormat,proto3" json:"input_format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountResponse) Reset() {
+	*x = CountResponse{}
+	mi := &file_count_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountResponse) ProtoMessage() {}
+
+func (x *CountResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_count_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountResponse.ProtoReflect.Descriptor instead.
+func (*CountResponse) Descriptor() ([]byte, []int) {
+	return file_count_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CountResponse) GetDomains() []*DomainData {
+	if x != nil {
+		return x.Domains
+	}
+	return nil
+}
+
+func (x *CountResponse) GetStats() *Stats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *CountResponse) GetInputFormat() string {
+	if x != nil {
+		return x.InputFormat
+	}
+	return ""
+}
+
+type DomainData struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Customers     int64                  `protobuf:"varint,2,opt,name=customers,proto3" json:"customers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DomainData) Reset() {
+	*x = DomainData{}
+	mi := &file_count_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DomainData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DomainData) ProtoMessage() {}
+
+func (x *DomainData) ProtoReflect() protoreflect.Message {
+	mi := &file_count_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DomainData.ProtoReflect.Descriptor instead.
+func (*DomainData) Descriptor() ([]byte, []int) {
+	return file_count_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DomainData) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *DomainData) GetCustomers() int64 {
+	if x != nil {
+		return x.Customers
+	}
+	return 0
+}
+
+type Stats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TotalRows     int64                  `protobuf:"varint,1,opt,name=total_rows,json=totalRows,proto3" json:"total_rows,omitempty"`
+	BadRows       int64                  `protobuf:"varint,2,opt,name=bad_rows,json=badRows,proto3" json:"bad_rows,omitempty"`
+	MalformedRows int64                  `protobuf:"varint,3,opt,name=malformed_rows,json=malformedRows,proto3" json:"malformed_rows,omitempty"`
+	UniqueDomains int64                  `protobuf:"varint,4,opt,name=unique_domains,json=uniqueDomains,proto3" json:"unique_domains,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_count_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_count_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_count_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Stats) GetTotalRows() int64 {
+	if x != nil {
+		return x.TotalRows
+	}
+	return 0
+}
+
+func (x *Stats) GetBadRows() int64 {
+	if x != nil {
+		return x.BadRows
+	}
+	return 0
+}
+
+func (x *Stats) GetMalformedRows() int64 {
+	if x != nil {
+		return x.MalformedRows
+	}
+	return 0
+}
+
+func (x *Stats) GetUniqueDomains() int64 {
+	if x != nil {
+		return x.UniqueDomains
+	}
+	return 0
+}
+
+type WatchProgressRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchProgressRequest) Reset() {
+	*x = WatchProgressRequest{}
+	mi := &file_count_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchProgressRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchProgressRequest) ProtoMessage() {}
+
+func (x *WatchProgressRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_count_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchProgressRequest.ProtoReflect.Descriptor instead.
+func (*WatchProgressRequest) Descriptor() ([]byte, []int) {
+	return file_count_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WatchProgressRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type Progress struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	BytesRead int64                  `protobuf:"varint,1,opt,name=bytes_read,json=bytesRead,proto3" json:"bytes_read,omitempty"`
+	// Zero unless the count's options set size_bytes.
+	TotalBytes     int64   `protobuf:"varint,2,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	Rows           int64   `protobuf:"varint,3,opt,name=rows,proto3" json:"rows,omitempty"`
+	BadRows        int64   `protobuf:"varint,4,opt,name=bad_rows,json=badRows,proto3" json:"bad_rows,omitempty"`
+	ElapsedSeconds float64 `protobuf:"fixed64,5,opt,name=elapsed_seconds,json=elapsedSeconds,proto3" json:"elapsed_seconds,omitempty"`
+	Done           bool    `protobuf:"varint,6,opt,name=done,proto3" json:"done,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Progress) Reset() {
+	*x = Progress{}
+	mi := &file_count_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Progress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Progress) ProtoMessage() {}
+
+func (x *Progress) ProtoReflect() protoreflect.Message {
+	mi := &file_count_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Progress.ProtoReflect.Descriptor instead.
+func (*Progress) Descriptor() ([]byte, []int) {
+	return file_count_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Progress) GetBytesRead() int64 {
+	if x != nil {
+		return x.BytesRead
+	}
+	return 0
+}
+
+func (x *Progress) GetTotalBytes() int64 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+func (x *Progress) GetRows() int64 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *Progress) GetBadRows() int64 {
+	if x != nil {
+		return x.BadRows
+	}
+	return 0
+}
+
+func (x *Progress) GetElapsedSeconds() float64 {
+	if x != nil {
+		return x.ElapsedSeconds
+	}
+	return 0
+}
+
+func (x *Progress) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+var File_count_proto protoreflect.FileDescriptor
+
+const file_count_proto_rawDesc = "" +
+	"\n" +
+	"\vcount.proto\x12\x06edc.v1\"{\n" +
+	"\fCountRequest\x120\n" +
+	"\aoptions\x18\x01 \x01(\v2\x14.edc.v1.CountOptionsH\x00R\aoptions\x12\x16\n" +
+	"\x05chunk\x18\x02 \x01(\fH\x00R\x05chunk\x12\x16\n" +
+	"\x05email\x18\x03 \x01(\tH\x00R\x05emailB\t\n" +
+	"\apayload\"\xe4\x03\n" +
+	"\fCountOptions\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x12\x16\n" +
+	"\x06format\x18\x03 \x01(\tR\x06format\x12!\n" +
+	"\femail_header\x18\x04 \x01(\tR\vemailHeader\x12!\n" +
+	"\femail_column\x18\x05 \x01(\x05R\vemailColumn\x12\x1b\n" +
+	"\tno_header\x18\x06 \x01(\bR\bnoHeader\x12\x1a\n" +
+	"\bencoding\x18\a \x01(\tR\bencoding\x12\x14\n" +
+	"\x05sheet\x18\b \x01(\tR\x05sheet\x12\x1d\n" +
+	"\n" +
+	"email_path\x18\t \x01(\tR\temailPath\x12\x14\n" +
+	"\x05query\x18\n" +
+	" \x01(\tR\x05query\x12!\n" +
+	"\fmbox_headers\x18\v \x03(\tR\vmboxHeaders\x12\x18\n" +
+	"\amembers\x18\f \x01(\tR\amembers\x12/\n" +
+	"\tmalformed\x18\r \x01(\x0e2\x11.edc.v1.MalformedR\tmalformed\x129\n" +
+	"\x19allow_single_label_domain\x18\x0e \x01(\bR\x16allowSingleLabelDomain\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x0f \x01(\x03R\tsizeBytes\"\x85\x01\n" +
+	"\rCountResponse\x12,\n" +
+	"\adomains\x18\x01 \x03(\v2\x12.edc.v1.DomainDataR\adomains\x12#\n" +
+	"\x05stats\x18\x02 \x01(\v2\r.edc.v1.StatsR\x05stats\x12!\n" +
+	"\finput_format\x18\x03 \x01(\tR\vinputFormat\"B\n" +
+	"\n" +
+	"DomainData\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x1c\n" +
+	"\tcustomers\x18\x02 \x01(\x03R\tcustomers\"\x8f\x01\n" +
+	"\x05Stats\x12\x1d\n" +
+	"\n" +
+	"total_rows\x18\x01 \x01(\x03R\ttotalRows\x12\x19\n" +
+	"\bbad_rows\x18\x02 \x01(\x03R\abadRows\x12%\n" +
+	"\x0emalformed_rows\x18\x03 \x01(\x03R\rmalformedRows\x12%\n" +
+	"\x0eunique_domains\x18\x04 \x01(\x03R\runiqueDomains\"&\n" +
+	"\x14WatchProgressRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xb6\x01\n" +
+	"\bProgress\x12\x1d\n" +
+	"\n" +
+	"bytes_read\x18\x01 \x01(\x03R\tbytesRead\x12\x1f\n" +
+	"\vtotal_bytes\x18\x02 \x01(\x03R\n" +
+	"totalBytes\x12\x12\n" +
+	"\x04rows\x18\x03 \x01(\x03R\x04rows\x12\x19\n" +
+	"\bbad_rows\x18\x04 \x01(\x03R\abadRows\x12'\n" +
+	"\x0felapsed_seconds\x18\x05 \x01(\x01R\x0eelapsedSeconds\x12\x12\n" +
+	"\x04done\x18\x06 \x01(\bR\x04done*J\n" +
+	"\tMalformed\x12\x13\n" +
+	"\x0fMALFORMED_COUNT\x10\x00\x12\x14\n" +
+	"\x10MALFORMED_STRICT\x10\x01\x12\x12\n" +
+	"\x0eMALFORMED_SKIP\x10\x022\x8a\x01\n" +
+	"\rDomainCounter\x126\n" +
+	"\x05Count\x12\x14.edc.v1.CountRequest\x1a\x15.edc.v1.CountResponse(\x01\x12A\n" +
+	"\rWatchProgress\x12\x1c.edc.v1.WatchProgressRequest\x1a\x10.edc.v1.Progress0\x01B5Z3github.com/daveteshome/email-domain-counter/countpbb\x06proto3"
+
+var (
+	file_count_proto_rawDescOnce sync.Once
+	file_count_proto_rawDescData []byte
+)
+
+func file_count_proto_rawDescGZIP() []byte {
+	file_count_proto_rawDescOnce.Do(func() {
+		file_count_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_count_proto_rawDesc), len(file_count_proto_rawDesc)))
+	})
+	return file_count_proto_rawDescData
+}
+
+var file_count_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_count_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_count_proto_goTypes = []any{
+	(Malformed)(0),               // 0: edc.v1.Malformed
+	(*CountRequest)(nil),         // 1: edc.v1.CountRequest
+	(*CountOptions)(nil),         // 2: edc.v1.CountOptions
+	(*CountResponse)(nil),        // 3: edc.v1.CountResponse
+	(*DomainData)(nil),           // 4: edc.v1.DomainData
+	(*Stats)(nil),                // 5: edc.v1.Stats
+	(*WatchProgressRequest)(nil), // 6: edc.v1.WatchProgressRequest
+	(*Progress)(nil),             // 7: edc.v1.Progress
+}
+var file_count_proto_depIdxs = []int32{
+	2, // 0: edc.v1.CountRequest.options:type_name -> edc.v1.CountOptions
+	0, // 1: edc.v1.CountOptions.malformed:type_name -> edc.v1.Malformed
+	4, // 2: edc.v1.CountResponse.domains:type_name -> edc.v1.DomainData
+	5, // 3: edc.v1.CountResponse.stats:type_name -> edc.v1.Stats
+	1, // 4: edc.v1.DomainCounter.Count:input_type -> edc.v1.CountRequest
+	6, // 5: edc.v1.DomainCounter.WatchProgress:input_type -> edc.v1.WatchProgressRequest
+	3, // 6: edc.v1.DomainCounter.Count:output_type -> edc.v1.CountResponse
+	7, // 7: edc.v1.DomainCounter.WatchProgress:output_type -> edc.v1.Progress
+	6, // [6:8] is the sub-list for method output_type
+	4, // [4:6] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_count_proto_init() }
+func file_count_proto_init() {
+	if File_count_proto != nil {
+		return
+	}
+	file_count_proto_msgTypes[0].OneofWrappers = []any{
+		(*CountRequest_Options)(nil),
+		(*CountRequest_Chunk)(nil),
+		(*CountRequest_Email)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_count_proto_rawDesc), len(file_count_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_count_proto_goTypes,
+		DependencyIndexes: file_count_proto_depIdxs,
+		EnumInfos:         file_count_proto_enumTypes,
+		MessageInfos:      file_count_proto_msgTypes,
+	}.Build()
+	File_count_proto = out.File
+	file_count_proto_goTypes = nil
+	file_count_proto_depIdxs = nil
+}
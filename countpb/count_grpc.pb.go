@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: count.proto
+
+package countpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DomainCounter_Count_FullMethodName         = "/edc.v1.DomainCounter/Count"
+	DomainCounter_WatchProgress_FullMethodName = "/edc.v1.DomainCounter/WatchProgress"
+)
+
+// DomainCounterClient is the client API for DomainCounter service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// DomainCounter counts customers per email domain.
+type DomainCounterClient interface {
+	// Count reads customer data streamed by the client and answers with the
+	// per-domain counts once the client closes the stream. The first message
+	// must carry the options; the following ones carry either raw input bytes
+	// or single email addresses, not both.
+	Count(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[CountRequest, CountResponse], error)
+	// WatchProgress streams the progress of the Count whose options carry id,
+	// waiting for it to start if needed. The last message has done set; if the
+	// count failed the stream ends with its error instead.
+	WatchProgress(ctx context.Context, in *WatchProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Progress], error)
+}
+
+type domainCounterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDomainCounterClient(cc grpc.ClientConnInterface) DomainCounterClient {
+	return &domainCounterClient{cc}
+}
+
+func (c *domainCounterClient) Count(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[CountRequest, CountResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DomainCounter_ServiceDesc.Streams[0], DomainCounter_Count_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[CountRequest, CountResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DomainCounter_CountClient = grpc.ClientStreamingClient[CountRequest, CountResponse]
+
+func (c *domainCounterClient) WatchProgress(ctx context.Context, in *WatchProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Progress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DomainCounter_ServiceDesc.Streams[1], DomainCounter_WatchProgress_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchProgressRequest, Progress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DomainCounter_WatchProgressClient = grpc.ServerStreamingClient[Progress]
+
+// DomainCounterServer is the server API for DomainCounter service.
+// All implementations must embed UnimplementedDomainCounterServer
+// for forward compatibility.
+//
+// DomainCounter counts customers per email domain.
+type DomainCounterServer interface {
+	// Count reads customer data streamed by the client and answers with the
+	// per-domain counts once the client closes the stream. The first message
+	// must carry the options; the following ones carry either raw input bytes
+	// or single email addresses, not both.
+	Count(grpc.ClientStreamingServer[CountRequest, CountResponse]) error
+	// WatchProgress streams the progress of the Count whose options carry id,
+	// waiting for it to start if needed. The last message has done set; if the
+	// count failed the stream ends with its error instead.
+	WatchProgress(*WatchProgressRequest, grpc.ServerStreamingServer[Progress]) error
+	mustEmbedUnimplementedDomainCounterServer()
+}
+
+// UnimplementedDomainCounterServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDomainCounterServer struct{}
+
+func (UnimplementedDomainCounterServer) Count(grpc.ClientStreamingServer[CountRequest, CountResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Count not implemented")
+}
+func (UnimplementedDomainCounterServer) WatchProgress(*WatchProgressRequest, grpc.ServerStreamingServer[Progress]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchProgress not implemented")
+}
+func (UnimplementedDomainCounterServer) mustEmbedUnimplementedDomainCounterServer() {}
+func (UnimplementedDomainCounterServer) testEmbeddedByValue()                       {}
+
+// UnsafeDomainCounterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DomainCounterServer will
+// result in compilation errors.
+type UnsafeDomainCounterServer interface {
+	mustEmbedUnimplementedDomainCounterServer()
+}
+
+func RegisterDomainCounterServer(s grpc.ServiceRegistrar, srv DomainCounterServer) {
+	// If the following call pancis, it indicates UnimplementedDomainCounterServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DomainCounter_ServiceDesc, srv)
+}
+
+func _DomainCounter_Count_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DomainCounterServer).Count(&grpc.GenericServerStream[CountRequest, CountResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DomainCounter_CountServer = grpc.ClientStreamingServer[CountRequest, CountResponse]
+
+func _DomainCounter_WatchProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchProgressRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DomainCounterServer).WatchProgress(m, &grpc.GenericServerStream[WatchProgressRequest, Progress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DomainCounter_WatchProgressServer = grpc.ServerStreamingServer[Progress]
+
+// DomainCounter_ServiceDesc is the grpc.ServiceDesc for DomainCounter service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DomainCounter_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "edc.v1.DomainCounter",
+	HandlerType: (*DomainCounterServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Count",
+			Handler:       _DomainCounter_Count_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchProgress",
+			Handler:       _DomainCounter_WatchProgress_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "count.proto",
+}
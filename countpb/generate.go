@@ -0,0 +1,5 @@
+// Package countpb is the gRPC API of the domain counter, generated from
+// count.proto; grpcserver implements it.
+package countpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative count.proto
@@ -0,0 +1,168 @@
+package customerimporter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// importArchive counts every selected member of a zip or tar(.gz) archive into
+// counts and records a MemberStats entry for each one. Nested archives are not
+// opened.
+func (i *Importer) importArchive(rn *run, in io.Reader, format string, counts map[string]int, res *Result) error {
+	switch format {
+	case FormatZip:
+		f, ok := in.(inputFile)
+		if !ok {
+			return fmt.Errorf("%w: zip needs a seekable file", ErrUnsupportedFormat)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			return fmt.Errorf("open zip: %w", err)
+		}
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() {
+				continue
+			}
+			memberFormat, ok := i.memberFormat(zf.Name)
+			if !ok {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return fmt.Errorf("open member %q: %w", zf.Name, err)
+			}
+			err = i.importMember(rn, zf.Name, memberFormat, rc, counts, res)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case FormatTar, FormatTarGz:
+		r := in
+		if format == FormatTarGz {
+			gz, err := gzip.NewReader(in)
+			if err != nil {
+				return fmt.Errorf("open gzip: %w", err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read tar: %w", err)
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			memberFormat, ok := i.memberFormat(hdr.Name)
+			if !ok {
+				continue
+			}
+			if err := i.importMember(rn, hdr.Name, memberFormat, tr, counts, res); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+}
+
+// memberFormat reports whether a member is selected and which format to read
+// it with. Without a Members glob only recognised extensions are selected;
+// with one, matching members of unknown type are read as CSV. The AppleDouble
+// files macOS adds to archives (__MACOSX/, ._name) are never selected.
+func (i *Importer) memberFormat(name string) (string, bool) {
+	if appleDouble(name) {
+		return "", false
+	}
+	format, known := FormatForPath(name)
+	switch format {
+	case FormatZip, FormatTar, FormatTarGz:
+		return "", false
+	}
+
+	if i.cfg.Members == "" {
+		return format, known
+	}
+	target := name
+	if !strings.Contains(i.cfg.Members, "/") {
+		target = path.Base(name)
+	}
+	if ok, _ := path.Match(i.cfg.Members, target); !ok {
+		return "", false
+	}
+	if !known {
+		format = FormatCSV
+	}
+	return format, true
+}
+
+// appleDouble reports whether an archive member holds macOS resource fork
+// metadata rather than data: anything under __MACOSX/ and ._ files.
+func appleDouble(name string) bool {
+	return name == "__MACOSX" || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._")
+}
+
+// importMember counts one archive member on its own map so its unique domains
+// can be reported, then folds it into the aggregate counts.
+func (i *Importer) importMember(rn *run, name, format string, r io.Reader, counts map[string]int, res *Result) error {
+	ms := MemberStats{Name: name, Format: format}
+	memberCounts := make(map[string]int, 1024)
+
+	var err error
+	switch format {
+	case FormatXLSX, FormatParquet, FormatSQLite:
+		err = i.importSpooled(rn, name, format, r, memberCounts, &ms.Stats)
+	default:
+		err = i.importFile(rn, r, name, format, memberCounts, &ms.Stats)
+	}
+	// Fold in what was counted even on error, so an interrupted import still
+	// reports the partial member.
+	for d, c := range memberCounts {
+		counts[d] += c
+	}
+	ms.Stats.UniqueDomains = len(memberCounts)
+	res.Stats.TotalRows += ms.Stats.TotalRows
+	res.Stats.BadRows += ms.Stats.BadRows
+	res.Stats.MalformedRows += ms.Stats.MalformedRows
+	res.Members = append(res.Members, ms)
+	if err != nil {
+		return fmt.Errorf("member %q: %w", name, err)
+	}
+	return nil
+}
+
+// importSpooled copies a member that needs random access to a temporary file
+// and reads it from there.
+func (i *Importer) importSpooled(rn *run, name, format string, r io.Reader, counts map[string]int, stats *Stats) error {
+	tmp, err := os.CreateTemp("", "edc-member-*"+path.Ext(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return i.importFile(rn, tmp, tmp.Name(), format, counts, stats)
+}
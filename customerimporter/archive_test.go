@@ -0,0 +1,194 @@
+package customerimporter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type archiveMember struct {
+	name, body string
+}
+
+var archiveMembers = []archiveMember{
+	{"north/customers.csv", "email\na@x.com\nb@x.com\nbad\n"},
+	{"south/customers.csv", "name,email\nC,c@y.com\nD,d@x.com\n"},
+	{"south/events.ndjson", `{"email":"e@z.com"}` + "\n"},
+	{"README.txt", "not data\n"},
+	{"nested.zip", "ignored"},
+}
+
+func mustWriteTempZip(t *testing.T, members []archiveMember) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("north/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		w, err := zw.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(m.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return mustWriteTempFile(t, "bundle.zip", buf.String())
+}
+
+func mustWriteTempTarGz(t *testing.T, members []archiveMember) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "north/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		if err := tw.WriteHeader(&tar.Header{Name: m.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(m.body))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(m.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return mustWriteTempFile(t, "bundle.tar.gz", buf.String())
+}
+
+func memberNames(ms []MemberStats) string {
+	names := make([]string, len(ms))
+	for i, m := range ms {
+		names[i] = m.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestImporter_Archives(t *testing.T) {
+	paths := map[string]string{
+		"zip":    mustWriteTempZip(t, archiveMembers),
+		"tar.gz": mustWriteTempTarGz(t, archiveMembers),
+	}
+
+	for kind, path := range paths {
+		t.Run(kind+"_all_recognised_members", func(t *testing.T) {
+			got, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData()
+			if err != nil {
+				t.Fatalf("ImportDomainData error: %v", err)
+			}
+			if got.Format != kind {
+				t.Fatalf("format=%q, want %q", got.Format, kind)
+			}
+			if got.Stats.TotalRows != 6 || got.Stats.BadRows != 1 || got.Stats.UniqueDomains != 3 {
+				t.Fatalf("stats=%+v", got.Stats)
+			}
+			if got.Data[0] != (DomainData{Domain: "x.com", CustomerQuantity: 3}) {
+				t.Fatalf("data=%v", got.Data)
+			}
+			if names := memberNames(got.Members); names != "north/customers.csv,south/customers.csv,south/events.ndjson" {
+				t.Fatalf("members=%s", names)
+			}
+			north := got.Members[0]
+			if north.Format != FormatCSV || north.Stats != (Stats{TotalRows: 3, BadRows: 1, UniqueDomains: 1}) {
+				t.Fatalf("north member=%+v", north)
+			}
+			south := got.Members[1]
+			if south.Stats != (Stats{TotalRows: 2, BadRows: 0, UniqueDomains: 2}) {
+				t.Fatalf("south member=%+v", south)
+			}
+		})
+
+		t.Run(kind+"_glob_on_base_name", func(t *testing.T) {
+			got, err := New(Config{Path: path, EmailHeader: "email", Members: "*.csv"}).ImportDomainData()
+			if err != nil {
+				t.Fatalf("ImportDomainData error: %v", err)
+			}
+			if names := memberNames(got.Members); names != "north/customers.csv,south/customers.csv" {
+				t.Fatalf("members=%s", names)
+			}
+			if got.Stats.TotalRows != 5 {
+				t.Fatalf("stats=%+v", got.Stats)
+			}
+		})
+
+		t.Run(kind+"_glob_on_full_path", func(t *testing.T) {
+			got, err := New(Config{Path: path, EmailHeader: "email", Members: "south/*"}).ImportDomainData()
+			if err != nil {
+				t.Fatalf("ImportDomainData error: %v", err)
+			}
+			if names := memberNames(got.Members); names != "south/customers.csv,south/events.ndjson" {
+				t.Fatalf("members=%s", names)
+			}
+		})
+	}
+}
+
+// TestImporter_ArchiveSkipsAppleDouble checks that the metadata files of an
+// archive made on a Mac are not read as data.
+func TestImporter_ArchiveSkipsAppleDouble(t *testing.T) {
+	members := []archiveMember{
+		{"customers.csv", "email\na@x.com\n"},
+		{"__MACOSX/._customers.csv", "\x00\x05\x16\x07\x00\x02\x00\x00Mac OS X"},
+		{"__MACOSX/notes.csv", "id\n1\n"},
+		{"._other.csv", "\x00\x05\x16\x07"},
+	}
+	paths := map[string]string{
+		"zip":    mustWriteTempZip(t, members),
+		"tar.gz": mustWriteTempTarGz(t, members),
+	}
+	for kind, path := range paths {
+		for _, glob := range []string{"", "*.csv"} {
+			got, err := New(Config{Path: path, EmailHeader: "email", Members: glob}).ImportDomainData()
+			if err != nil {
+				t.Fatalf("%s, members %q: %v", kind, glob, err)
+			}
+			if names := memberNames(got.Members); names != "customers.csv" || got.Stats.TotalRows != 1 {
+				t.Fatalf("%s, members %q: members=%s stats=%+v", kind, glob, names, got.Stats)
+			}
+		}
+	}
+}
+
+func TestImporter_ArchiveMemberErrorsNameTheMember(t *testing.T) {
+	path := mustWriteTempZip(t, []archiveMember{{"ok.csv", "email\na@x.com\n"}, {"broken.csv", "id\n1\n"}})
+	_, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData()
+	if !errors.Is(err, ErrEmailHeaderMissing) || !strings.Contains(err.Error(), "broken.csv") {
+		t.Fatalf("expected wrapped ErrEmailHeaderMissing naming broken.csv, got %v", err)
+	}
+}
+
+func TestImporter_ArchiveSpoolsRandomAccessMembers(t *testing.T) {
+	xlsx, err := os.ReadFile(mustWriteTempXLSX(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := mustWriteTempZip(t, []archiveMember{{"sales/customers.xlsx", string(xlsx)}})
+
+	got, err := New(Config{Path: path, EmailHeader: "email", Sheet: "Customers"}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	if len(got.Members) != 1 || got.Members[0].Format != FormatXLSX || got.Stats.TotalRows != 4 {
+		t.Fatalf("members=%+v stats=%+v", got.Members, got.Stats)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(os.TempDir(), "edc-member-*"))
+	if len(leftovers) != 0 {
+		t.Fatalf("temporary member files left behind: %v", leftovers)
+	}
+}
@@ -0,0 +1,130 @@
+package customerimporter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrUnsupportedDiffOrder = errors.New("unsupported diff order")
+
+// Diff orders accepted by SortChanges.
+const (
+	DiffOrderMagnitude = "magnitude" // |change| descending
+	DiffOrderChange    = "change"    // signed change descending: biggest gains first
+	DiffOrderPercent   = "percent"   // percent change descending, new domains first
+	DiffOrderDomain    = "domain"    // domain ascending
+)
+
+// Change statuses reported by DomainChange.Status.
+const (
+	ChangeNew         = "new"
+	ChangeDisappeared = "disappeared"
+	ChangeUp          = "up"
+	ChangeDown        = "down"
+	ChangeUnchanged   = "unchanged"
+)
+
+// DomainChange pairs the customer counts of one domain in two results.
+type DomainChange struct {
+	Domain   string
+	Previous int
+	Current  int
+}
+
+// Delta is the absolute change, Current - Previous.
+func (c DomainChange) Delta() int {
+	return c.Current - c.Previous
+}
+
+// Percent is the change relative to Previous, in percent. ok is false for new
+// domains, which have no previous count to compare with.
+func (c DomainChange) Percent() (pct float64, ok bool) {
+	if c.Previous == 0 {
+		return 0, false
+	}
+	return float64(c.Delta()) / float64(c.Previous) * 100, true
+}
+
+func (c DomainChange) Status() string {
+	switch {
+	case c.Previous == 0 && c.Current > 0:
+		return ChangeNew
+	case c.Current == 0 && c.Previous > 0:
+		return ChangeDisappeared
+	case c.Current > c.Previous:
+		return ChangeUp
+	case c.Current < c.Previous:
+		return ChangeDown
+	}
+	return ChangeUnchanged
+}
+
+// Diff pairs the counts of previous and current by domain. Every domain found
+// in either result gets one entry, ordered by DiffOrderMagnitude.
+func Diff(previous, current []DomainData) []DomainChange {
+	byDomain := make(map[string]int, len(current))
+	changes := make([]DomainChange, 0, len(current))
+	for _, d := range previous {
+		if i, ok := byDomain[d.Domain]; ok {
+			changes[i].Previous += d.CustomerQuantity
+			continue
+		}
+		byDomain[d.Domain] = len(changes)
+		changes = append(changes, DomainChange{Domain: d.Domain, Previous: d.CustomerQuantity})
+	}
+	for _, d := range current {
+		i, ok := byDomain[d.Domain]
+		if !ok {
+			i = len(changes)
+			byDomain[d.Domain] = i
+			changes = append(changes, DomainChange{Domain: d.Domain})
+		}
+		changes[i].Current += d.CustomerQuantity
+	}
+	SortChanges(changes, DiffOrderMagnitude)
+	return changes
+}
+
+// SortChanges sorts changes in place by order. Ties are broken by domain, so
+// the output is stable across runs.
+func SortChanges(changes []DomainChange, order string) error {
+	var less func(a, b DomainChange) bool
+	switch order {
+	case DiffOrderMagnitude, "":
+		less = func(a, b DomainChange) bool { return abs(a.Delta()) > abs(b.Delta()) }
+	case DiffOrderChange:
+		less = func(a, b DomainChange) bool { return a.Delta() > b.Delta() }
+	case DiffOrderPercent:
+		less = func(a, b DomainChange) bool {
+			pa, oka := a.Percent()
+			pb, okb := b.Percent()
+			if oka != okb {
+				return !oka
+			}
+			return pa > pb
+		}
+	case DiffOrderDomain:
+		less = func(a, b DomainChange) bool { return false }
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedDiffOrder, order)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.Domain < b.Domain
+	})
+	return nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
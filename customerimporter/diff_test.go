@@ -0,0 +1,98 @@
+package customerimporter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	previous := []DomainData{
+		{Domain: "x.com", CustomerQuantity: 10},
+		{Domain: "y.com", CustomerQuantity: 4},
+		{Domain: "gone.com", CustomerQuantity: 3},
+		{Domain: "same.com", CustomerQuantity: 2},
+	}
+	current := []DomainData{
+		{Domain: "x.com", CustomerQuantity: 5},
+		{Domain: "y.com", CustomerQuantity: 6},
+		{Domain: "same.com", CustomerQuantity: 2},
+		{Domain: "new.com", CustomerQuantity: 2},
+	}
+
+	got := Diff(previous, current)
+	want := []DomainChange{
+		{Domain: "x.com", Previous: 10, Current: 5},
+		{Domain: "gone.com", Previous: 3, Current: 0},
+		{Domain: "new.com", Previous: 0, Current: 2},
+		{Domain: "y.com", Previous: 4, Current: 6},
+		{Domain: "same.com", Previous: 2, Current: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff:\n got %+v\nwant %+v", got, want)
+	}
+
+	statuses := map[string]string{
+		"x.com": ChangeDown, "gone.com": ChangeDisappeared, "new.com": ChangeNew,
+		"y.com": ChangeUp, "same.com": ChangeUnchanged,
+	}
+	for _, c := range got {
+		if s := c.Status(); s != statuses[c.Domain] {
+			t.Errorf("%s: Status() = %q, want %q", c.Domain, s, statuses[c.Domain])
+		}
+	}
+}
+
+func TestDomainChange_Percent(t *testing.T) {
+	tests := []struct {
+		name   string
+		change DomainChange
+		want   float64
+		wantOK bool
+	}{
+		{name: "Growth", change: DomainChange{Previous: 4, Current: 6}, want: 50, wantOK: true},
+		{name: "Disappeared", change: DomainChange{Previous: 3}, want: -100, wantOK: true},
+		{name: "New", change: DomainChange{Current: 2}, wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.change.Percent()
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("[%s] Percent() = %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSortChanges(t *testing.T) {
+	changes := []DomainChange{
+		{Domain: "a.com", Previous: 10, Current: 5},
+		{Domain: "b.com", Previous: 0, Current: 2},
+		{Domain: "c.com", Previous: 1, Current: 3},
+		{Domain: "d.com", Previous: 2, Current: 2},
+	}
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{order: DiffOrderMagnitude, want: []string{"a.com", "b.com", "c.com", "d.com"}},
+		{order: DiffOrderChange, want: []string{"b.com", "c.com", "d.com", "a.com"}},
+		{order: DiffOrderPercent, want: []string{"b.com", "c.com", "d.com", "a.com"}},
+		{order: DiffOrderDomain, want: []string{"a.com", "b.com", "c.com", "d.com"}},
+	}
+	for _, tt := range tests {
+		sorted := append([]DomainChange(nil), changes...)
+		if err := SortChanges(sorted, tt.order); err != nil {
+			t.Fatalf("[%s] SortChanges: %v", tt.order, err)
+		}
+		var got []string
+		for _, c := range sorted {
+			got = append(got, c.Domain)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%s] order %v, want %v", tt.order, got, tt.want)
+		}
+	}
+
+	if err := SortChanges(changes, "size"); !errors.Is(err, ErrUnsupportedDiffOrder) {
+		t.Fatalf("SortChanges(size) error = %v, want ErrUnsupportedDiffOrder", err)
+	}
+}
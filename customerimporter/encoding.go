@@ -0,0 +1,188 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var ErrUnsupportedEncoding = errors.New("unsupported encoding")
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// windows1252 maps the 0x80–0x9F range, the only place where Windows-1252
+// differs from Latin-1. Unassigned bytes pass through as C1 controls, the same
+// way browsers decode them.
+var windows1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// newDecoder returns a reader that yields UTF-8 regardless of the source encoding.
+// A leading BOM is always consumed; with an empty or "auto" encoding the BOM also
+// decides between UTF-8 and UTF-16.
+func newDecoder(br *bufio.Reader, encoding string) (io.Reader, error) {
+	enc := strings.ToLower(strings.TrimSpace(encoding))
+	enc = strings.ReplaceAll(enc, "_", "-")
+
+	switch enc {
+	case "", "auto":
+		switch {
+		case hasPrefix(br, bomUTF8):
+			br.Discard(len(bomUTF8))
+			return br, nil
+		case hasPrefix(br, bomUTF16LE):
+			br.Discard(len(bomUTF16LE))
+			return &runeDecoder{src: br, next: utf16Next(false)}, nil
+		case hasPrefix(br, bomUTF16BE):
+			br.Discard(len(bomUTF16BE))
+			return &runeDecoder{src: br, next: utf16Next(true)}, nil
+		}
+		return br, nil
+	case "utf-8", "utf8":
+		if hasPrefix(br, bomUTF8) {
+			br.Discard(len(bomUTF8))
+		}
+		return br, nil
+	case "utf-16", "utf16":
+		// Without a BOM assume little-endian, which is what Windows tools write.
+		bigEndian := false
+		if hasPrefix(br, bomUTF16BE) {
+			bigEndian = true
+			br.Discard(len(bomUTF16BE))
+		} else if hasPrefix(br, bomUTF16LE) {
+			br.Discard(len(bomUTF16LE))
+		}
+		return &runeDecoder{src: br, next: utf16Next(bigEndian)}, nil
+	case "utf-16le", "utf16le":
+		if hasPrefix(br, bomUTF16LE) {
+			br.Discard(len(bomUTF16LE))
+		}
+		return &runeDecoder{src: br, next: utf16Next(false)}, nil
+	case "utf-16be", "utf16be":
+		if hasPrefix(br, bomUTF16BE) {
+			br.Discard(len(bomUTF16BE))
+		}
+		return &runeDecoder{src: br, next: utf16Next(true)}, nil
+	case "latin1", "latin-1", "iso-8859-1", "iso8859-1":
+		return &runeDecoder{src: br, next: latin1Next}, nil
+	case "windows-1252", "cp1252":
+		return &runeDecoder{src: br, next: windows1252Next}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, encoding)
+}
+
+func hasPrefix(br *bufio.Reader, prefix []byte) bool {
+	b, _ := br.Peek(len(prefix))
+	return bytes.Equal(b, prefix)
+}
+
+// runeDecoder converts a byte stream into UTF-8 one rune at a time.
+type runeDecoder struct {
+	src  *bufio.Reader
+	next func(*bufio.Reader) (rune, error)
+	buf  [utf8.UTFMax]byte
+	pend []byte
+}
+
+func (d *runeDecoder) Read(p []byte) (int, error) {
+	if len(d.pend) > 0 {
+		n := copy(p, d.pend)
+		d.pend = d.pend[n:]
+		return n, nil
+	}
+
+	n := 0
+	for n < len(p) {
+		// Don't block for more input once we have something to hand back.
+		if n > 0 && d.src.Buffered() == 0 {
+			break
+		}
+		r, err := d.next(d.src)
+		if err != nil {
+			if err == io.EOF && n > 0 {
+				return n, nil
+			}
+			return n, err
+		}
+		if len(p)-n >= utf8.UTFMax {
+			n += utf8.EncodeRune(p[n:], r)
+			continue
+		}
+		m := utf8.EncodeRune(d.buf[:], r)
+		c := copy(p[n:], d.buf[:m])
+		n += c
+		d.pend = d.buf[c:m]
+		break
+	}
+	return n, nil
+}
+
+func latin1Next(br *bufio.Reader) (rune, error) {
+	b, err := br.ReadByte()
+	return rune(b), err
+}
+
+func windows1252Next(br *bufio.Reader) (rune, error) {
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b >= 0x80 && b <= 0x9F {
+		return windows1252[b-0x80], nil
+	}
+	return rune(b), nil
+}
+
+func utf16Next(bigEndian bool) func(*bufio.Reader) (rune, error) {
+	decode := func(b []byte) rune {
+		if bigEndian {
+			return rune(b[0])<<8 | rune(b[1])
+		}
+		return rune(b[1])<<8 | rune(b[0])
+	}
+
+	return func(br *bufio.Reader) (rune, error) {
+		var b [2]byte
+		if _, err := io.ReadFull(br, b[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				// A dangling odd byte can't form a code unit.
+				return utf8.RuneError, nil
+			}
+			return 0, err
+		}
+		r := decode(b[:])
+		if !utf16.IsSurrogate(r) {
+			return r, nil
+		}
+		if r >= 0xDC00 {
+			// A low surrogate without a high one before it.
+			return utf8.RuneError, nil
+		}
+		// Only a low surrogate completes the pair; anything else is left for
+		// the next call, so a lone high surrogate cannot swallow a newline.
+		next, err := br.Peek(2)
+		if len(next) < 2 {
+			if err == io.EOF {
+				err = nil
+			}
+			return utf8.RuneError, err
+		}
+		if dec := utf16.DecodeRune(r, decode(next)); dec != utf8.RuneError {
+			br.Discard(2)
+			return dec, nil
+		}
+		return utf8.RuneError, nil
+	}
+}
@@ -0,0 +1,152 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"unicode/utf16"
+)
+
+func encodeUTF16(s string, bigEndian bool, bom bool) []byte {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	return utf16Bytes(units, bigEndian)
+}
+
+// utf16Bytes serialises raw code units, which unlike encodeUTF16 can hold
+// unpaired surrogates.
+func utf16Bytes(units []uint16, bigEndian bool) []byte {
+	var out []byte
+	for _, u := range units {
+		if bigEndian {
+			out = append(out, byte(u>>8), byte(u))
+		} else {
+			out = append(out, byte(u), byte(u>>8))
+		}
+	}
+	return out
+}
+
+func decodeAll(t *testing.T, in []byte, encoding string) string {
+	t.Helper()
+	r, err := newDecoder(bufio.NewReader(bytes.NewReader(in)), encoding)
+	if err != nil {
+		t.Fatalf("newDecoder(%q): %v", encoding, err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read decoded: %v", err)
+	}
+	return string(b)
+}
+
+func TestNewDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       []byte
+		encoding string
+		want     string
+	}{
+		{"Plain_utf8", []byte("email\n"), "", "email\n"},
+		{"Strips_utf8_bom_auto", append([]byte{0xEF, 0xBB, 0xBF}, "email\n"...), "auto", "email\n"},
+		{"Strips_utf8_bom_explicit", append([]byte{0xEF, 0xBB, 0xBF}, "email\n"...), "UTF-8", "email\n"},
+		{"Detects_utf16le_bom", encodeUTF16("émail\n", false, true), "", "émail\n"},
+		{"Detects_utf16be_bom", encodeUTF16("émail\n", true, true), "", "émail\n"},
+		{"Utf16_without_bom_defaults_le", encodeUTF16("a@b.com", false, false), "utf-16", "a@b.com"},
+		{"Utf16_honours_be_bom", encodeUTF16("a@b.com", true, true), "utf-16", "a@b.com"},
+		{"Utf16be_explicit", encodeUTF16("a@b.com", true, false), "utf-16be", "a@b.com"},
+		{"Utf16_surrogate_pair", encodeUTF16("😀@x.com", false, true), "", "😀@x.com"},
+		{"Utf16_lone_high_surrogate", utf16Bytes(append(append(utf16.Encode([]rune("a@x.com")), 0xD800), utf16.Encode([]rune("\nb@y.com"))...), false), "utf-16", "a@x.com\uFFFD\nb@y.com"},
+		{"Utf16_lone_low_surrogate", utf16Bytes(append(append(utf16.Encode([]rune("a@x.com")), 0xDC00), utf16.Encode([]rune("\nb@y.com"))...), true), "utf-16be", "a@x.com\uFFFD\nb@y.com"},
+		{"Utf16_high_surrogate_then_pair", utf16Bytes([]uint16{0xD800, 0xD83D, 0xDE00}, false), "utf-16le", "\uFFFD😀"},
+		{"Utf16_high_surrogate_at_eof", utf16Bytes([]uint16{'a', 0xD800}, false), "utf-16le", "a\uFFFD"},
+		{"Latin1", []byte{'J', 0xFC, 'r', 'g', 'e', 'n'}, "latin1", "Jürgen"},
+		{"Windows1252_specials", []byte{0x80, ' ', 0x93, 'q', 0x94, ' ', 0xE9}, "windows-1252", "€ “q” é"},
+		{"Windows1252_alias", []byte{0x9C}, "cp1252", "œ"},
+	}
+
+	for _, tt := range tests {
+		if got := decodeAll(t, tt.in, tt.encoding); got != tt.want {
+			t.Fatalf("[%s] decoded=%q want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewDecoder_UnknownEncoding(t *testing.T) {
+	_, err := newDecoder(bufio.NewReader(bytes.NewReader(nil)), "ebcdic")
+	if !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Fatalf("expected ErrUnsupportedEncoding, got %v", err)
+	}
+}
+
+func TestRuneDecoder_TinyReads(t *testing.T) {
+	r, err := newDecoder(bufio.NewReader(bytes.NewReader([]byte{0x80, 0xE9})), "windows-1252")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	p := make([]byte, 1)
+	for {
+		n, err := r.Read(p)
+		got = append(got, p[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(got) != "€é" {
+		t.Fatalf("got %q want %q", got, "€é")
+	}
+}
+
+// TestImporter_UTF16LoneSurrogate checks that an unpaired surrogate before a
+// newline spoils only its own row instead of merging it with the next one.
+func TestImporter_UTF16LoneSurrogate(t *testing.T) {
+	units := utf16.Encode([]rune("email\na@x.com"))
+	units = append(units, 0xD800)
+	units = append(units, utf16.Encode([]rune("\nb@y.com\nc@z.com\n"))...)
+	path := mustWriteTempCSV(t, string(utf16Bytes(units, false)))
+
+	got, err := New(Config{Path: path, EmailHeader: "email", Encoding: "utf-16le"}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	if got.Stats.TotalRows != 3 || got.Stats.UniqueDomains+got.Stats.BadRows != 3 {
+		t.Fatalf("stats=%+v data=%v", got.Stats, got.Data)
+	}
+}
+
+func TestImporter_Encodings(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+	}{
+		{"Utf8_bom_header", append([]byte{0xEF, 0xBB, 0xBF}, "email,name\na@x.com,A\nb@x.com,B\n"...), ""},
+		{"Utf16le_bom", encodeUTF16("email,name\na@x.com,Zoë\nb@X.com,B\n", false, true), ""},
+		{"Utf16be_bom", encodeUTF16("email,name\r\na@x.com,Zoë\r\nb@x.com,B\r\n", true, true), ""},
+		{"Windows1252", []byte("name,email\nJ\xfcrgen,a@x.com\nRen\xe9e,b@x.com\n"), "windows-1252"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := mustWriteTempCSV(t, string(tt.body))
+			got, err := New(Config{Path: path, EmailHeader: "email", Encoding: tt.encoding}).ImportDomainData()
+			if err != nil {
+				t.Fatalf("ImportDomainData error: %v", err)
+			}
+			if got.Stats.TotalRows != 2 || got.Stats.BadRows != 0 || len(got.Data) != 1 {
+				t.Fatalf("stats=%+v data=%v", got.Stats, got.Data)
+			}
+			if got.Data[0] != (DomainData{Domain: "x.com", CustomerQuantity: 2}) {
+				t.Fatalf("data=%v", got.Data)
+			}
+		})
+	}
+}
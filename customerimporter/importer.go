@@ -2,20 +2,89 @@ package customerimporter
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
-var ErrEmailHeaderMissing = errors.New("email header not found")
+const (
+	FormatCSV     = "csv"
+	FormatXLSX    = "xlsx"
+	FormatJSON    = "json"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+	FormatSQLite  = "sqlite"
+	FormatMbox    = "mbox"
+	FormatVCard   = "vcard"
+	FormatLDIF    = "ldif"
+	FormatZip     = "zip"
+	FormatTar     = "tar"
+	FormatTarGz   = "tar.gz"
+)
+
+var (
+	ErrEmailHeaderMissing = errors.New("email header not found")
+	ErrEmailColumnMissing = errors.New("email column is required when the input has no header")
+	ErrUnsupportedFormat  = errors.New("unsupported input format")
+)
 
 type Config struct {
 	Path                   string
 	EmailHeader            string
 	AllowSingleLabelDomain bool
+	// NoHeader treats the first record as data instead of a header row.
+	NoHeader bool
+	// EmailColumn selects the email field by its 1-based position.
+	// Zero means look it up by EmailHeader.
+	EmailColumn int
+	// Encoding names the input character set (utf-8, utf-16, utf-16le, utf-16be,
+	// latin1, windows-1252). Empty or "auto" detects UTF-8/UTF-16 from the BOM.
+	Encoding string
+	// Format is the input format (csv, xlsx, json, ndjson, parquet, sqlite,
+	// mbox, vcard, ldif) or an archive of such files (zip, tar, tar.gz). Empty detects it from the file extension.
+	Format string
+	// Sheet selects an XLSX worksheet by name or 1-based position. Empty means the first sheet.
+	Sheet string
+	// EmailPath locates the email in JSON records as a dotted path with optional
+	// indexes, e.g. "contact.emails[0]", or names a nested Parquet column
+	// ("contact.email"). Empty falls back to EmailHeader.
+	EmailPath string
+	// Query is the SELECT run against SQLite input; the email is taken from the
+	// result column named by EmailHeader (or positioned by EmailColumn).
+	Query string
+	// MboxHeaders lists the headers whose addresses are counted in mbox input.
+	// Empty means DefaultMboxHeaders.
+	MboxHeaders []string
+	// Members is a glob (path.Match syntax) selecting archive members. A pattern
+	// without "/" is matched against the base name. Empty selects every member
+	// with a recognised extension.
+	Members string
+	// Progress, if set, is called from the importing goroutine at most once per
+	// ProgressInterval (DefaultProgressInterval if zero), and once more at the end.
+	Progress         func(Progress)
+	ProgressInterval time.Duration
+	// Malformed selects how malformed records are handled.
+	Malformed MalformedPolicy
+	// RejectedSample keeps the first RejectedSample records that were counted
+	// as bad for having no usable email in Result.Rejected. Zero keeps none.
+	RejectedSample int
+	// StatePath makes the import incremental for CSV input that only grows
+	// at the end. After a successful import the counts, the byte offset of
+	// the counted prefix and a SHA-256 of it are saved there; the next import
+	// hashes the prefix again, resumes from that offset when it is unchanged
+	// and counts everything again otherwise. See Result.Incremental.
+	StatePath string
+	// Size is the expected length of a streamed input, e.g. a request's
+	// Content-Length, reported as Progress.TotalBytes by ImportReaderContext.
+	// Zero means unknown. ImportDomainData uses the file size instead.
+	Size int64
 }
 
 type DomainData struct {
@@ -24,14 +93,107 @@ type DomainData struct {
 }
 
 type Stats struct {
-	TotalRows     int
-	BadRows       int
-	UniqueDomains int
+	TotalRows int `json:"total_rows"`
+	BadRows   int `json:"bad_rows"`
+	// MalformedRows is the subset of BadRows that could not be parsed at all
+	// or had too few fields; see RowError.
+	MalformedRows int `json:"malformed_rows"`
+	UniqueDomains int `json:"unique_domains"`
 }
 
 type Result struct {
 	Data  []DomainData
 	Stats Stats
+	// Format is the input format that was read, after extension detection.
+	Format string
+	// Members holds per-file stats when the input is an archive, in archive order.
+	Members []MemberStats
+	// RowErrors samples the malformed records that were skipped, up to
+	// MaxRecordedRowErrors.
+	RowErrors []RowError
+	// Rejected samples well-formed records without a valid email; see
+	// Config.RejectedSample.
+	Rejected []RejectedRow
+	Timings  Timings
+	// Incremental is set when Config.StatePath is.
+	Incremental Incremental
+	// BadRowsByReason splits Stats.BadRows by cause: the RejectedRow reasons
+	// for rows without a usable email and ReasonMalformed for malformed ones.
+	BadRowsByReason map[string]int
+	// BytesRead is how much of the input was read; it can be less than its
+	// size for formats that skip data, such as Parquet.
+	BytesRead int64
+}
+
+// RejectedRow is a record counted as bad although it could be parsed.
+type RejectedRow struct {
+	// Row is the 1-based record number within its file, not counting a header.
+	Row    int
+	Email  string
+	Reason string
+}
+
+// Reasons reported in RejectedRow.Reason.
+const (
+	RejectMissingEmail  = "missing email"
+	RejectInvalidEmail  = "invalid address"
+	RejectInvalidDomain = "invalid domain"
+)
+
+// ReasonMalformed counts malformed rows in Result.BadRowsByReason.
+const ReasonMalformed = "malformed"
+
+// Timings breaks an import down by phase. For archives Open and Parse are
+// summed over the members.
+type Timings struct {
+	// Open covers opening the input and reading headers or file metadata.
+	Open  time.Duration
+	Parse time.Duration
+	Sort  time.Duration
+}
+
+type MemberStats struct {
+	Name   string
+	Format string
+	Stats  Stats
+}
+
+// rowReader yields one record at a time; *csv.Reader satisfies it.
+type rowReader interface {
+	Read() ([]string, error)
+}
+
+// emailSource yields the email field of each record. ok is false when the
+// record exists but has no usable email field; it still counts as a row.
+type emailSource interface {
+	Next() (email string, ok bool, err error)
+}
+
+// columnSource picks the email field out of tabular rows by position.
+type columnSource struct {
+	rows rowReader
+	idx  int
+}
+
+// fieldPositioner is implemented by row readers that know where the last
+// record started (*csv.Reader, xlsxRows).
+type fieldPositioner interface {
+	FieldPos(field int) (line, column int)
+}
+
+func (c *columnSource) Next() (string, bool, error) {
+	rec, err := c.rows.Read()
+	if err != nil {
+		return "", false, asRowError(err)
+	}
+	if c.idx >= len(rec) {
+		rowErr := &RowError{Err: ErrEmailFieldMissing, skippable: true}
+		if fp, ok := c.rows.(fieldPositioner); ok {
+			rowErr.Line, _ = fp.FieldPos(0)
+		}
+		return "", false, rowErr
+	}
+	return rec[c.idx], true, nil
 }
 
 type Importer struct {
@@ -43,32 +205,23 @@ func New(cfg Config) *Importer {
 }
 
 func (i *Importer) ImportDomainData() (Result, error) {
-	var res Result
+	return i.ImportDomainDataContext(context.Background())
+}
 
+// ImportDomainDataContext is ImportDomainData with cancellation. When ctx is
+// done the import stops within a few thousand records and returns the counts
+// gathered so far together with ctx.Err().
+func (i *Importer) ImportDomainDataContext(ctx context.Context) (Result, error) {
 	f, err := os.Open(i.cfg.Path)
 	if err != nil {
-		return res, err
+		return Result{}, err
 	}
 	defer f.Close()
 
-	r := csv.NewReader(bufio.NewReaderSize(f, 256<<10))
-	r.FieldsPerRecord = -1
-	r.TrimLeadingSpace = true
-	// ReuseRecord reduces allocations per row. Safe because we consume header immediately,
-	// and in the loop we fully process each record before next Read.
-	r.ReuseRecord = true
-
-	header, err := r.Read()
-	if err != nil {
-		return res, err
-	}
-	emailIdx := findHeaderIndex(header, i.cfg.EmailHeader)
-	if emailIdx < 0 {
-		return res, ErrEmailHeaderMissing
-	}
-
+	var size int64
 	var counts map[string]int
 	if fi, _ := f.Stat(); fi != nil {
+		size = fi.Size()
 		// assume ~40 bytes/row to estimate initial map capacity; reduces rehashing on large files.
 		estRows := int(fi.Size()/40) + 1
 		if estRows < 1024 {
@@ -79,34 +232,318 @@ func (i *Importer) ImportDomainData() (Result, error) {
 		counts = make(map[string]int, 1024)
 	}
 
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
+	return i.importTracked(ctx, &trackedFile{File: f, ctx: ctx}, size, counts)
+}
+
+// ImportReaderContext counts the emails streamed from r, e.g. an uploaded
+// request body. Config.Path is not opened; its name, if set, only picks the
+// format when Config.Format is empty. CSV, JSON, NDJSON, mbox, vCard, LDIF
+// and tar(.gz) input is read as it arrives. XLSX, Parquet, zip and SQLite
+// need random access, so r is first copied to a temporary file that is
+// removed afterwards. Config.StatePath is not supported.
+func (i *Importer) ImportReaderContext(ctx context.Context, r io.Reader) (Result, error) {
+	format := i.format()
+	if i.cfg.StatePath != "" {
+		return Result{Format: format}, fmt.Errorf("%w: streamed input", ErrStateUnsupported)
+	}
+	switch format {
+	case FormatXLSX, FormatParquet, FormatZip, FormatSQLite:
+		return i.importSpooledInput(ctx, r, format)
+	}
+	return i.importTracked(ctx, &trackedReader{r: r, ctx: ctx}, i.cfg.Size, make(map[string]int, 1024))
+}
+
+// importSpooledInput copies a streamed input that needs random access to a
+// temporary file and imports that, like importSpooled does for members.
+func (i *Importer) importSpooledInput(ctx context.Context, r io.Reader, format string) (Result, error) {
+	tmp, err := os.CreateTemp("", "edc-input-*."+format)
+	if err != nil {
+		return Result{Format: format}, fmt.Errorf("spool input: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, &trackedReader{r: r, ctx: ctx})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Result{Format: format}, ctxErr
+		}
+		return Result{Format: format}, fmt.Errorf("spool input: %w", err)
+	}
+
+	cfg := i.cfg
+	cfg.Path = tmp.Name()
+	cfg.Format = format
+	return New(cfg).ImportDomainDataContext(ctx)
+}
+
+// importTracked dispatches on the input format and collects the result.
+func (i *Importer) importTracked(ctx context.Context, in trackedInput, size int64, counts map[string]int) (Result, error) {
+	var res Result
+	var err error
+	rn := i.newRun(ctx, in, size)
+
+	format := i.format()
+	res.Format = format
+	switch {
+	case i.cfg.StatePath != "" && format != FormatCSV:
+		err = fmt.Errorf("%w: %s input", ErrStateUnsupported, format)
+	case i.cfg.StatePath != "":
+		f, ok := in.(*trackedFile)
+		if !ok {
+			err = fmt.Errorf("%w: streamed input", ErrStateUnsupported)
 			break
 		}
-		if err != nil {
-			return res, err
+		err = i.importIncremental(rn, f, size, counts, &res)
+	case format == FormatZip, format == FormatTar, format == FormatTarGz:
+		err = i.importArchive(rn, in, format, counts, &res)
+	default:
+		err = i.importFile(rn, in, i.cfg.Path, format, counts, &res.Stats)
+	}
+	res.RowErrors = rn.rowErrors
+	res.Rejected = rn.rejected
+	res.BadRowsByReason = rn.reasons
+	res.BytesRead = in.bytesRead()
+	res.Timings = rn.timings
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			res.Data = makeSortedData(counts)
+			res.Stats.UniqueDomains = len(res.Data)
+			rn.finish()
+			return res, ctxErr
 		}
+		return res, err
+	}
+
+	start := time.Now()
+	data := makeSortedData(counts)
+	res.Timings.Sort = time.Since(start)
+	res.Data = data
+	res.Stats.UniqueDomains = len(data)
+	rn.finish()
+	return res, nil
+}
 
-		res.Stats.TotalRows++
+// importFile counts the emails of a single input into counts and stats.
+func (i *Importer) importFile(rn *run, r io.Reader, path, format string, counts map[string]int, stats *Stats) error {
+	start := time.Now()
+	src, err := i.openSource(rn.ctx, r, path, format)
+	rn.timings.Open += time.Since(start)
+	if err != nil {
+		return err
+	}
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
+	}
 
-		if emailIdx >= len(rec) {
-			res.Stats.BadRows++
+	start = time.Now()
+	defer func() { rn.timings.Parse += time.Since(start) }()
+	return i.countSource(rn, src, counts, stats)
+}
+
+// countSource counts the emails src yields into counts and stats until io.EOF.
+func (i *Importer) countSource(rn *run, src emailSource, counts map[string]int, stats *Stats) error {
+	for {
+		email, ok, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var rowErr *RowError
+			if !errors.As(err, &rowErr) || !i.skipMalformed(rowErr) {
+				return err
+			}
+			stats.TotalRows++
+			stats.BadRows++
+			stats.MalformedRows++
+			rn.reasons[ReasonMalformed]++
+			if len(rn.rowErrors) < MaxRecordedRowErrors {
+				rn.rowErrors = append(rn.rowErrors, *rowErr)
+			}
+			if err := rn.record(true); err != nil {
+				return err
+			}
 			continue
 		}
 
-		if domain, ok := extractDomain(rec[emailIdx]); ok && isValidDomain(domain, i.cfg.AllowSingleLabelDomain) {
+		stats.TotalRows++
+
+		reason := ""
+		if !ok || email == "" {
+			reason = RejectMissingEmail
+		} else if domain, ok := extractDomain(email); !ok {
+			reason = RejectInvalidEmail
+		} else if !isValidDomain(domain, i.cfg.AllowSingleLabelDomain) {
+			reason = RejectInvalidDomain
+		} else {
 			counts[domain]++
-			continue
+		}
+		bad := reason != ""
+		if bad {
+			stats.BadRows++
+			rn.reasons[reason]++
+			if len(rn.rejected) < i.cfg.RejectedSample {
+				rn.rejected = append(rn.rejected, RejectedRow{Row: stats.TotalRows, Email: email, Reason: reason})
+			}
 		}
 
-		res.Stats.BadRows++
+		if err := rn.record(bad); err != nil {
+			return err
+		}
 	}
+}
 
-	data := makeSortedData(counts)
-	res.Data = data
-	res.Stats.UniqueDomains = len(data)
-	return res, nil
+// openSource picks the reader for the input format and positions it on the
+// first data record. XLSX and Parquet need random access, so r must be an
+// inputFile for them; SQLite opens path itself.
+func (i *Importer) openSource(ctx context.Context, r io.Reader, path, format string) (emailSource, error) {
+	var rows rowReader
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, err = i.csvRows(r)
+	case FormatXLSX:
+		f, ok := r.(inputFile)
+		if !ok {
+			return nil, fmt.Errorf("%w: xlsx needs a seekable file", ErrUnsupportedFormat)
+		}
+		rows, err = openXLSX(f, i.cfg.Sheet)
+	case FormatJSON, FormatNDJSON:
+		return i.jsonSource(r, format)
+	case FormatParquet:
+		f, ok := r.(inputFile)
+		if !ok {
+			return nil, fmt.Errorf("%w: parquet needs a seekable file", ErrUnsupportedFormat)
+		}
+		column := i.cfg.EmailPath
+		if column == "" {
+			column = i.cfg.EmailHeader
+		}
+		return openParquet(f, column)
+	case FormatMbox, FormatVCard, FormatLDIF:
+		return i.textSource(r, format)
+	case FormatSQLite:
+		return openSQLite(ctx, path, i.cfg.Query, i.cfg.EmailHeader, i.cfg.EmailColumn)
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	emailIdx, err := i.emailIndex(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &columnSource{rows: rows, idx: emailIdx}, nil
+}
+
+// format resolves Config.Format, falling back to the file extension.
+func (i *Importer) format() string {
+	if i.cfg.Format != "" {
+		if format := strings.ToLower(i.cfg.Format); format != "tgz" {
+			return format
+		}
+		return FormatTarGz
+	}
+	if format, ok := FormatForPath(i.cfg.Path); ok {
+		return format
+	}
+	return FormatCSV
+}
+
+// FormatForPath maps a file name to an input format by its extension. ok is
+// false for extensions no reader is registered for.
+func FormatForPath(name string) (format string, ok bool) {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".tar.gz") {
+		return FormatTarGz, true
+	}
+	switch filepath.Ext(lower) {
+	case ".csv":
+		return FormatCSV, true
+	case ".xlsx":
+		return FormatXLSX, true
+	case ".json":
+		return FormatJSON, true
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, true
+	case ".parquet":
+		return FormatParquet, true
+	case ".sqlite", ".sqlite3", ".db":
+		return FormatSQLite, true
+	case ".mbox", ".mbx":
+		return FormatMbox, true
+	case ".vcf", ".vcard":
+		return FormatVCard, true
+	case ".ldif":
+		return FormatLDIF, true
+	case ".zip":
+		return FormatZip, true
+	case ".tar":
+		return FormatTar, true
+	case ".tgz":
+		return FormatTarGz, true
+	}
+	return "", false
+}
+
+// textSource opens the address-oriented formats, where every address found
+// counts as one record.
+func (i *Importer) textSource(f io.Reader, format string) (emailSource, error) {
+	src, err := newDecoder(bufio.NewReaderSize(f, 256<<10), i.cfg.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(src, 64<<10)
+
+	switch format {
+	case FormatMbox:
+		return newMboxSource(br, i.cfg.MboxHeaders), nil
+	case FormatVCard:
+		return &vcardSource{lines: newUnfoldReader(br)}, nil
+	}
+	return &ldifSource{lines: newUnfoldReader(br)}, nil
+}
+
+func (i *Importer) csvRows(f io.Reader) (rowReader, error) {
+	src, err := newDecoder(bufio.NewReaderSize(f, 256<<10), i.cfg.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(src)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	// ReuseRecord reduces allocations per row. Safe because we consume header immediately,
+	// and in the loop we fully process each record before next Read.
+	r.ReuseRecord = true
+	return r, nil
+}
+
+// emailIndex consumes the header row (unless NoHeader is set) and returns the
+// zero-based position of the email field.
+func (i *Importer) emailIndex(rows rowReader) (int, error) {
+	emailIdx := i.cfg.EmailColumn - 1
+	if i.cfg.NoHeader {
+		if i.cfg.EmailColumn <= 0 {
+			return -1, ErrEmailColumnMissing
+		}
+		return emailIdx, nil
+	}
+
+	header, err := rows.Read()
+	if err != nil {
+		return -1, asRowError(err)
+	}
+	if i.cfg.EmailColumn <= 0 {
+		emailIdx = findHeaderIndex(header, i.cfg.EmailHeader)
+		if emailIdx < 0 {
+			return -1, ErrEmailHeaderMissing
+		}
+	}
+	return emailIdx, nil
 }
 
 func isValidDomain(domain string, allowSingle bool) bool {
@@ -205,6 +642,12 @@ func extractDomain(email string) (string, bool) {
 	return string(buf), true
 }
 
+// SortedData turns per-domain counts into DomainData in result order: count
+// descending, then domain ascending.
+func SortedData(counts map[string]int) []DomainData {
+	return makeSortedData(counts)
+}
+
 func makeSortedData(counts map[string]int) []DomainData {
 	data := make([]DomainData, 0, len(counts))
 	for d, c := range counts {
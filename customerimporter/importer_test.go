@@ -2,8 +2,14 @@
 package customerimporter
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -228,6 +234,78 @@ func TestImporter_MissingEmailHeader(t *testing.T) {
 	}
 }
 
+func TestImporter_HeaderlessAndColumnIndex(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		cfg         Config
+		total, bad  int
+		wantDomains map[string]int
+	}{
+		{
+			name:        "No_header_first_row_is_data",
+			body:        "Alice,a@x.com\nBob,b@x.com\nCarol,c@y.com\n",
+			cfg:         Config{NoHeader: true, EmailColumn: 2},
+			total:       3,
+			bad:         0,
+			wantDomains: map[string]int{"x.com": 2, "y.com": 1},
+		},
+		{
+			name:        "No_header_ragged_row_is_bad",
+			body:        "a@x.com,1\n2\n",
+			cfg:         Config{NoHeader: true, EmailColumn: 1},
+			total:       2,
+			bad:         1,
+			wantDomains: map[string]int{"x.com": 1},
+		},
+		{
+			name:        "Column_index_overrides_header_name",
+			body:        "email,backup\nwrong@nope,a@x.com\n",
+			cfg:         Config{EmailHeader: "email", EmailColumn: 2},
+			total:       1,
+			bad:         0,
+			wantDomains: map[string]int{"x.com": 1},
+		},
+		{
+			name:        "Column_index_out_of_range",
+			body:        "email\na@x.com\n",
+			cfg:         Config{EmailColumn: 3},
+			total:       1,
+			bad:         1,
+			wantDomains: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Path = mustWriteTempCSV(t, tt.body)
+			got, err := New(tt.cfg).ImportDomainData()
+			if err != nil {
+				t.Fatalf("ImportDomainData error: %v", err)
+			}
+			if got.Stats.TotalRows != tt.total || got.Stats.BadRows != tt.bad {
+				t.Fatalf("stats got=%+v want total=%d bad=%d", got.Stats, tt.total, tt.bad)
+			}
+			if len(got.Data) != len(tt.wantDomains) {
+				t.Fatalf("domains got=%v want=%v", got.Data, tt.wantDomains)
+			}
+			for _, d := range got.Data {
+				if tt.wantDomains[d.Domain] != d.CustomerQuantity {
+					t.Errorf("domain %q count got=%d want=%d", d.Domain, d.CustomerQuantity, tt.wantDomains[d.Domain])
+				}
+			}
+		})
+	}
+}
+
+func TestImporter_NoHeaderRequiresColumn(t *testing.T) {
+	path := mustWriteTempCSV(t, "a@x.com\n")
+	_, err := New(Config{Path: path, NoHeader: true}).ImportDomainData()
+	if err != ErrEmailColumnMissing {
+		t.Fatalf("expected ErrEmailColumnMissing, got %v", err)
+	}
+}
+
 func TestExtractDomain(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -382,6 +460,127 @@ func TestImporter_SingleLabel_Strict_vs_Permissive(t *testing.T) {
 	}
 }
 
+func TestImporter_RejectedSample(t *testing.T) {
+	body := "name,email\n" +
+		"A,a@x.com\n" +
+		"B,\n" +
+		"C,not-an-email\n" +
+		"D,d@corp\n" +
+		"E,e@nodot\n"
+	path := mustWriteTempCSV(t, body)
+
+	got, err := New(Config{Path: path, EmailHeader: "email", RejectedSample: 3}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	want := []RejectedRow{
+		{Row: 2, Email: "", Reason: RejectMissingEmail},
+		{Row: 3, Email: "not-an-email", Reason: RejectInvalidEmail},
+		{Row: 4, Email: "d@corp", Reason: RejectInvalidDomain},
+	}
+	if len(got.Rejected) != len(want) {
+		t.Fatalf("rejected=%+v, want %+v", got.Rejected, want)
+	}
+	for i := range want {
+		if got.Rejected[i] != want[i] {
+			t.Fatalf("rejected[%d]=%+v, want %+v", i, got.Rejected[i], want[i])
+		}
+	}
+	if got.Stats.BadRows != 4 {
+		t.Fatalf("stats=%+v", got.Stats)
+	}
+	// Reasons are counted for every bad row, not just the sampled ones.
+	wantReasons := map[string]int{RejectMissingEmail: 1, RejectInvalidEmail: 1, RejectInvalidDomain: 2}
+	if !reflect.DeepEqual(got.BadRowsByReason, wantReasons) {
+		t.Fatalf("BadRowsByReason=%v, want %v", got.BadRowsByReason, wantReasons)
+	}
+	if got.BytesRead != int64(len(body)) {
+		t.Fatalf("BytesRead=%d, want %d", got.BytesRead, len(body))
+	}
+
+	got, err = New(Config{Path: path, EmailHeader: "email"}).ImportDomainData()
+	if err != nil || len(got.Rejected) != 0 {
+		t.Fatalf("rejected kept without RejectedSample: %+v (err=%v)", got.Rejected, err)
+	}
+}
+
+func TestImporter_Timings(t *testing.T) {
+	path := filepath.Join("testdata", "benchmark10k.csv")
+	got, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	if got.Timings.Open <= 0 || got.Timings.Parse <= 0 || got.Timings.Sort <= 0 {
+		t.Fatalf("timings=%+v, want every phase measured", got.Timings)
+	}
+}
+
+func TestImporter_ImportReader(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		cfg  Config
+	}{
+		{name: "CSV", path: mustWriteTempCSV(t, "email\na@x.com\nb@y.com\nc@x.com\nbad\n")},
+		{name: "NDJSON_by_format", path: mustWriteTempCSV(t, `{"email":"a@x.com"}`+"\n"), cfg: Config{Format: FormatNDJSON}},
+		{name: "TarGz_streamed", path: mustWriteTempTarGz(t, archiveMembers)},
+		{name: "Zip_spooled", path: mustWriteTempZip(t, archiveMembers)},
+		{name: "XLSX_spooled", path: mustWriteTempXLSX(t), cfg: Config{Sheet: "Customers"}},
+	}
+
+	for _, tt := range tests {
+		cfg := tt.cfg
+		cfg.Path = tt.path
+		cfg.EmailHeader = "email"
+		want, err := New(cfg).ImportDomainData()
+		if err != nil {
+			t.Fatalf("[%s] ImportDomainData error: %v", tt.name, err)
+		}
+
+		b, err := os.ReadFile(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Path only names the upload; the bytes come from a plain reader.
+		cfg.Path = "upload" + filepath.Ext(tt.path)
+		if strings.HasSuffix(tt.path, ".tar.gz") {
+			cfg.Path = "upload.tar.gz"
+		}
+		cfg.Size = int64(len(b))
+		var last Progress
+		cfg.Progress = func(p Progress) { last = p }
+		got, err := New(cfg).ImportReaderContext(context.Background(), io.MultiReader(bytes.NewReader(b)))
+		if err != nil {
+			t.Fatalf("[%s] ImportReaderContext error: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got.Data, want.Data) || got.Stats != want.Stats || got.Format != want.Format {
+			t.Fatalf("[%s] got %s %+v %+v, want %s %+v %+v", tt.name, got.Format, got.Data, got.Stats, want.Format, want.Data, want.Stats)
+		}
+		if !last.Done || last.TotalBytes != cfg.Size {
+			t.Fatalf("[%s] last progress = %+v, want done with TotalBytes %d", tt.name, last, cfg.Size)
+		}
+	}
+}
+
+func TestImporter_ImportReaderErrors(t *testing.T) {
+	cfg := Config{EmailHeader: "email", StatePath: filepath.Join(t.TempDir(), "state.json")}
+	if _, err := New(cfg).ImportReaderContext(context.Background(), strings.NewReader("email\n")); !errors.Is(err, ErrStateUnsupported) {
+		t.Fatalf("StatePath error = %v, want ErrStateUnsupported", err)
+	}
+
+	cfg = Config{EmailHeader: "email"}
+	if _, err := New(cfg).ImportReaderContext(context.Background(), strings.NewReader("id\n1\n")); !errors.Is(err, ErrEmailHeaderMissing) {
+		t.Fatalf("missing header error = %v, want ErrEmailHeaderMissing", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg.Format = FormatXLSX
+	if _, err := New(cfg).ImportReaderContext(ctx, strings.NewReader("PK")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled spool error = %v, want context.Canceled", err)
+	}
+}
+
 func BenchmarkImportDomainData(b *testing.B) {
 	// Path is relative to the package dir (customerimporter)
 	path := filepath.Join("testdata", "benchmark10k.csv")
@@ -0,0 +1,216 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidJSONPath = errors.New("invalid JSON path")
+
+// pathStep is one segment of an email path: an object key or an array index.
+type pathStep struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// jsonSource streams the elements of a top-level JSON array (or a single
+// top-level object) one record at a time.
+type jsonSource struct {
+	dec    *json.Decoder
+	path   []pathStep
+	single bool
+	done   bool
+}
+
+// ndjsonSource reads one JSON document per line. Lines that fail to parse are
+// reported as skippable RowErrors, so by default they count as bad rows
+// instead of aborting the import.
+type ndjsonSource struct {
+	r    *bufio.Reader
+	path []pathStep
+	line int
+}
+
+func (i *Importer) jsonSource(f io.Reader, format string) (emailSource, error) {
+	expr := i.cfg.EmailPath
+	if expr == "" {
+		expr = i.cfg.EmailHeader
+	}
+	path, err := parseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := newDecoder(bufio.NewReaderSize(f, 256<<10), i.cfg.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(src, 64<<10)
+
+	if format == FormatNDJSON {
+		return &ndjsonSource{r: br, path: path}, nil
+	}
+
+	first, err := peekNonSpace(br)
+	if err != nil {
+		if err == io.EOF {
+			return &jsonSource{done: true}, nil
+		}
+		return nil, err
+	}
+	s := &jsonSource{dec: json.NewDecoder(br), path: path}
+	switch first {
+	case '[':
+		if _, err := s.dec.Token(); err != nil {
+			return nil, fmt.Errorf("parse json: %w", err)
+		}
+	case '{':
+		s.single = true
+	default:
+		return nil, fmt.Errorf("parse json: expected an array or object, got %q", first)
+	}
+	return s, nil
+}
+
+func (s *jsonSource) Next() (string, bool, error) {
+	if s.done {
+		return "", false, io.EOF
+	}
+	if !s.single && !s.dec.More() {
+		s.done = true
+		// Consume the closing bracket so truncated documents are reported.
+		if _, err := s.dec.Token(); err != nil {
+			return "", false, fmt.Errorf("parse json: %w", err)
+		}
+		return "", false, io.EOF
+	}
+
+	var rec any
+	if err := s.dec.Decode(&rec); err != nil {
+		return "", false, fmt.Errorf("parse json record at offset %d: %w", s.dec.InputOffset(), err)
+	}
+	if s.single {
+		s.done = true
+	}
+	email, ok := lookupJSONPath(rec, s.path)
+	return email, ok, nil
+}
+
+func (s *ndjsonSource) Next() (string, bool, error) {
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return "", false, err
+		}
+		if len(line) > 0 {
+			s.line++
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err == io.EOF {
+				return "", false, io.EOF
+			}
+			continue
+		}
+
+		var rec any
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return "", false, &RowError{Line: s.line, Err: err, skippable: true}
+		}
+		email, ok := lookupJSONPath(rec, s.path)
+		return email, ok, nil
+	}
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+// parseJSONPath parses expressions like "email", "contact.email" or
+// "contact.emails[0]". A leading "$." is accepted and ignored.
+func parseJSONPath(expr string) ([]pathStep, error) {
+	p := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	p = strings.TrimPrefix(p, ".")
+	if p == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidJSONPath, expr)
+	}
+
+	var steps []pathStep
+	for _, part := range strings.Split(p, ".") {
+		if part == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidJSONPath, expr)
+		}
+		key := part
+		if br := strings.IndexByte(part, '['); br >= 0 {
+			key = part[:br]
+		}
+		if key != "" {
+			steps = append(steps, pathStep{key: key})
+		}
+
+		rest := part[len(key):]
+		for rest != "" {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidJSONPath, expr)
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidJSONPath, expr)
+			}
+			steps = append(steps, pathStep{index: n, isIdx: true})
+			rest = rest[end+1:]
+		}
+	}
+	return steps, nil
+}
+
+// lookupJSONPath walks a decoded record. Keys match exactly first and then
+// case-insensitively, like CSV headers; the final value must be a string.
+func lookupJSONPath(v any, path []pathStep) (string, bool) {
+	for _, st := range path {
+		switch node := v.(type) {
+		case map[string]any:
+			if st.isIdx {
+				return "", false
+			}
+			next, ok := node[st.key]
+			if !ok {
+				for k, val := range node {
+					if strings.EqualFold(k, st.key) {
+						next, ok = val, true
+						break
+					}
+				}
+			}
+			if !ok {
+				return "", false
+			}
+			v = next
+		case []any:
+			if !st.isIdx || st.index >= len(node) {
+				return "", false
+			}
+			v = node[st.index]
+		default:
+			return "", false
+		}
+	}
+	s, ok := v.(string)
+	return s, ok
+}
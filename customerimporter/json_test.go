@@ -0,0 +1,167 @@
+package customerimporter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func mustWriteTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return p
+}
+
+func TestImporter_JSONFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		body        string
+		cfg         Config
+		total, bad  int
+		wantDomains map[string]int
+	}{
+		{
+			name:        "Array_flat_uses_email_header",
+			file:        "in.json",
+			body:        `[{"email":"a@x.com"},{"EMAIL":"b@X.com"},{"name":"no email"},{"email":42}]`,
+			cfg:         Config{EmailHeader: "email"},
+			total:       4,
+			bad:         2,
+			wantDomains: map[string]int{"x.com": 2},
+		},
+		{
+			name: "Array_nested_path",
+			file: "in.json",
+			body: `[
+				{"contact":{"emails":["a@x.com","alt@y.com"]}},
+				{"contact":{"emails":[]}},
+				{"contact":{"emails":["c@y.com"]}},
+				"not an object"
+			]`,
+			cfg:         Config{EmailPath: "contact.emails[0]"},
+			total:       4,
+			bad:         2,
+			wantDomains: map[string]int{"x.com": 1, "y.com": 1},
+		},
+		{
+			name:        "Single_object",
+			file:        "in.json",
+			body:        `{"email":"a@x.com"}`,
+			cfg:         Config{EmailHeader: "email"},
+			total:       1,
+			wantDomains: map[string]int{"x.com": 1},
+		},
+		{
+			name:        "Empty_array",
+			file:        "in.json",
+			body:        " [ ] ",
+			cfg:         Config{EmailHeader: "email"},
+			wantDomains: map[string]int{},
+		},
+		{
+			name:        "NDJSON_skips_blank_and_counts_broken_lines",
+			file:        "in.ndjson",
+			body:        "{\"user\":{\"email\":\"a@x.com\"}}\n\n{broken\n{\"user\":{\"email\":\"b@y.com\"}}\n{\"user\":{}}",
+			cfg:         Config{EmailPath: "$.user.email"},
+			total:       4,
+			bad:         2,
+			wantDomains: map[string]int{"x.com": 1, "y.com": 1},
+		},
+		{
+			name:        "JSONL_extension_and_bom",
+			file:        "in.jsonl",
+			body:        "\xef\xbb\xbf{\"email\":\"a@x.com\"}\r\n{\"email\":\"bad@\"}\r\n",
+			cfg:         Config{EmailHeader: "email"},
+			total:       2,
+			bad:         1,
+			wantDomains: map[string]int{"x.com": 1},
+		},
+		{
+			name:        "Format_overrides_extension",
+			file:        "in.txt",
+			body:        `{"email":"a@x.com"}` + "\n" + `{"email":"b@x.com"}`,
+			cfg:         Config{EmailHeader: "email", Format: FormatNDJSON},
+			total:       2,
+			wantDomains: map[string]int{"x.com": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Path = mustWriteTempFile(t, tt.file, tt.body)
+			got, err := New(tt.cfg).ImportDomainData()
+			if err != nil {
+				t.Fatalf("ImportDomainData error: %v", err)
+			}
+			if got.Stats.TotalRows != tt.total || got.Stats.BadRows != tt.bad {
+				t.Fatalf("stats got=%+v want total=%d bad=%d", got.Stats, tt.total, tt.bad)
+			}
+			if len(got.Data) != len(tt.wantDomains) {
+				t.Fatalf("domains got=%v want=%v", got.Data, tt.wantDomains)
+			}
+			for _, d := range got.Data {
+				if tt.wantDomains[d.Domain] != d.CustomerQuantity {
+					t.Errorf("domain %q count got=%d want=%d", d.Domain, d.CustomerQuantity, tt.wantDomains[d.Domain])
+				}
+			}
+		})
+	}
+}
+
+func TestImporter_JSONSyntaxErrorAborts(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"Truncated_array", `[{"email":"a@x.com"},`},
+		{"Garbage_element", `[{"email":"a@x.com"} x]`},
+		{"Not_array_or_object", `"email"`},
+	}
+	for _, tt := range tests {
+		path := mustWriteTempFile(t, "in.json", tt.body)
+		if _, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData(); err == nil {
+			t.Fatalf("[%s] expected error, got nil", tt.name)
+		}
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []pathStep
+		ok   bool
+	}{
+		{"email", []pathStep{{key: "email"}}, true},
+		{"$.contact.email", []pathStep{{key: "contact"}, {key: "email"}}, true},
+		{"contact.emails[0]", []pathStep{{key: "contact"}, {key: "emails"}, {index: 0, isIdx: true}}, true},
+		{"[1].email", []pathStep{{index: 1, isIdx: true}, {key: "email"}}, true},
+		{"a[0][2]", []pathStep{{key: "a"}, {index: 0, isIdx: true}, {index: 2, isIdx: true}}, true},
+		{"", nil, false},
+		{"a..b", nil, false},
+		{"a[x]", nil, false},
+		{"a[-1]", nil, false},
+		{"a[0", nil, false},
+		{"a[0]b", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parseJSONPath(tt.in)
+		if (err == nil) != tt.ok {
+			t.Fatalf("parseJSONPath(%q) err=%v; want ok=%v", tt.in, err, tt.ok)
+		}
+		if !tt.ok {
+			if !errors.Is(err, ErrInvalidJSONPath) {
+				t.Fatalf("parseJSONPath(%q) err=%v; want ErrInvalidJSONPath", tt.in, err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("parseJSONPath(%q)=%+v; want %+v", tt.in, got, tt.want)
+		}
+	}
+}
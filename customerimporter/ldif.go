@@ -0,0 +1,49 @@
+package customerimporter
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// ldifSource yields every "mail" attribute value of an LDIF export, decoding
+// base64 values ("mail:: ...") and skipping comments.
+type ldifSource struct {
+	lines *unfoldReader
+}
+
+func (l *ldifSource) Next() (string, bool, error) {
+	for {
+		line, err := l.lines.ReadLine()
+		if err != nil {
+			return "", false, err
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			continue
+		}
+		name := line[:colon]
+		if semi := strings.IndexByte(name, ';'); semi >= 0 {
+			name = name[:semi]
+		}
+		if !strings.EqualFold(name, "mail") {
+			continue
+		}
+
+		val := line[colon+1:]
+		switch {
+		case strings.HasPrefix(val, ":"):
+			b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(val[1:]))
+			if err != nil {
+				return "", false, nil
+			}
+			return string(b), true, nil
+		case strings.HasPrefix(val, "<"):
+			// URL references ("mail:< file:///...") are not followed.
+			return "", false, nil
+		}
+		return strings.TrimSpace(val), true, nil
+	}
+}
@@ -0,0 +1,42 @@
+package customerimporter
+
+import "testing"
+
+const ldifFixture = "version: 1\n" +
+	"# comment mail: ignored@comment.com\n" +
+	"dn: cn=Alice,ou=people,dc=example,dc=com\n" +
+	"cn: Alice\n" +
+	"mail: alice@X.com\n" +
+	"mail;lang-en: alice@alt.org\n" +
+	"description: mail: not-an-attribute@nope.com\n" +
+	"\n" +
+	"dn: cn=Bob,ou=people,dc=example,dc=com\n" +
+	"MAIL:: Ym9iQHguY29t\n" + // bob@x.com
+	"mail: bob@very-long-domain-that-wr\n" +
+	" aps.net\n" +
+	"mail:: !!!notbase64\n" +
+	"mail:< file:///tmp/mail\n"
+
+func TestImporter_LDIF(t *testing.T) {
+	path := mustWriteTempFile(t, "people.ldif", ldifFixture)
+	got, err := New(Config{Path: path}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	if got.Stats.TotalRows != 6 || got.Stats.BadRows != 2 {
+		t.Fatalf("stats=%+v", got.Stats)
+	}
+	want := []DomainData{
+		{Domain: "x.com", CustomerQuantity: 2},
+		{Domain: "alt.org", CustomerQuantity: 1},
+		{Domain: "very-long-domain-that-wraps.net", CustomerQuantity: 1},
+	}
+	if len(got.Data) != len(want) {
+		t.Fatalf("data=%v want %v", got.Data, want)
+	}
+	for i := range want {
+		if got.Data[i] != want[i] {
+			t.Fatalf("order[%d] got=%v want=%v", i, got.Data[i], want[i])
+		}
+	}
+}
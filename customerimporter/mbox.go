@@ -0,0 +1,123 @@
+package customerimporter
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// DefaultMboxHeaders are the address headers counted when Config.MboxHeaders is empty.
+var DefaultMboxHeaders = []string{"From", "To", "Cc"}
+
+// mboxSource walks an mbox archive message by message, parsing only the
+// header block of each one. Every address in the selected headers is one
+// record; a header that cannot be parsed as an address list is one bad record.
+type mboxSource struct {
+	r       *bufio.Reader
+	headers []string
+	pending []string
+	bad     int
+	started bool
+	eof     bool
+	hdr     bytes.Buffer
+}
+
+func newMboxSource(r *bufio.Reader, headers []string) *mboxSource {
+	if len(headers) == 0 {
+		headers = DefaultMboxHeaders
+	}
+	canon := make([]string, 0, len(headers))
+	for _, h := range headers {
+		if h = strings.TrimSpace(h); h != "" {
+			canon = append(canon, textproto.CanonicalMIMEHeaderKey(h))
+		}
+	}
+	return &mboxSource{r: r, headers: canon}
+}
+
+func (m *mboxSource) Next() (string, bool, error) {
+	for {
+		if m.bad > 0 {
+			m.bad--
+			return "", false, nil
+		}
+		if len(m.pending) > 0 {
+			addr := m.pending[0]
+			m.pending = m.pending[1:]
+			return addr, true, nil
+		}
+		if m.eof {
+			return "", false, io.EOF
+		}
+		if err := m.readMessage(); err != nil {
+			return "", false, err
+		}
+	}
+}
+
+// readMessage consumes the next message (its "From " separator, header block
+// and body) and queues the addresses found in the selected headers.
+func (m *mboxSource) readMessage() error {
+	// Skip to the first separator; anything before it is not a message.
+	for !m.started {
+		line, err := m.r.ReadString('\n')
+		if strings.HasPrefix(line, "From ") {
+			m.started = true
+			break
+		}
+		if err == io.EOF {
+			m.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	m.hdr.Reset()
+	inHeader := true
+	for {
+		line, err := m.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if strings.HasPrefix(line, "From ") {
+			break
+		}
+		if inHeader {
+			if strings.TrimRight(line, "\r\n") == "" {
+				inHeader = false
+			} else {
+				m.hdr.WriteString(line)
+			}
+		}
+		if err == io.EOF {
+			m.eof = true
+			break
+		}
+	}
+
+	m.hdr.WriteString("\r\n")
+	msg, err := mail.ReadMessage(&m.hdr)
+	if err != nil {
+		// A mangled header block still counts once so it shows up in BadRows.
+		m.bad++
+		return nil
+	}
+	for _, name := range m.headers {
+		for _, v := range msg.Header[name] {
+			list, err := mail.ParseAddressList(v)
+			if err != nil {
+				m.bad++
+				continue
+			}
+			for _, a := range list {
+				m.pending = append(m.pending, a.Address)
+			}
+		}
+	}
+	return nil
+}
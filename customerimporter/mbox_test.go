@@ -0,0 +1,74 @@
+package customerimporter
+
+import "testing"
+
+const mboxFixture = "preamble that is not a message\n" +
+	"From alice@x.com Mon Sep 22 10:00:00 2025\n" +
+	"From: Alice <alice@X.com>\n" +
+	"To: bob@y.com, \"Carol, C.\" <carol@y.com>\n" +
+	"Cc: dave@z.com\n" +
+	"Bcc: eve@hidden.com\n" +
+	"Subject: hello\n" +
+	"\n" +
+	"body line\n" +
+	">From the body, not a separator\n" +
+	"\n" +
+	"From bob@y.com Mon Sep 22 11:00:00 2025\n" +
+	"From: =?UTF-8?Q?B=C3=B6b?= <bob@y.com>\n" +
+	"To: undisclosed-recipients:;\n" +
+	"Cc: not an address\n" +
+	"\n" +
+	"From: this is body text, not a header\n"
+
+func TestImporter_Mbox(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    []string
+		total, bad int
+		want       map[string]int
+	}{
+		{
+			name:  "Default_headers",
+			total: 6, bad: 1,
+			want: map[string]int{"x.com": 1, "y.com": 3, "z.com": 1},
+		},
+		{
+			name:    "Selected_headers",
+			headers: []string{"from", " BCC "},
+			total:   3, bad: 0,
+			want: map[string]int{"x.com": 1, "y.com": 1, "hidden.com": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := mustWriteTempFile(t, "archive.mbox", mboxFixture)
+			got, err := New(Config{Path: path, MboxHeaders: tt.headers}).ImportDomainData()
+			if err != nil {
+				t.Fatalf("ImportDomainData error: %v", err)
+			}
+			if got.Stats.TotalRows != tt.total || got.Stats.BadRows != tt.bad {
+				t.Fatalf("stats got=%+v want total=%d bad=%d", got.Stats, tt.total, tt.bad)
+			}
+			if len(got.Data) != len(tt.want) {
+				t.Fatalf("data got=%v want=%v", got.Data, tt.want)
+			}
+			for _, d := range got.Data {
+				if tt.want[d.Domain] != d.CustomerQuantity {
+					t.Errorf("domain %q count got=%d want=%d", d.Domain, d.CustomerQuantity, tt.want[d.Domain])
+				}
+			}
+		})
+	}
+}
+
+func TestImporter_MboxWithoutMessages(t *testing.T) {
+	path := mustWriteTempFile(t, "empty.mbox", "no separator here\n")
+	got, err := New(Config{Path: path}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	if got.Stats.TotalRows != 0 || len(got.Data) != 0 {
+		t.Fatalf("stats=%+v data=%v", got.Stats, got.Data)
+	}
+}
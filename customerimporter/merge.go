@@ -0,0 +1,29 @@
+package customerimporter
+
+// Merge sums the per-domain counts of several results and returns them in
+// result order (count descending, then domain ascending). TotalRows, BadRows
+// and MalformedRows are summed too, as are BadRowsByReason and BytesRead;
+// they stay zero for results that were read from formats without Stats.
+func Merge(results ...Result) Result {
+	var merged Result
+	counts := make(map[string]int)
+	reasons := make(map[string]int)
+	for _, res := range results {
+		for _, d := range res.Data {
+			counts[d.Domain] += d.CustomerQuantity
+		}
+		merged.Stats.TotalRows += res.Stats.TotalRows
+		merged.Stats.BadRows += res.Stats.BadRows
+		merged.Stats.MalformedRows += res.Stats.MalformedRows
+		for reason, n := range res.BadRowsByReason {
+			reasons[reason] += n
+		}
+		merged.BytesRead += res.BytesRead
+	}
+	if len(reasons) > 0 {
+		merged.BadRowsByReason = reasons
+	}
+	merged.Data = makeSortedData(counts)
+	merged.Stats.UniqueDomains = len(merged.Data)
+	return merged
+}
@@ -0,0 +1,52 @@
+package customerimporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	north := Result{
+		Data:            []DomainData{{Domain: "y.com", CustomerQuantity: 5}, {Domain: "x.com", CustomerQuantity: 2}},
+		Stats:           Stats{TotalRows: 8, BadRows: 1, UniqueDomains: 2},
+		BadRowsByReason: map[string]int{RejectInvalidEmail: 1},
+		BytesRead:       100,
+	}
+	south := Result{
+		Data:            []DomainData{{Domain: "z.com", CustomerQuantity: 5}, {Domain: "x.com", CustomerQuantity: 3}},
+		Stats:           Stats{TotalRows: 9, BadRows: 1, MalformedRows: 1, UniqueDomains: 2},
+		BadRowsByReason: map[string]int{ReasonMalformed: 1},
+		BytesRead:       50,
+	}
+
+	tests := []struct {
+		name    string
+		results []Result
+		want    Result
+	}{
+		{
+			name:    "None",
+			results: nil,
+			want:    Result{Data: []DomainData{}},
+		},
+		{
+			name:    "Sums_and_resorts",
+			results: []Result{north, south},
+			want: Result{
+				Data: []DomainData{
+					{Domain: "x.com", CustomerQuantity: 5},
+					{Domain: "y.com", CustomerQuantity: 5},
+					{Domain: "z.com", CustomerQuantity: 5},
+				},
+				Stats:           Stats{TotalRows: 17, BadRows: 2, MalformedRows: 1, UniqueDomains: 3},
+				BadRowsByReason: map[string]int{RejectInvalidEmail: 1, ReasonMalformed: 1},
+				BytesRead:       150,
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := Merge(tt.results...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("[%s] Merge:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package customerimporter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+var ErrEmailColumnType = errors.New("email column is not a string column")
+
+// parquetSource reads a single column chunk by chunk, page by page, so the
+// other columns of the file are never loaded. Repeated columns yield one
+// record per value; nulls are reported as bad records.
+type parquetSource struct {
+	groups []parquet.RowGroup
+	col    int
+	rg     int
+	pages  parquet.Pages
+	page   parquet.Page
+	values parquet.ValueReader
+	buf    []parquet.Value
+	pos, n int
+}
+
+// openParquet resolves column (a dotted path, matched case-insensitively)
+// against the file schema.
+func openParquet(f inputFile, column string) (*parquetSource, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	pf, err := parquet.OpenFile(f, fi.Size())
+	if err != nil {
+		return nil, fmt.Errorf("open parquet: %w", err)
+	}
+
+	want := strings.Split(strings.TrimSpace(column), ".")
+	leaf, ok := pf.Schema().Lookup(want...)
+	if !ok {
+		for _, path := range pf.Schema().Columns() {
+			if equalFoldPath(path, want) {
+				leaf, ok = pf.Schema().Lookup(path...)
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, ErrEmailHeaderMissing
+	}
+	if leaf.Node.Type().Kind() != parquet.ByteArray {
+		return nil, fmt.Errorf("%w: %q is %s", ErrEmailColumnType, column, leaf.Node.Type())
+	}
+
+	return &parquetSource{
+		groups: pf.RowGroups(),
+		col:    leaf.ColumnIndex,
+		buf:    make([]parquet.Value, 1024),
+	}, nil
+}
+
+func equalFoldPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *parquetSource) Next() (string, bool, error) {
+	for {
+		if s.pos < s.n {
+			v := s.buf[s.pos]
+			s.pos++
+			if v.IsNull() {
+				return "", false, nil
+			}
+			return string(v.ByteArray()), true, nil
+		}
+
+		if s.values != nil {
+			n, err := s.values.ReadValues(s.buf)
+			s.pos, s.n = 0, n
+			if err == io.EOF {
+				s.values = nil
+			} else if err != nil {
+				return "", false, fmt.Errorf("read parquet values: %w", err)
+			}
+			continue
+		}
+
+		if s.pages != nil {
+			if s.page != nil {
+				parquet.Release(s.page)
+				s.page = nil
+			}
+			page, err := s.pages.ReadPage()
+			if err == io.EOF {
+				s.pages.Close()
+				s.pages = nil
+				continue
+			}
+			if err != nil {
+				return "", false, fmt.Errorf("read parquet page: %w", err)
+			}
+			s.page = page
+			s.values = page.Values()
+			continue
+		}
+
+		if s.rg >= len(s.groups) {
+			return "", false, io.EOF
+		}
+		s.pages = s.groups[s.rg].ColumnChunks()[s.col].Pages()
+		s.rg++
+	}
+}
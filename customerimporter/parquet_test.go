@@ -0,0 +1,96 @@
+package customerimporter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type parquetContact struct {
+	Email string `parquet:"email"`
+}
+
+type parquetCustomer struct {
+	Name    string         `parquet:"name"`
+	Email   *string        `parquet:"email,optional"`
+	Age     int32          `parquet:"age"`
+	Contact parquetContact `parquet:"contact"`
+}
+
+func strPtr(s string) *string { return &s }
+
+// mustWriteTempParquet writes rows in small row groups and pages so the reader
+// has to cross chunk and page boundaries.
+func mustWriteTempParquet(t *testing.T, rows []parquetCustomer) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "in.parquet")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatalf("create fixture: %v", err)
+	}
+	defer f.Close()
+
+	w := parquet.NewGenericWriter[parquetCustomer](f, parquet.PageBufferSize(64))
+	for start := 0; start < len(rows); start += 3 {
+		end := min(start+3, len(rows))
+		if _, err := w.Write(rows[start:end]); err != nil {
+			t.Fatalf("write rows: %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("flush row group: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close writer: %v", err)
+	}
+	return p
+}
+
+func TestImporter_Parquet(t *testing.T) {
+	var rows []parquetCustomer
+	for i := 0; i < 10; i++ {
+		rows = append(rows, parquetCustomer{Name: "n", Email: strPtr("user@x.com"), Contact: parquetContact{Email: "c@nested.org"}})
+	}
+	rows = append(rows,
+		parquetCustomer{Name: "y", Email: strPtr("u@Y.com"), Contact: parquetContact{Email: "bad"}},
+		parquetCustomer{Name: "null email"},
+		parquetCustomer{Name: "bad", Email: strPtr("nope@")},
+	)
+	path := mustWriteTempParquet(t, rows)
+
+	got, err := New(Config{Path: path, EmailHeader: "EMAIL"}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	if got.Stats.TotalRows != 13 || got.Stats.BadRows != 2 || got.Stats.UniqueDomains != 2 {
+		t.Fatalf("stats=%+v", got.Stats)
+	}
+	want := []DomainData{{Domain: "x.com", CustomerQuantity: 10}, {Domain: "y.com", CustomerQuantity: 1}}
+	for i := range want {
+		if got.Data[i] != want[i] {
+			t.Fatalf("order[%d] got=%v want=%v", i, got.Data[i], want[i])
+		}
+	}
+
+	nested, err := New(Config{Path: path, EmailPath: "contact.email"}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("nested ImportDomainData error: %v", err)
+	}
+	if nested.Stats.TotalRows != 13 || nested.Stats.BadRows != 3 || len(nested.Data) != 1 || nested.Data[0].CustomerQuantity != 10 {
+		t.Fatalf("nested stats=%+v data=%v", nested.Stats, nested.Data)
+	}
+}
+
+func TestImporter_ParquetColumnErrors(t *testing.T) {
+	path := mustWriteTempParquet(t, []parquetCustomer{{Name: "a", Email: strPtr("a@x.com")}})
+
+	if _, err := New(Config{Path: path, EmailHeader: "mail"}).ImportDomainData(); err != ErrEmailHeaderMissing {
+		t.Fatalf("missing column: expected ErrEmailHeaderMissing, got %v", err)
+	}
+	if _, err := New(Config{Path: path, EmailHeader: "age"}).ImportDomainData(); !errors.Is(err, ErrEmailColumnType) {
+		t.Fatalf("int column: expected ErrEmailColumnType, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package customerimporter
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultProgressInterval is how often Config.Progress is called when
+// Config.ProgressInterval is zero.
+const DefaultProgressInterval = 250 * time.Millisecond
+
+// Progress is a snapshot of a running import.
+type Progress struct {
+	BytesRead int64
+	// TotalBytes is the input file size, or the Config.Size hint of a streamed
+	// input (0 if unknown). BytesRead may stay below it for formats that skip
+	// data (Parquet reads only the email column).
+	TotalBytes int64
+	Rows       int
+	BadRows    int
+	Elapsed    time.Duration
+	// Done is set on the final report of a run, including interrupted ones.
+	Done bool
+}
+
+func (p Progress) BytesPerSecond() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesRead) / p.Elapsed.Seconds()
+}
+
+func (p Progress) RowsPerSecond() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Rows) / p.Elapsed.Seconds()
+}
+
+// inputFile is what the random-access formats (XLSX, Parquet, zip) need.
+// *os.File and *trackedFile satisfy it.
+type inputFile interface {
+	io.Reader
+	io.ReaderAt
+	Stat() (os.FileInfo, error)
+}
+
+// trackedInput is an input that counts the bytes read from it, for Progress.
+type trackedInput interface {
+	io.Reader
+	bytesRead() int64
+}
+
+// trackedFile counts the bytes pulled from the input and fails reads once the
+// context is done, so even a parser stuck in a long record stops promptly.
+type trackedFile struct {
+	*os.File
+	ctx context.Context
+	n   atomic.Int64
+}
+
+func (t *trackedFile) Read(p []byte) (int, error) {
+	if err := t.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := t.File.Read(p)
+	t.n.Add(int64(n))
+	return n, err
+}
+
+func (t *trackedFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := t.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := t.File.ReadAt(p, off)
+	t.n.Add(int64(n))
+	return n, err
+}
+
+func (t *trackedFile) bytesRead() int64 { return t.n.Load() }
+
+// trackedReader is trackedFile for a streamed input such as a request body.
+type trackedReader struct {
+	r   io.Reader
+	ctx context.Context
+	n   atomic.Int64
+}
+
+func (t *trackedReader) Read(p []byte) (int, error) {
+	if err := t.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := t.r.Read(p)
+	t.n.Add(int64(n))
+	return n, err
+}
+
+func (t *trackedReader) bytesRead() int64 { return t.n.Load() }
+
+// checkEvery is how many records pass between context checks and progress
+// reports; it keeps the per-row cost to a counter increment.
+const checkEvery = 4096
+
+// run carries per-import state shared by every file of an import, including
+// all members of an archive.
+type run struct {
+	ctx      context.Context
+	in       trackedInput
+	total    int64
+	start    time.Time
+	next     time.Time
+	interval time.Duration
+	report   func(Progress)
+	rows     int
+	bad      int
+	// rowErrors collects skipped malformed records for Result.RowErrors.
+	rowErrors []RowError
+	rejected  []RejectedRow
+	reasons   map[string]int
+	timings   Timings
+}
+
+func (i *Importer) newRun(ctx context.Context, in trackedInput, total int64) *run {
+	interval := i.cfg.ProgressInterval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	now := time.Now()
+	return &run{
+		ctx:      ctx,
+		in:       in,
+		total:    total,
+		start:    now,
+		next:     now.Add(interval),
+		interval: interval,
+		report:   i.cfg.Progress,
+		reasons:  make(map[string]int),
+	}
+}
+
+// record updates the shared counters and, every checkEvery rows, checks for
+// cancellation and reports progress if the interval has passed.
+func (r *run) record(bad bool) error {
+	r.rows++
+	if bad {
+		r.bad++
+	}
+	if r.rows%checkEvery != 0 {
+		return nil
+	}
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+	if r.report != nil {
+		if now := time.Now(); !now.Before(r.next) {
+			r.next = now.Add(r.interval)
+			r.report(r.snapshot(false))
+		}
+	}
+	return nil
+}
+
+func (r *run) snapshot(done bool) Progress {
+	return Progress{
+		BytesRead:  r.in.bytesRead(),
+		TotalBytes: r.total,
+		Rows:       r.rows,
+		BadRows:    r.bad,
+		Elapsed:    time.Since(r.start),
+		Done:       done,
+	}
+}
+
+func (r *run) finish() {
+	if r.report != nil {
+		r.report(r.snapshot(true))
+	}
+}
@@ -0,0 +1,117 @@
+package customerimporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bigCSV(rows int) string {
+	var sb strings.Builder
+	sb.WriteString("email\n")
+	for n := 0; n < rows; n++ {
+		fmt.Fprintf(&sb, "user%d@d%d.com\n", n, n%7)
+	}
+	return sb.String()
+}
+
+func TestImportContext_ProgressReports(t *testing.T) {
+	path := mustWriteTempCSV(t, bigCSV(3*checkEvery))
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []Progress
+	imp := New(Config{
+		Path:             path,
+		EmailHeader:      "email",
+		ProgressInterval: time.Nanosecond,
+		Progress:         func(p Progress) { reports = append(reports, p) },
+	})
+	got, err := imp.ImportDomainDataContext(context.Background())
+	if err != nil {
+		t.Fatalf("ImportDomainDataContext error: %v", err)
+	}
+	if got.Stats.TotalRows != 3*checkEvery {
+		t.Fatalf("stats=%+v", got.Stats)
+	}
+
+	if len(reports) < 2 {
+		t.Fatalf("expected periodic and final reports, got %d", len(reports))
+	}
+	for n := 1; n < len(reports); n++ {
+		if reports[n].Rows < reports[n-1].Rows || reports[n].BytesRead < reports[n-1].BytesRead {
+			t.Fatalf("progress went backwards: %+v then %+v", reports[n-1], reports[n])
+		}
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Rows != 3*checkEvery || last.BytesRead != fi.Size() || last.TotalBytes != fi.Size() {
+		t.Fatalf("final report=%+v size=%d", last, fi.Size())
+	}
+	if reports[0].Done {
+		t.Fatalf("first report should not be final: %+v", reports[0])
+	}
+}
+
+func TestImportContext_CancelReturnsPartialResult(t *testing.T) {
+	const total = 10 * checkEvery
+	path := mustWriteTempCSV(t, bigCSV(total))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	imp := New(Config{
+		Path:             path,
+		EmailHeader:      "email",
+		ProgressInterval: time.Nanosecond,
+		Progress: func(p Progress) {
+			if p.Rows >= 2*checkEvery {
+				cancel()
+			}
+		},
+	})
+	got, err := imp.ImportDomainDataContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if got.Stats.TotalRows == 0 || got.Stats.TotalRows >= total {
+		t.Fatalf("expected a partial count, got %+v", got.Stats)
+	}
+	if len(got.Data) == 0 || got.Stats.UniqueDomains != len(got.Data) {
+		t.Fatalf("expected partial data, got stats=%+v data=%v", got.Stats, got.Data)
+	}
+}
+
+func TestImportContext_AlreadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tc := range []struct{ name, body string }{
+		{"csv.csv", "email\na@x.com\n"},
+		{"in.ndjson", `{"email":"a@x.com"}`},
+	} {
+		path := mustWriteTempFile(t, tc.name, tc.body)
+		got, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainDataContext(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("[%s] expected context.Canceled, got %v", tc.name, err)
+		}
+		if got.Stats.TotalRows != 0 {
+			t.Fatalf("[%s] stats=%+v", tc.name, got.Stats)
+		}
+	}
+}
+
+func TestProgressRates(t *testing.T) {
+	p := Progress{BytesRead: 2000, Rows: 50, Elapsed: 2 * time.Second}
+	if p.BytesPerSecond() != 1000 || p.RowsPerSecond() != 25 {
+		t.Fatalf("rates = %v B/s, %v rows/s", p.BytesPerSecond(), p.RowsPerSecond())
+	}
+	if (Progress{}).BytesPerSecond() != 0 {
+		t.Fatalf("zero elapsed must not divide by zero")
+	}
+}
@@ -0,0 +1,86 @@
+package customerimporter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEmailFieldMissing = errors.New("record has no email field")
+	ErrBadRowThreshold   = errors.New("bad row threshold exceeded")
+)
+
+// MaxRecordedRowErrors caps Result.RowErrors so a badly broken file cannot
+// grow it without bound; Stats.MalformedRows keeps the full count.
+const MaxRecordedRowErrors = 100
+
+// MalformedPolicy decides what happens to records that cannot be parsed or
+// are too short to hold the email field.
+type MalformedPolicy int
+
+const (
+	// MalformedDefault aborts on unparsable CSV, while short rows and broken
+	// NDJSON lines are counted as bad rows.
+	MalformedDefault MalformedPolicy = iota
+	// MalformedStrict aborts on the first malformed record of any kind.
+	MalformedStrict
+	// MalformedSkip counts every malformed record as a bad row, records it in
+	// Result.RowErrors and carries on.
+	MalformedSkip
+)
+
+// RowError locates a malformed record. Line is 1-based; Column is the 1-based
+// byte position within the line, or 0 when it does not apply.
+type RowError struct {
+	Line   int
+	Column int
+	Err    error
+	// skippable marks records the default policy counts as bad rather than
+	// aborting on.
+	skippable bool
+}
+
+func (e *RowError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// asRowError turns a csv.ParseError into a RowError; other errors pass through.
+func asRowError(err error) error {
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		return &RowError{Line: pe.Line, Column: pe.Column, Err: pe.Err}
+	}
+	return err
+}
+
+// skipMalformed reports whether the import carries on past rowErr.
+func (i *Importer) skipMalformed(rowErr *RowError) bool {
+	switch i.cfg.Malformed {
+	case MalformedStrict:
+		return false
+	case MalformedSkip:
+		return true
+	}
+	return rowErr.skippable
+}
+
+// CheckBadRows returns an error wrapping ErrBadRowThreshold when s has more
+// bad rows than maxRows or a larger bad share than maxRatio (0..1). A negative
+// limit disables that check.
+func CheckBadRows(s Stats, maxRows int, maxRatio float64) error {
+	if maxRows >= 0 && s.BadRows > maxRows {
+		return fmt.Errorf("%w: %d bad rows, limit %d", ErrBadRowThreshold, s.BadRows, maxRows)
+	}
+	if maxRatio >= 0 && s.TotalRows > 0 {
+		if ratio := float64(s.BadRows) / float64(s.TotalRows); ratio > maxRatio {
+			return fmt.Errorf("%w: %.2f%% bad rows, limit %.2f%%", ErrBadRowThreshold, ratio*100, maxRatio*100)
+		}
+	}
+	return nil
+}
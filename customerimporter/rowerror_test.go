@@ -0,0 +1,147 @@
+package customerimporter
+
+import (
+	"encoding/csv"
+	"errors"
+	"testing"
+)
+
+// Line 3 has a bare quote, line 5 is too short for the email column.
+const malformedCSV = "name,email\n" +
+	"A,a@x.com\n" +
+	"B\"ad,b@x.com\n" +
+	"C,c@y.com\n" +
+	"D\n" +
+	"E,e@y.com\n"
+
+func TestImporter_MalformedPolicies(t *testing.T) {
+	t.Run("Default_aborts_on_parse_error_with_position", func(t *testing.T) {
+		path := mustWriteTempCSV(t, malformedCSV)
+		_, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData()
+		var rowErr *RowError
+		if !errors.As(err, &rowErr) {
+			t.Fatalf("expected *RowError, got %T %v", err, err)
+		}
+		if rowErr.Line != 3 || rowErr.Column != 2 || !errors.Is(err, csv.ErrBareQuote) {
+			t.Fatalf("row error = %+v (%v)", rowErr, err)
+		}
+		if err.Error() != `line 3, column 2: bare " in non-quoted-field` {
+			t.Fatalf("message = %q", err.Error())
+		}
+	})
+
+	t.Run("Default_counts_short_rows", func(t *testing.T) {
+		path := mustWriteTempCSV(t, "name,email\nA,a@x.com\nD\n")
+		got, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData()
+		if err != nil {
+			t.Fatalf("ImportDomainData error: %v", err)
+		}
+		if got.Stats.BadRows != 1 || got.Stats.MalformedRows != 1 || len(got.RowErrors) != 1 || got.RowErrors[0].Line != 3 {
+			t.Fatalf("stats=%+v row errors=%+v", got.Stats, got.RowErrors)
+		}
+	})
+
+	t.Run("Strict_aborts_on_short_row", func(t *testing.T) {
+		path := mustWriteTempCSV(t, "name,email\nA,a@x.com\nD\nE,e@y.com\n")
+		got, err := New(Config{Path: path, EmailHeader: "email", Malformed: MalformedStrict}).ImportDomainData()
+		var rowErr *RowError
+		if !errors.As(err, &rowErr) || rowErr.Line != 3 || !errors.Is(err, ErrEmailFieldMissing) {
+			t.Fatalf("expected short-row RowError on line 3, got %v", err)
+		}
+		if got.Stats.TotalRows != 1 {
+			t.Fatalf("stats=%+v", got.Stats)
+		}
+	})
+
+	t.Run("Skip_records_and_continues", func(t *testing.T) {
+		path := mustWriteTempCSV(t, malformedCSV)
+		got, err := New(Config{Path: path, EmailHeader: "email", Malformed: MalformedSkip}).ImportDomainData()
+		if err != nil {
+			t.Fatalf("ImportDomainData error: %v", err)
+		}
+		want := Stats{TotalRows: 5, BadRows: 2, MalformedRows: 2, UniqueDomains: 2}
+		if got.Stats != want {
+			t.Fatalf("stats=%+v want %+v", got.Stats, want)
+		}
+		if len(got.RowErrors) != 2 || got.RowErrors[0].Line != 3 || got.RowErrors[1].Line != 5 {
+			t.Fatalf("row errors=%+v", got.RowErrors)
+		}
+		if !errors.Is(got.RowErrors[0].Err, csv.ErrBareQuote) || !errors.Is(got.RowErrors[1].Err, ErrEmailFieldMissing) {
+			t.Fatalf("row error causes=%v, %v", got.RowErrors[0].Err, got.RowErrors[1].Err)
+		}
+	})
+}
+
+func TestImporter_MalformedHeaderHasPosition(t *testing.T) {
+	path := mustWriteTempCSV(t, "na\"me,email\na@x.com\n")
+	_, err := New(Config{Path: path, EmailHeader: "email", Malformed: MalformedSkip}).ImportDomainData()
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 1 {
+		t.Fatalf("expected RowError on line 1, got %v", err)
+	}
+}
+
+func TestImporter_MalformedNDJSON(t *testing.T) {
+	body := "{\"email\":\"a@x.com\"}\n\n{broken\n{\"email\":\"b@x.com\"}\n"
+	path := mustWriteTempFile(t, "in.ndjson", body)
+
+	got, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData()
+	if err != nil || got.Stats.MalformedRows != 1 || got.RowErrors[0].Line != 3 {
+		t.Fatalf("default: err=%v stats=%+v row errors=%+v", err, got.Stats, got.RowErrors)
+	}
+
+	_, err = New(Config{Path: path, EmailHeader: "email", Malformed: MalformedStrict}).ImportDomainData()
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 3 {
+		t.Fatalf("strict: expected RowError on line 3, got %v", err)
+	}
+}
+
+func TestImporter_MalformedXLSXReportsSheetRow(t *testing.T) {
+	path := mustWriteTempXLSX(t)
+	// Row 5 of the Customers sheet only has column A.
+	_, err := New(Config{Path: path, EmailHeader: "email", Sheet: "Customers", Malformed: MalformedStrict}).ImportDomainData()
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 5 {
+		t.Fatalf("expected RowError on sheet row 5, got %v", err)
+	}
+}
+
+func TestImporter_RowErrorSamplesAreCapped(t *testing.T) {
+	body := "name,email\n"
+	for n := 0; n < MaxRecordedRowErrors+20; n++ {
+		body += "short\n"
+	}
+	path := mustWriteTempCSV(t, body)
+	got, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	if got.Stats.MalformedRows != MaxRecordedRowErrors+20 || len(got.RowErrors) != MaxRecordedRowErrors {
+		t.Fatalf("malformed=%d recorded=%d", got.Stats.MalformedRows, len(got.RowErrors))
+	}
+}
+
+func TestCheckBadRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		stats    Stats
+		maxRows  int
+		maxRatio float64
+		exceeded bool
+	}{
+		{"Disabled", Stats{TotalRows: 10, BadRows: 10}, -1, -1, false},
+		{"Rows_at_limit", Stats{TotalRows: 10, BadRows: 2}, 2, -1, false},
+		{"Rows_over_limit", Stats{TotalRows: 10, BadRows: 3}, 2, -1, true},
+		{"Zero_allowed", Stats{TotalRows: 10, BadRows: 1}, 0, -1, true},
+		{"Ratio_at_limit", Stats{TotalRows: 10, BadRows: 1}, -1, 0.1, false},
+		{"Ratio_over_limit", Stats{TotalRows: 10, BadRows: 2}, -1, 0.1, true},
+		{"Ratio_empty_input", Stats{}, -1, 0, false},
+	}
+	for _, tt := range tests {
+		err := CheckBadRows(tt.stats, tt.maxRows, tt.maxRatio)
+		if got := errors.Is(err, ErrBadRowThreshold); got != tt.exceeded {
+			t.Fatalf("[%s] CheckBadRows err=%v; want exceeded=%v", tt.name, err, tt.exceeded)
+		}
+	}
+}
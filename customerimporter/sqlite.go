@@ -0,0 +1,88 @@
+package customerimporter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+var ErrQueryMissing = errors.New("a SELECT query is required for SQLite input")
+
+// sqliteSource iterates the rows of a user-supplied query. The database is
+// opened read-only, so the query cannot modify it.
+type sqliteSource struct {
+	db   *sql.DB
+	rows *sql.Rows
+	idx  int
+	dest []any
+	vals []sql.RawBytes
+}
+
+func openSQLite(ctx context.Context, path, query, column string, position int) (*sqliteSource, error) {
+	if query == "" {
+		return nil, ErrQueryMissing
+	}
+
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?mode=ro"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite %q: %w", path, err)
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("run sqlite query: %w", err)
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		db.Close()
+		return nil, fmt.Errorf("read sqlite columns: %w", err)
+	}
+
+	idx := position - 1
+	if position <= 0 {
+		idx = findHeaderIndex(cols, column)
+	}
+	if idx < 0 || idx >= len(cols) {
+		rows.Close()
+		db.Close()
+		return nil, ErrEmailHeaderMissing
+	}
+
+	s := &sqliteSource{db: db, rows: rows, idx: idx, vals: make([]sql.RawBytes, len(cols))}
+	s.dest = make([]any, len(cols))
+	for i := range s.vals {
+		s.dest[i] = &s.vals[i]
+	}
+	return s, nil
+}
+
+func (s *sqliteSource) Next() (string, bool, error) {
+	if !s.rows.Next() {
+		if err := s.rows.Err(); err != nil {
+			return "", false, fmt.Errorf("read sqlite rows: %w", err)
+		}
+		return "", false, io.EOF
+	}
+	if err := s.rows.Scan(s.dest...); err != nil {
+		return "", false, fmt.Errorf("scan sqlite row: %w", err)
+	}
+	v := s.vals[s.idx]
+	if v == nil {
+		return "", false, nil
+	}
+	return string(v), true, nil
+}
+
+func (s *sqliteSource) Close() error {
+	s.rows.Close()
+	return s.db.Close()
+}
@@ -0,0 +1,100 @@
+package customerimporter
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func mustWriteTempSQLite(t *testing.T, stmts ...string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "customers.db")
+	db, err := sql.Open("sqlite", p)
+	if err != nil {
+		t.Fatalf("open fixture db: %v", err)
+	}
+	defer db.Close()
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatalf("exec %q: %v", s, err)
+		}
+	}
+	return p
+}
+
+func TestImporter_SQLite(t *testing.T) {
+	path := mustWriteTempSQLite(t,
+		`CREATE TABLE customers (id INTEGER, name TEXT, Email TEXT, region TEXT)`,
+		`INSERT INTO customers VALUES
+			(1, 'a', 'a@x.com', 'eu'),
+			(2, 'b', 'B@X.COM', 'eu'),
+			(3, 'c', 'c@y.com', 'us'),
+			(4, 'd', NULL, 'eu'),
+			(5, 'e', 'broken', 'eu')`,
+	)
+
+	tests := []struct {
+		name       string
+		cfg        Config
+		total, bad int
+		want       []DomainData
+	}{
+		{
+			name:  "Named_column_case_insensitive",
+			cfg:   Config{Query: "SELECT * FROM customers", EmailHeader: "email"},
+			total: 5, bad: 2,
+			want: []DomainData{{Domain: "x.com", CustomerQuantity: 2}, {Domain: "y.com", CustomerQuantity: 1}},
+		},
+		{
+			name:  "Filtered_and_aliased",
+			cfg:   Config{Query: "SELECT email AS contact FROM customers WHERE region = 'eu'", EmailHeader: "contact"},
+			total: 4, bad: 2,
+			want: []DomainData{{Domain: "x.com", CustomerQuantity: 2}},
+		},
+		{
+			name:  "Positional_column",
+			cfg:   Config{Query: "SELECT id, email FROM customers", EmailColumn: 2},
+			total: 5, bad: 2,
+			want: []DomainData{{Domain: "x.com", CustomerQuantity: 2}, {Domain: "y.com", CustomerQuantity: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Path = path
+			got, err := New(tt.cfg).ImportDomainData()
+			if err != nil {
+				t.Fatalf("ImportDomainData error: %v", err)
+			}
+			if got.Stats.TotalRows != tt.total || got.Stats.BadRows != tt.bad {
+				t.Fatalf("stats got=%+v want total=%d bad=%d", got.Stats, tt.total, tt.bad)
+			}
+			if len(got.Data) != len(tt.want) {
+				t.Fatalf("data got=%v want=%v", got.Data, tt.want)
+			}
+			for i := range tt.want {
+				if got.Data[i] != tt.want[i] {
+					t.Fatalf("order[%d] got=%v want=%v", i, got.Data[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestImporter_SQLiteErrors(t *testing.T) {
+	path := mustWriteTempSQLite(t, `CREATE TABLE customers (email TEXT)`)
+
+	if _, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData(); err != ErrQueryMissing {
+		t.Fatalf("expected ErrQueryMissing, got %v", err)
+	}
+	if _, err := New(Config{Path: path, Query: "SELECT email AS mail FROM customers", EmailHeader: "email"}).ImportDomainData(); err != ErrEmailHeaderMissing {
+		t.Fatalf("expected ErrEmailHeaderMissing, got %v", err)
+	}
+	if _, err := New(Config{Path: path, Query: "SELECT nope FROM customers", EmailHeader: "email"}).ImportDomainData(); err == nil {
+		t.Fatalf("expected error for invalid query, got nil")
+	}
+	// The database is opened read-only.
+	if _, err := New(Config{Path: path, Query: "DELETE FROM customers RETURNING email", EmailHeader: "email"}).ImportDomainData(); err == nil {
+		t.Fatalf("expected error for write query, got nil")
+	}
+}
@@ -0,0 +1,243 @@
+package customerimporter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrStateUnsupported is returned when Config.StatePath is set for an input
+// that cannot be resumed from a byte offset.
+var ErrStateUnsupported = errors.New("incremental state is only supported for UTF-8 CSV input")
+
+// stateVersion is bumped when the state file layout changes; older files are
+// ignored and the input is recounted. Version 2 hashes the whole prefix.
+const stateVersion = 2
+
+// Incremental reports how Config.StatePath was used by an import.
+type Incremental struct {
+	// Resumed is set when the saved counts were reused and only the bytes
+	// after Offset were read.
+	Resumed bool
+	// Offset is where reading started: the saved offset when resumed, else 0.
+	Offset int64
+	// End is the offset saved for the next run: just past the last complete
+	// line. A trailing line without a newline is left for the next run.
+	End int64
+	// Reason explains a full recount although a state file existed.
+	Reason string
+}
+
+// state is the checkpoint written to Config.StatePath after a successful
+// import.
+type state struct {
+	Version int `json:"version"`
+	// Offset is the end of the consumed prefix, always just past a newline.
+	Offset int64 `json:"offset"`
+	// Lines is the number of lines in the prefix, for RowError line numbers.
+	Lines int `json:"lines"`
+	// Fingerprint is the hex SHA-256 of the whole prefix.
+	Fingerprint string         `json:"fingerprint"`
+	Options     string         `json:"options"`
+	EmailIndex  int            `json:"email_index"`
+	Stats       Stats          `json:"stats"`
+	Reasons     map[string]int `json:"bad_rows_by_reason,omitempty"`
+	Counts      map[string]int `json:"counts"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// stateOptions renders the settings that change what a prefix counts to. A
+// state saved with different ones is not reused.
+func (i *Importer) stateOptions() string {
+	return fmt.Sprintf("email_header=%s;email_column=%d;no_header=%t;allow_single_label_domain=%t;malformed=%d",
+		strings.ToLower(strings.TrimSpace(i.cfg.EmailHeader)), i.cfg.EmailColumn, i.cfg.NoHeader,
+		i.cfg.AllowSingleLabelDomain, i.cfg.Malformed)
+}
+
+// importIncremental counts a CSV input that only grows at the end. The counts
+// and offset saved by the previous run are reused when the input still starts
+// with the same prefix; otherwise the whole input is counted.
+func (i *Importer) importIncremental(rn *run, f *trackedFile, size int64, counts map[string]int, res *Result) error {
+	switch enc := strings.ToLower(strings.TrimSpace(i.cfg.Encoding)); enc {
+	case "", "auto", "utf-8", "utf8":
+	default:
+		return fmt.Errorf("%w: encoding %q", ErrStateUnsupported, i.cfg.Encoding)
+	}
+	head := make([]byte, len(bomUTF16LE))
+	if n, _ := f.File.ReadAt(head, 0); bytes.Equal(head[:n], bomUTF16LE) || bytes.Equal(head[:n], bomUTF16BE) {
+		return fmt.Errorf("%w: UTF-16 input", ErrStateUnsupported)
+	}
+
+	start := time.Now()
+	end, err := lastLineEnd(f.File, size)
+	if err != nil {
+		return err
+	}
+	inc := Incremental{End: end}
+	// h hashes the input as it is read: the saved prefix while it is
+	// checked, then the lines counted now, so that the next fingerprint
+	// covers [0, end) without reading anything twice.
+	h := sha256.New()
+	st, reason := i.loadState(f.File, end, h)
+	inc.Reason = reason
+	if st == nil {
+		h.Reset()
+	}
+
+	var (
+		rows     rowReader
+		idx      int
+		lineBase int
+	)
+	if st != nil {
+		inc.Resumed = true
+		inc.Offset = st.Offset
+		for d, n := range st.Counts {
+			counts[d] = n
+		}
+		res.Stats = st.Stats
+		for reason, n := range st.Reasons {
+			rn.reasons[reason] = n
+		}
+		idx = st.EmailIndex
+		lineBase = st.Lines
+	}
+
+	lines := &lineCounter{r: io.TeeReader(io.NewSectionReader(f, inc.Offset, end-inc.Offset), h)}
+	rows, err = i.csvRows(lines)
+	if err == nil && !inc.Resumed {
+		idx, err = i.emailIndex(rows)
+	}
+	rn.timings.Open += time.Since(start)
+	res.Incremental = inc
+	if err != nil {
+		return err
+	}
+
+	start = time.Now()
+	firstErr := len(rn.rowErrors)
+	err = i.countSource(rn, &columnSource{rows: rows, idx: idx}, counts, &res.Stats)
+	rn.timings.Parse += time.Since(start)
+	for k := firstErr; k < len(rn.rowErrors); k++ {
+		rn.rowErrors[k].Line += lineBase
+	}
+	if err != nil {
+		return err
+	}
+
+	next := state{
+		Version:     stateVersion,
+		Offset:      end,
+		Lines:       lineBase + lines.n,
+		Fingerprint: hex.EncodeToString(h.Sum(nil)),
+		Options:     i.stateOptions(),
+		EmailIndex:  idx,
+		Stats:       res.Stats,
+		Reasons:     rn.reasons,
+		Counts:      counts,
+		UpdatedAt:   time.Now().UTC(),
+	}
+	next.Stats.UniqueDomains = len(counts)
+	return writeState(i.cfg.StatePath, &next)
+}
+
+// loadState returns the saved state if it can be resumed for an input whose
+// complete lines end at end. Otherwise it returns nil and, if a state file
+// existed, the reason it was not used. Checking the prefix writes it to h.
+func (i *Importer) loadState(f io.ReaderAt, end int64, h hash.Hash) (*state, string) {
+	b, err := os.ReadFile(i.cfg.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ""
+	}
+	if err != nil {
+		return nil, fmt.Sprintf("cannot read state: %v", err)
+	}
+
+	var st state
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Sprintf("cannot parse state: %v", err)
+	}
+	switch {
+	case st.Version != stateVersion:
+		return nil, fmt.Sprintf("state version %d, want %d", st.Version, stateVersion)
+	case st.Options != i.stateOptions():
+		return nil, "import options changed"
+	case st.Offset > end:
+		return nil, "input is shorter than the counted prefix"
+	}
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, st.Offset)); err != nil {
+		return nil, fmt.Sprintf("cannot fingerprint input: %v", err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != st.Fingerprint {
+		return nil, "input changed within the counted prefix"
+	}
+	if st.Counts == nil {
+		st.Counts = make(map[string]int)
+	}
+	return &st, ""
+}
+
+// writeState replaces the state file atomically, so an interrupted write
+// leaves the previous checkpoint in place.
+func writeState(path string, st *state) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	return nil
+}
+
+// lastLineEnd returns the offset just past the last newline in the first size
+// bytes of f, or 0 if there is none.
+func lastLineEnd(f io.ReaderAt, size int64) (int64, error) {
+	buf := make([]byte, 64<<10)
+	for end := size; end > 0; {
+		start := max(0, end-int64(len(buf)))
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, fmt.Errorf("find last line: %w", err)
+		}
+		if k := bytes.LastIndexByte(chunk, '\n'); k >= 0 {
+			return start + int64(k) + 1, nil
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// lineCounter counts the newlines read through it. The section it wraps ends
+// just past a newline, so once it is drained n is the number of lines.
+type lineCounter struct {
+	r io.Reader
+	n int
+}
+
+func (l *lineCounter) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += bytes.Count(p[:n], []byte{'\n'})
+	return n, err
+}
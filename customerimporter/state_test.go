@@ -0,0 +1,221 @@
+package customerimporter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImporter_IncrementalResume(t *testing.T) {
+	path := mustWriteTempCSV(t, "email\na@x.com\nb@y.com\nc@x.co")
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	cfg := Config{Path: path, EmailHeader: "email", StatePath: statePath}
+
+	// The unterminated last line is left for the next run.
+	first, err := New(cfg).ImportDomainData()
+	if err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if want := (Incremental{End: 22}); first.Incremental != want {
+		t.Fatalf("first run Incremental = %+v, want %+v", first.Incremental, want)
+	}
+	if first.Stats.TotalRows != 2 {
+		t.Fatalf("first run TotalRows = %d, want 2", first.Stats.TotalRows)
+	}
+
+	appendFile(t, path, "m\nd@y.com\nnot-an-email\n")
+	second, err := New(cfg).ImportDomainData()
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if !second.Incremental.Resumed || second.Incremental.Offset != 22 || second.Incremental.Reason != "" {
+		t.Fatalf("second run Incremental = %+v, want resumed from 22", second.Incremental)
+	}
+
+	full, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("full count: %v", err)
+	}
+	if !reflect.DeepEqual(second.Data, full.Data) || second.Stats != full.Stats {
+		t.Fatalf("resumed result %+v %+v, full count %+v %+v", second.Data, second.Stats, full.Data, full.Stats)
+	}
+	if len(second.Rejected) != 0 {
+		t.Fatalf("Rejected = %+v, want none without RejectedSample", second.Rejected)
+	}
+
+	// Nothing new: the saved counts are returned as they are.
+	third, err := New(cfg).ImportDomainData()
+	if err != nil {
+		t.Fatalf("third run: %v", err)
+	}
+	if !third.Incremental.Resumed || !reflect.DeepEqual(third.Data, full.Data) || third.Stats != full.Stats ||
+		!reflect.DeepEqual(third.BadRowsByReason, full.BadRowsByReason) {
+		t.Fatalf("third run = %+v", third)
+	}
+}
+
+func TestImporter_IncrementalFallback(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, path string, cfg *Config)
+		reason string
+	}{
+		{
+			name: "Prefix_rewritten",
+			change: func(t *testing.T, path string, cfg *Config) {
+				if err := os.WriteFile(path, []byte("email\na@z.com\nb@y.com\nc@x.com\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reason: "input changed within the counted prefix",
+		},
+		{
+			name: "Truncated",
+			change: func(t *testing.T, path string, cfg *Config) {
+				if err := os.WriteFile(path, []byte("email\na@x.com\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reason: "input is shorter than the counted prefix",
+		},
+		{
+			name: "Options_changed",
+			change: func(t *testing.T, path string, cfg *Config) {
+				cfg.AllowSingleLabelDomain = true
+			},
+			reason: "import options changed",
+		},
+		{
+			name: "Corrupt_state",
+			change: func(t *testing.T, path string, cfg *Config) {
+				if err := os.WriteFile(cfg.StatePath, []byte("{"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reason: "cannot parse state",
+		},
+	}
+
+	for _, tt := range tests {
+		path := mustWriteTempCSV(t, "email\na@x.com\nb@y.com\n")
+		cfg := Config{Path: path, EmailHeader: "email", StatePath: filepath.Join(t.TempDir(), "state.json")}
+		if _, err := New(cfg).ImportDomainData(); err != nil {
+			t.Fatalf("[%s] first run: %v", tt.name, err)
+		}
+
+		tt.change(t, path, &cfg)
+		got, err := New(cfg).ImportDomainData()
+		if err != nil {
+			t.Fatalf("[%s] second run: %v", tt.name, err)
+		}
+		if got.Incremental.Resumed || !strings.HasPrefix(got.Incremental.Reason, tt.reason) {
+			t.Fatalf("[%s] Incremental = %+v, want a recount because %q", tt.name, got.Incremental, tt.reason)
+		}
+		cfg.StatePath = ""
+		want, err := New(cfg).ImportDomainData()
+		if err != nil {
+			t.Fatalf("[%s] full count: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got.Data, want.Data) || got.Stats != want.Stats {
+			t.Fatalf("[%s] recount %+v %+v, want %+v %+v", tt.name, got.Data, got.Stats, want.Data, want.Stats)
+		}
+	}
+}
+
+// TestImporter_IncrementalMiddleEdit changes one byte in the middle of a
+// prefix of several MiB, keeping its length, and expects a recount.
+func TestImporter_IncrementalMiddleEdit(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("email\n")
+	for b.Len() < 3<<20 {
+		b.WriteString("someone@x.com\n")
+	}
+	content := b.String()
+	path := mustWriteTempCSV(t, content)
+	cfg := Config{Path: path, EmailHeader: "email", StatePath: filepath.Join(t.TempDir(), "state.json")}
+	if _, err := New(cfg).ImportDomainData(); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+
+	mid := strings.Index(content[len(content)/2:], "@x.com") + len(content)/2
+	edited := content[:mid] + "@y.com" + content[mid+len("@y.com"):]
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := New(cfg).ImportDomainData()
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if got.Incremental.Resumed || got.Incremental.Reason != "input changed within the counted prefix" {
+		t.Fatalf("Incremental = %+v, want a recount", got.Incremental)
+	}
+	if len(got.Data) != 2 || got.Data[1] != (DomainData{Domain: "y.com", CustomerQuantity: 1}) {
+		t.Fatalf("data = %v", got.Data)
+	}
+}
+
+func TestImporter_IncrementalRowErrorLines(t *testing.T) {
+	path := mustWriteTempCSV(t, "id,email\n1,a@x.com\n")
+	cfg := Config{Path: path, EmailHeader: "email", StatePath: filepath.Join(t.TempDir(), "state.json"), Malformed: MalformedSkip}
+	if _, err := New(cfg).ImportDomainData(); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+
+	appendFile(t, path, "2,b@x.com\n3\n")
+	got, err := New(cfg).ImportDomainData()
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if len(got.RowErrors) != 1 || got.RowErrors[0].Line != 4 {
+		t.Fatalf("RowErrors = %+v, want one on line 4", got.RowErrors)
+	}
+	if got.Stats.TotalRows != 3 || got.Stats.MalformedRows != 1 {
+		t.Fatalf("Stats = %+v", got.Stats)
+	}
+}
+
+func TestImporter_IncrementalUnsupported(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{
+			name: "NDJSON",
+			cfg:  Config{Path: mustWriteTempCSV(t, `{"email":"a@x.com"}`+"\n"), Format: FormatNDJSON},
+		},
+		{
+			name: "Latin1",
+			cfg:  Config{Path: mustWriteTempCSV(t, "email\na@x.com\n"), Encoding: "latin1"},
+		},
+		{
+			name: "UTF16",
+			cfg:  Config{Path: mustWriteTempCSV(t, "\xff\xfee\x00\n\x00")},
+		},
+	}
+	for _, tt := range tests {
+		tt.cfg.EmailHeader = "email"
+		tt.cfg.StatePath = statePath
+		if _, err := New(tt.cfg).ImportDomainData(); !errors.Is(err, ErrStateUnsupported) {
+			t.Errorf("[%s] error = %v, want ErrStateUnsupported", tt.name, err)
+		}
+	}
+	if _, err := os.Stat(statePath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("state written for unsupported input: %v", err)
+	}
+}
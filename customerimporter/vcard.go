@@ -0,0 +1,95 @@
+package customerimporter
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// vcardSource yields the value of every EMAIL property in a .vcf file,
+// including grouped ones such as "item1.EMAIL;TYPE=INTERNET:a@b.com".
+type vcardSource struct {
+	lines *unfoldReader
+}
+
+func (v *vcardSource) Next() (string, bool, error) {
+	for {
+		line, err := v.lines.ReadLine()
+		if err != nil {
+			return "", false, err
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			continue
+		}
+		name := line[:colon]
+		if semi := strings.IndexByte(name, ';'); semi >= 0 {
+			name = name[:semi]
+		}
+		if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+			name = name[dot+1:]
+		}
+		if !strings.EqualFold(name, "EMAIL") {
+			continue
+		}
+		return line[colon+1:], true, nil
+	}
+}
+
+// unfoldReader returns logical lines from formats that fold long lines by
+// starting the continuation with a single space or tab (vCard, LDIF).
+type unfoldReader struct {
+	r    *bufio.Reader
+	next string
+	have bool
+	eof  bool
+}
+
+func newUnfoldReader(r *bufio.Reader) *unfoldReader {
+	return &unfoldReader{r: r}
+}
+
+func (u *unfoldReader) physical() (string, error) {
+	if u.have {
+		u.have = false
+		return u.next, nil
+	}
+	if u.eof {
+		return "", io.EOF
+	}
+	line, err := u.r.ReadString('\n')
+	if err == io.EOF {
+		u.eof = true
+		if line == "" {
+			return "", io.EOF
+		}
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// ReadLine returns the next logical line with continuations joined.
+func (u *unfoldReader) ReadLine() (string, error) {
+	line, err := u.physical()
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(line)
+	for {
+		cont, err := u.physical()
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if cont != "" && (cont[0] == ' ' || cont[0] == '\t') {
+			sb.WriteString(cont[1:])
+			continue
+		}
+		u.next, u.have = cont, true
+		return sb.String(), nil
+	}
+}
@@ -0,0 +1,58 @@
+package customerimporter
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+const vcardFixture = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"FN:Alice\r\n" +
+	"EMAIL;TYPE=INTERNET,WORK:alice@X.com\r\n" +
+	"item1.EMAIL;type=INTERNET:alice.home@y.com\r\n" +
+	"NOTE:EMAIL:not-a-property@z.com\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:4.0\r\n" +
+	"email:bob@very-long-domain-name-that-is-fol\r\n" +
+	" ded.com\r\n" +
+	"EMAIL:broken\r\n" +
+	"END:VCARD\r\n"
+
+func TestImporter_VCard(t *testing.T) {
+	path := mustWriteTempFile(t, "contacts.vcf", vcardFixture)
+	got, err := New(Config{Path: path}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	if got.Stats.TotalRows != 4 || got.Stats.BadRows != 1 {
+		t.Fatalf("stats=%+v", got.Stats)
+	}
+	want := map[string]int{"x.com": 1, "y.com": 1, "very-long-domain-name-that-is-folded.com": 1}
+	if len(got.Data) != len(want) {
+		t.Fatalf("data=%v want %v", got.Data, want)
+	}
+	for _, d := range got.Data {
+		if want[d.Domain] != d.CustomerQuantity {
+			t.Errorf("domain %q count got=%d want=%d", d.Domain, d.CustomerQuantity, want[d.Domain])
+		}
+	}
+}
+
+func TestUnfoldReader(t *testing.T) {
+	in := "a:1\n b\n\tc\nd:2\r\n\r\ne:3"
+	u := newUnfoldReader(bufio.NewReader(strings.NewReader(in)))
+	var got []string
+	for {
+		line, err := u.ReadLine()
+		if err != nil {
+			break
+		}
+		got = append(got, line)
+	}
+	want := []string{"a:1bc", "d:2", "", "e:3"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("lines=%q want %q", got, want)
+	}
+}
@@ -0,0 +1,352 @@
+package customerimporter
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var ErrSheetNotFound = errors.New("sheet not found")
+
+// xlsxRows streams the rows of a single worksheet. Only the shared string table
+// is held in memory; sheet XML is decoded token by token.
+type xlsxRows struct {
+	dec    *xml.Decoder
+	body   io.ReadCloser
+	shared []string
+	row    []string
+	rowNum int
+}
+
+type xlsxSheet struct {
+	Name string `xml:"name,attr"`
+	RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []xlsxSheet `xml:"sheets>sheet"`
+}
+
+type xlsxRels struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// openXLSX opens the worksheet selected by sheet (a name or 1-based position,
+// empty for the first one). The returned reader closes the sheet on io.EOF.
+func openXLSX(f inputFile, sheet string) (rowReader, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, zf := range zr.File {
+		files[zf.Name] = zf
+	}
+
+	target, err := xlsxSheetPath(files, sheet)
+	if err != nil {
+		return nil, err
+	}
+	zf, ok := files[target]
+	if !ok {
+		return nil, fmt.Errorf("open xlsx: missing worksheet part %q", target)
+	}
+
+	var shared []string
+	if sf, ok := files["xl/sharedStrings.xml"]; ok {
+		if shared, err = readSharedStrings(sf); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := zf.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx worksheet: %w", err)
+	}
+	return &xlsxRows{dec: xml.NewDecoder(body), body: body, shared: shared}, nil
+}
+
+func xlsxSheetPath(files map[string]*zip.File, sheet string) (string, error) {
+	var wb xlsxWorkbook
+	if err := decodeZipXML(files, "xl/workbook.xml", &wb); err != nil {
+		return "", err
+	}
+	var rels xlsxRels
+	if err := decodeZipXML(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", ErrSheetNotFound
+	}
+
+	chosen := -1
+	switch {
+	case sheet == "":
+		chosen = 0
+	default:
+		for idx, s := range wb.Sheets {
+			if strings.EqualFold(s.Name, sheet) {
+				chosen = idx
+				break
+			}
+		}
+		if n, err := strconv.Atoi(sheet); chosen < 0 && err == nil && n >= 1 && n <= len(wb.Sheets) {
+			chosen = n - 1
+		}
+	}
+	if chosen < 0 {
+		return "", fmt.Errorf("%w: %q", ErrSheetNotFound, sheet)
+	}
+
+	for _, rel := range rels.Rels {
+		if rel.ID != wb.Sheets[chosen].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", fmt.Errorf("%w: no relationship for %q", ErrSheetNotFound, wb.Sheets[chosen].Name)
+}
+
+func decodeZipXML(files map[string]*zip.File, name string, v any) error {
+	zf, ok := files[name]
+	if !ok {
+		return fmt.Errorf("open xlsx: missing %s", name)
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return fmt.Errorf("open xlsx %s: %w", name, err)
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("parse xlsx %s: %w", name, err)
+	}
+	return nil
+}
+
+// readSharedStrings loads the shared string table. Rich-text entries (<r> runs)
+// are concatenated; phonetic hints (<rPh>) are skipped.
+func readSharedStrings(zf *zip.File) ([]string, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx shared strings: %w", err)
+	}
+	defer rc.Close()
+
+	var (
+		out   []string
+		sb    strings.Builder
+		inSI  bool
+		inRPh bool
+	)
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse xlsx shared strings: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				inSI = true
+				sb.Reset()
+			case "rPh":
+				inRPh = true
+			case "t":
+				if inSI && !inRPh {
+					var s string
+					if err := dec.DecodeElement(&s, &t); err != nil {
+						return nil, fmt.Errorf("parse xlsx shared strings: %w", err)
+					}
+					sb.WriteString(s)
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				inSI = false
+				out = append(out, sb.String())
+			case "rPh":
+				inRPh = false
+			}
+		}
+	}
+}
+
+// Read returns the next non-empty row. Cells are placed by their reference
+// (A1, C1, ...) so gaps left by empty cells keep later columns aligned.
+func (x *xlsxRows) Read() ([]string, error) {
+	for {
+		tok, err := x.dec.Token()
+		if err == io.EOF {
+			x.body.Close()
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse xlsx worksheet: %w", err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "row" {
+			continue
+		}
+		x.rowNum++
+		if n, err := strconv.Atoi(attr(se, "r")); err == nil {
+			x.rowNum = n
+		}
+		if err := x.readRow(); err != nil {
+			return nil, err
+		}
+		if len(x.row) > 0 {
+			return x.row, nil
+		}
+	}
+}
+
+// FieldPos reports the sheet row number of the last row read as the line and
+// the field's position as the column.
+func (x *xlsxRows) FieldPos(field int) (line, column int) {
+	return x.rowNum, field + 1
+}
+
+// maxXLSXColumn is the last column a worksheet can have (XFD), zero-based.
+// Cells beyond it are rejected rather than padded out, so a crafted
+// reference cannot make a row allocate billions of empty fields.
+const maxXLSXColumn = 16383
+
+// readRow reads the cells of the current row into x.row. A cell beyond
+// maxXLSXColumn fails the row with a RowError once the rest of it has been
+// consumed, so that skipping it leaves the decoder at the next row.
+func (x *xlsxRows) readRow() error {
+	x.row = x.row[:0]
+	var rowErr error
+	for {
+		tok, err := x.dec.Token()
+		if err != nil {
+			return fmt.Errorf("parse xlsx worksheet: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				continue
+			}
+			val, err := x.readCell(t)
+			if err != nil {
+				return err
+			}
+			if rowErr != nil {
+				continue
+			}
+			col := len(x.row)
+			ref := attr(t, "r")
+			if ref != "" {
+				if c, ok := columnIndex(ref); ok && c >= col {
+					col = c
+				}
+			}
+			if col > maxXLSXColumn {
+				rowErr = &RowError{Line: x.rowNum, Err: fmt.Errorf("cell %q is beyond the last column XFD", ref)}
+				continue
+			}
+			for len(x.row) < col {
+				x.row = append(x.row, "")
+			}
+			x.row = append(x.row, val)
+		case xml.EndElement:
+			if t.Name.Local == "row" {
+				return rowErr
+			}
+		}
+	}
+}
+
+func (x *xlsxRows) readCell(start xml.StartElement) (string, error) {
+	typ := attr(start, "t")
+	var val, inline strings.Builder
+	for {
+		tok, err := x.dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("parse xlsx cell: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var s string
+			switch t.Name.Local {
+			case "v":
+				if err := x.dec.DecodeElement(&s, &t); err != nil {
+					return "", fmt.Errorf("parse xlsx cell: %w", err)
+				}
+				val.WriteString(s)
+			case "t":
+				if err := x.dec.DecodeElement(&s, &t); err != nil {
+					return "", fmt.Errorf("parse xlsx cell: %w", err)
+				}
+				inline.WriteString(s)
+			}
+		case xml.EndElement:
+			if t.Name.Local != "c" {
+				continue
+			}
+			switch typ {
+			case "s":
+				n, err := strconv.Atoi(strings.TrimSpace(val.String()))
+				if err != nil || n < 0 || n >= len(x.shared) {
+					return "", nil
+				}
+				return x.shared[n], nil
+			case "inlineStr":
+				return inline.String(), nil
+			}
+			return val.String(), nil
+		}
+	}
+}
+
+func attr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// columnIndex converts the letters of a cell reference ("C7") to a zero-based
+// column. References with more letters than XFD saturate just past
+// maxXLSXColumn instead of overflowing.
+func columnIndex(ref string) (int, bool) {
+	n := 0
+	i := 0
+	for ; i < len(ref); i++ {
+		c := ref[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		n = min(n*26+int(c-'A'+1), maxXLSXColumn+2)
+	}
+	if i == 0 {
+		return 0, false
+	}
+	return n - 1, true
+}
@@ -0,0 +1,188 @@
+package customerimporter
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	xlsxWorkbookXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Summary" sheetId="1" r:id="rId1"/><sheet name="Customers" sheetId="2" r:id="rId2"/></sheets>
+</workbook>`
+	xlsxRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`
+	xlsxSharedXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="5" uniqueCount="5">
+<si><t>name</t></si><si><t>Email</t></si><si><t>a@x.com</t></si><si><r><t>b@</t></r><r><t>X.com</t></r><rPh><t>ignored</t></rPh></si><si><t>total</t></si>
+</sst>`
+	xlsxSheet1XML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>4</v></c></row>
+</sheetData></worksheet>`
+	// Customers: header in A/C with B empty, shared, rich-text and inline strings,
+	// an empty row, and a row missing the email cell.
+	xlsxSheet2XML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>
+<row r="2"><c r="A2" t="inlineStr"><is><t>Alice</t></is></c><c r="C2" t="s"><v>2</v></c></row>
+<row r="3"><c r="A3" t="inlineStr"><is><t>Bob</t></is></c><c r="C3" t="s"><v>3</v></c></row>
+<row r="4"></row>
+<row r="5"><c r="A5"><v>42</v></c></row>
+<row r="6"><c r="A6" t="inlineStr"><is><t>Carol</t></is></c><c r="C6" t="str"><v>c@y.com</v></c></row>
+</sheetData></worksheet>`
+)
+
+func mustWriteTempXLSX(t *testing.T) string {
+	t.Helper()
+	return mustWriteTempXLSXSheet(t, xlsxSheet2XML)
+}
+
+// mustWriteTempXLSXSheet writes the fixture workbook with sheet2 as the
+// Customers sheet.
+func mustWriteTempXLSXSheet(t *testing.T, sheet2 string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "in.xlsx")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatalf("create fixture: %v", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	parts := []struct{ name, body string }{
+		{"xl/workbook.xml", xlsxWorkbookXML},
+		{"xl/_rels/workbook.xml.rels", xlsxRelsXML},
+		{"xl/sharedStrings.xml", xlsxSharedXML},
+		{"xl/worksheets/sheet1.xml", xlsxSheet1XML},
+		{"xl/worksheets/sheet2.xml", sheet2},
+	}
+	for _, part := range parts {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			t.Fatalf("zip create %s: %v", part.name, err)
+		}
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			t.Fatalf("zip write %s: %v", part.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	return p
+}
+
+func TestImporter_XLSX(t *testing.T) {
+	path := mustWriteTempXLSX(t)
+
+	for _, sheet := range []string{"Customers", "customers", "2"} {
+		got, err := New(Config{Path: path, EmailHeader: "email", Sheet: sheet}).ImportDomainData()
+		if err != nil {
+			t.Fatalf("[%s] ImportDomainData error: %v", sheet, err)
+		}
+		if got.Stats.TotalRows != 4 || got.Stats.BadRows != 1 || got.Stats.UniqueDomains != 2 {
+			t.Fatalf("[%s] stats=%+v", sheet, got.Stats)
+		}
+		want := []DomainData{
+			{Domain: "x.com", CustomerQuantity: 2},
+			{Domain: "y.com", CustomerQuantity: 1},
+		}
+		for i := range want {
+			if got.Data[i] != want[i] {
+				t.Fatalf("[%s] order[%d] got=%v want=%v", sheet, i, got.Data[i], want[i])
+			}
+		}
+	}
+}
+
+func TestImporter_XLSX_FirstSheetByDefault(t *testing.T) {
+	path := mustWriteTempXLSX(t)
+	_, err := New(Config{Path: path, EmailHeader: "email"}).ImportDomainData()
+	if err != ErrEmailHeaderMissing {
+		t.Fatalf("expected ErrEmailHeaderMissing from first sheet, got %v", err)
+	}
+}
+
+func TestImporter_XLSX_UnknownSheet(t *testing.T) {
+	path := mustWriteTempXLSX(t)
+	for _, sheet := range []string{"Orders", "3", "0"} {
+		_, err := New(Config{Path: path, EmailHeader: "email", Sheet: sheet}).ImportDomainData()
+		if !errors.Is(err, ErrSheetNotFound) {
+			t.Fatalf("[%s] expected ErrSheetNotFound, got %v", sheet, err)
+		}
+	}
+}
+
+func TestImporter_XLSX_ColumnIndexAndFormatOverride(t *testing.T) {
+	src := mustWriteTempXLSX(t)
+	// No .xlsx extension: the format must come from the config.
+	path := filepath.Join(t.TempDir(), "upload.bin")
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := New(Config{Path: path, Format: FormatXLSX, Sheet: "Customers", EmailColumn: 3}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	if got.Stats.TotalRows != 4 || got.Stats.UniqueDomains != 2 {
+		t.Fatalf("stats=%+v", got.Stats)
+	}
+}
+
+func TestImporter_XLSX_ColumnOutOfRange(t *testing.T) {
+	path := mustWriteTempXLSXSheet(t, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="inlineStr"><is><t>email</t></is></c></row>
+<row r="2"><c r="A2" t="inlineStr"><is><t>a@x.com</t></is></c></row>
+<row r="3"><c r="A3" t="inlineStr"><is><t>b@x.com</t></is></c><c r="ZZZZZZZ3"><v>1</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><t>c@y.com</t></is></c><c r="XFD4"><v>1</v></c></row>
+</sheetData></worksheet>`)
+
+	_, err := New(Config{Path: path, EmailHeader: "email", Sheet: "Customers"}).ImportDomainData()
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 3 {
+		t.Fatalf("expected a RowError on line 3, got %v", err)
+	}
+
+	got, err := New(Config{Path: path, EmailHeader: "email", Sheet: "Customers", Malformed: MalformedSkip}).ImportDomainData()
+	if err != nil {
+		t.Fatalf("ImportDomainData error: %v", err)
+	}
+	if got.Stats.TotalRows != 3 || got.Stats.BadRows != 1 || got.Stats.MalformedRows != 1 || got.Stats.UniqueDomains != 2 {
+		t.Fatalf("stats=%+v", got.Stats)
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+		ok   bool
+	}{
+		{"A1", 0, true},
+		{"c7", 2, true},
+		{"Z10", 25, true},
+		{"AA1", 26, true},
+		{"AB12", 27, true},
+		{"XFD1", 16383, true},
+		{"ZZZZZZZZZZZZZZZ1", 16384, true},
+		{"12", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := columnIndex(tt.ref)
+		if got != tt.want || ok != tt.ok {
+			t.Fatalf("columnIndex(%q)=(%d,%v); want (%d,%v)", tt.ref, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -2,44 +2,210 @@ package exporter
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/daveteshome/email-domain-counter/customerimporter"
 )
 
+const (
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatParquet  = "parquet"
+	FormatSQLite   = "sqlite"
+	FormatTable    = "table"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported output format")
+	ErrFileRequired      = errors.New("output format can only be written to a file")
+)
+
 var csvHeader = []string{"domain", "number_of_customers"}
 
 type CustomerExporter struct {
 	outPath string
+	format  string
+	source  string
+	atomic  bool
+	top     int
 }
 
+// NewCustomerExporter writes to outPath in the format implied by its extension
+// (CSV unless recognised); use WithFormat to override.
 func NewCustomerExporter(outPath string) *CustomerExporter {
-	return &CustomerExporter{outPath: outPath}
+	return &CustomerExporter{outPath: outPath, format: FormatForPath(outPath), top: DefaultMarkdownTop}
+}
+
+// WithFormat sets the output format; an empty format keeps the detected one.
+func (e *CustomerExporter) WithFormat(format string) *CustomerExporter {
+	if format != "" {
+		e.format = strings.ToLower(format)
+	}
+	return e
+}
+
+// WithSource records where the results came from, for formats that keep run
+// metadata (SQLite, HTML).
+func (e *CustomerExporter) WithSource(source string) *CustomerExporter {
+	e.source = source
+	return e
+}
+
+// WithTop sets how many domains a Markdown report lists and an HTML report
+// charts; 0 means all.
+func (e *CustomerExporter) WithTop(n int) *CustomerExporter {
+	e.top = n
+	return e
+}
+
+// Atomic makes ExportResult write to a temporary file next to the output and
+// rename it into place, so readers never see a partial result. SQLite output
+// is written in a transaction and is not affected.
+func (e *CustomerExporter) Atomic() *CustomerExporter {
+	e.atomic = true
+	return e
+}
+
+// FormatForPath maps a file extension to an output format, defaulting to CSV.
+func FormatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".parquet":
+		return FormatParquet
+	case ".sqlite", ".sqlite3", ".db":
+		return FormatSQLite
+	case ".md", ".markdown":
+		return FormatMarkdown
+	case ".html", ".htm":
+		return FormatHTML
+	}
+	return FormatCSV
+}
+
+// CheckFormat reports whether format can be written, with
+// ErrUnsupportedFormat if not. An empty format is CSV.
+func CheckFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "", FormatCSV, FormatJSON, FormatParquet, FormatSQLite, FormatTable, FormatMarkdown, FormatHTML:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
 }
 
 func (e *CustomerExporter) ExportData(data []customerimporter.DomainData) error {
+	return e.ExportResult(customerimporter.Result{Data: data})
+}
+
+// ExportResult writes res to the exporter's path. Formats that can carry
+// metadata (JSON, Parquet, SQLite) include res.Stats as well. SQLite output is
+// appended to an existing database instead of replacing it.
+func (e *CustomerExporter) ExportResult(res customerimporter.Result) error {
+	// Checked before anything is created so a bad format leaves an existing
+	// output untouched.
+	if err := CheckFormat(e.format); err != nil {
+		return fmt.Errorf("write %q: %w", e.outPath, err)
+	}
 	if dir := filepath.Dir(e.outPath); dir != "." && dir != "" {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return fmt.Errorf("ensure dir %q: %w", dir, err)
 		}
 	}
 
+	if e.format == FormatSQLite {
+		if e.outPath == "" {
+			return fmt.Errorf("write sqlite: %w", ErrFileRequired)
+		}
+		if _, err := WriteSQLite(e.outPath, e.source, time.Now(), res); err != nil {
+			return fmt.Errorf("write sqlite to %q: %w", e.outPath, err)
+		}
+		return nil
+	}
+
+	if e.atomic {
+		return e.exportAtomic(res)
+	}
+
 	f, err := os.Create(e.outPath)
 	if err != nil {
 		return fmt.Errorf("create output file %q: %w", e.outPath, err)
 	}
 	defer f.Close()
 
-	if err := WriteCSV(f, data); err != nil {
-		return fmt.Errorf("write CSV to %q: %w", e.outPath, err)
+	if err := e.write(f, res); err != nil {
+		return fmt.Errorf("write %s to %q: %w", e.format, e.outPath, err)
+	}
+	return f.Close()
+}
+
+func (e *CustomerExporter) exportAtomic(res customerimporter.Result) error {
+	f, err := os.CreateTemp(filepath.Dir(e.outPath), "."+filepath.Base(e.outPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temporary file for %q: %w", e.outPath, err)
+	}
+	// Removing fails harmlessly once the file has been renamed.
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := e.write(f, res); err != nil {
+		return fmt.Errorf("write %s to %q: %w", e.format, e.outPath, err)
+	}
+	// CreateTemp uses 0600; match what os.Create would have produced.
+	if err := f.Chmod(0o644); err != nil {
+		return fmt.Errorf("write %s to %q: %w", e.format, e.outPath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write %s to %q: %w", e.format, e.outPath, err)
+	}
+	if err := os.Rename(f.Name(), e.outPath); err != nil {
+		return fmt.Errorf("replace %q: %w", e.outPath, err)
 	}
 	return nil
 }
 
+func (e *CustomerExporter) write(w io.Writer, res customerimporter.Result) error {
+	switch e.format {
+	case FormatMarkdown:
+		return WriteMarkdown(w, res, MarkdownOptions{Top: e.top})
+	case FormatHTML:
+		return WriteHTML(w, res, HTMLOptions{Top: e.top, Source: e.source, Generated: time.Now()})
+	}
+	return Write(w, e.format, res)
+}
+
+// Write encodes res to w in the given format. Tables are written without
+// color or truncation, and Markdown and HTML reports show the
+// DefaultMarkdownTop domains; use WriteTable, WriteMarkdown and WriteHTML for
+// other options.
+func Write(w io.Writer, format string, res customerimporter.Result) error {
+	switch strings.ToLower(format) {
+	case FormatCSV, "":
+		return WriteCSV(w, res.Data)
+	case FormatJSON:
+		return WriteJSON(w, res)
+	case FormatParquet:
+		return WriteParquet(w, res)
+	case FormatTable:
+		return WriteTable(w, res, TableOptions{})
+	case FormatMarkdown:
+		return WriteMarkdown(w, res, MarkdownOptions{Top: DefaultMarkdownTop})
+	case FormatHTML:
+		return WriteHTML(w, res, HTMLOptions{Top: DefaultMarkdownTop})
+	case FormatSQLite:
+		return fmt.Errorf("%w: %s", ErrFileRequired, format)
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+}
+
 func WriteCSV(w io.Writer, data []customerimporter.DomainData) error {
 	cw := csv.NewWriter(w)
 
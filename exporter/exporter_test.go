@@ -142,3 +142,49 @@ func TestWriteCSV_ShortWriteIsError(t *testing.T) {
 		t.Fatalf("expected error from short write, got nil")
 	}
 }
+
+func TestExportResult_UnsupportedFormatKeepsOutput(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "result.csv")
+	if err := os.WriteFile(out, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := NewCustomerExporter(out).WithFormat("xml").ExportResult(customerimporter.Result{})
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("xml export error = %v, want ErrUnsupportedFormat", err)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "old" {
+		t.Fatalf("after failed export the output is %q, want it untouched", b)
+	}
+}
+
+func TestExportResult_Atomic(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "result.csv")
+	if err := os.WriteFile(out, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	res := customerimporter.Result{Data: []customerimporter.DomainData{{Domain: "a.com", CustomerQuantity: 2}}}
+
+	// A failed write leaves the previous output in place.
+	err := NewCustomerExporter(out).WithFormat("xml").Atomic().ExportResult(res)
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("xml export error = %v, want ErrUnsupportedFormat", err)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "old" {
+		t.Fatalf("after failed export the output is %q, want it untouched", b)
+	}
+
+	if err := NewCustomerExporter(out).Atomic().ExportResult(res); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "domain,number_of_customers\na.com,2\n" {
+		t.Fatalf("output = %q", b)
+	}
+	if fi, err := os.Stat(out); err != nil || fi.Mode().Perm() != 0o644 {
+		t.Fatalf("output mode = %v (err=%v), want 0644", fi.Mode().Perm(), err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("dir has %d entries (err=%v), want only the output", len(entries), err)
+	}
+}
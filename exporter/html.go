@@ -0,0 +1,228 @@
+package exporter
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// Chart geometry of the HTML report, in SVG user units.
+const (
+	chartWidth     = 720
+	barLabelWidth  = 220
+	barValueWidth  = 110
+	barRowHeight   = 22
+	paretoHeight   = 260
+	paretoMargin   = 40
+	maxParetoSteps = 500
+	// topTLDs is how many TLDs the breakdown lists before "other".
+	topTLDs = 10
+)
+
+//go:embed html.tmpl
+var htmlSource string
+
+var htmlTemplate = template.Must(template.New("report").Parse(htmlSource))
+
+type HTMLOptions struct {
+	// Top is how many of the largest domains the bar chart shows; 0 shows
+	// all. The table always lists every domain.
+	Top int
+	// Source and Generated are shown under the title when set.
+	Source    string
+	Generated time.Time
+}
+
+// WriteHTML writes res as a single self-contained HTML page for emailing:
+// the Stats, a bar chart of the top domains, a Pareto chart of the cumulative
+// share, a TLD breakdown and a searchable table of all domains. Charts are
+// inline SVG and the page loads nothing else.
+func WriteHTML(w io.Writer, res customerimporter.Result, opts HTMLOptions) error {
+	total := 0
+	for _, d := range res.Data {
+		total += d.CustomerQuantity
+	}
+	top := res.Data
+	if opts.Top > 0 && opts.Top < len(top) {
+		top = top[:opts.Top]
+	}
+
+	page := htmlPage{
+		Source:   opts.Source,
+		Stats:    res.Stats,
+		Format:   res.Format,
+		Total:    total,
+		Unique:   len(res.Data),
+		BadShare: share(res.Stats.BadRows, res.Stats.TotalRows, 2),
+		Rest:     len(res.Data) - len(top),
+		Width:    chartWidth,
+	}
+	if !opts.Generated.IsZero() {
+		page.Generated = opts.Generated.UTC().Format(time.RFC3339)
+	}
+	page.Top = barChart(top, total)
+	page.TLDs = barChart(tldBreakdown(res.Data), total)
+	page.Pareto = paretoChart(res.Data, total)
+	page.Rows = make([]htmlRow, len(res.Data))
+	cumulative := 0
+	for i, d := range res.Data {
+		cumulative += d.CustomerQuantity
+		page.Rows[i] = htmlRow{
+			Rank:       i + 1,
+			Domain:     d.Domain,
+			Customers:  d.CustomerQuantity,
+			Share:      share(d.CustomerQuantity, total, 2),
+			Cumulative: share(cumulative, total, 2),
+		}
+	}
+	if err := htmlTemplate.Execute(w, page); err != nil {
+		return fmt.Errorf("render html: %w", err)
+	}
+	return nil
+}
+
+type htmlPage struct {
+	Source, Generated, Format, BadShare string
+	Stats                               customerimporter.Stats
+	Total, Unique, Rest, Width          int
+	Top, TLDs                           htmlBars
+	Pareto                              htmlPareto
+	Rows                                []htmlRow
+}
+
+type htmlRow struct {
+	Rank, Customers   int
+	Domain            string
+	Share, Cumulative string
+}
+
+type htmlBars struct {
+	Width, Height        int
+	LabelX, BarX, ValueX int
+	Bars                 []htmlBar
+}
+
+type htmlBar struct {
+	Label, Value string
+	Y, TextY     int
+	Width        float64
+}
+
+// barChart lays out one horizontal bar per entry, scaled to the largest.
+func barChart(data []customerimporter.DomainData, total int) htmlBars {
+	c := htmlBars{
+		Width:  chartWidth,
+		Height: len(data) * barRowHeight,
+		LabelX: barLabelWidth - 8,
+		BarX:   barLabelWidth,
+		ValueX: barLabelWidth + 4,
+		Bars:   make([]htmlBar, len(data)),
+	}
+	largest := 0
+	for _, d := range data {
+		largest = max(largest, d.CustomerQuantity)
+	}
+	area := float64(chartWidth - barLabelWidth - barValueWidth)
+	for i, d := range data {
+		b := htmlBar{
+			Label: truncate(d.Domain, 32),
+			Value: strconv.Itoa(d.CustomerQuantity) + " (" + share(d.CustomerQuantity, total, 1) + ")",
+			Y:     i*barRowHeight + 3,
+			TextY: i*barRowHeight + barRowHeight/2 + 5,
+		}
+		if largest > 0 {
+			b.Width = area * float64(d.CustomerQuantity) / float64(largest)
+		}
+		c.Bars[i] = b
+	}
+	return c
+}
+
+// tldBreakdown sums customers by the last label of the domain, largest
+// first, folding all but the topTLDs largest into "other".
+func tldBreakdown(data []customerimporter.DomainData) []customerimporter.DomainData {
+	sums := make(map[string]int)
+	for _, d := range data {
+		tld := d.Domain[strings.LastIndexByte(d.Domain, '.')+1:]
+		sums["."+tld] += d.CustomerQuantity
+	}
+	tlds := make([]customerimporter.DomainData, 0, len(sums))
+	for tld, n := range sums {
+		tlds = append(tlds, customerimporter.DomainData{Domain: tld, CustomerQuantity: n})
+	}
+	sort.Slice(tlds, func(i, j int) bool {
+		if tlds[i].CustomerQuantity != tlds[j].CustomerQuantity {
+			return tlds[i].CustomerQuantity > tlds[j].CustomerQuantity
+		}
+		return tlds[i].Domain < tlds[j].Domain
+	})
+	if len(tlds) > topTLDs+1 {
+		other := customerimporter.DomainData{Domain: fmt.Sprintf("other (%d TLDs)", len(tlds)-topTLDs)}
+		for _, t := range tlds[topTLDs:] {
+			other.CustomerQuantity += t.CustomerQuantity
+		}
+		tlds = append(tlds[:topTLDs], other)
+	}
+	return tlds
+}
+
+type htmlPareto struct {
+	Height, Left, Right, Bottom int
+	// Points is the cumulative share line as an SVG polyline.
+	Points string
+	Grid   []htmlGridLine
+	// Domains80 is how many domains hold 80% of the customers, drawn at X80.
+	Domains80 int
+	X80       float64
+}
+
+type htmlGridLine struct {
+	Label string
+	Y     float64
+}
+
+// paretoChart plots the cumulative share of customers against the number of
+// domains, largest first. Long tails are sampled down to maxParetoSteps
+// points.
+func paretoChart(data []customerimporter.DomainData, total int) htmlPareto {
+	c := htmlPareto{
+		Height: paretoHeight,
+		Left:   paretoMargin,
+		Right:  chartWidth - paretoMargin/2,
+		Bottom: paretoHeight - paretoMargin,
+	}
+	top := float64(paretoMargin / 2)
+	y := func(frac float64) float64 { return float64(c.Bottom) - frac*(float64(c.Bottom)-top) }
+	for _, p := range []int{0, 25, 50, 75, 100} {
+		c.Grid = append(c.Grid, htmlGridLine{Label: strconv.Itoa(p) + "%", Y: y(float64(p) / 100)})
+	}
+	if total == 0 {
+		return c
+	}
+	x := func(n int) float64 {
+		return float64(c.Left) + float64(n)/float64(len(data))*float64(c.Right-c.Left)
+	}
+
+	step := max(1, len(data)/maxParetoSteps)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%.1f,%.1f", x(0), y(0))
+	cumulative := 0
+	for i, d := range data {
+		cumulative += d.CustomerQuantity
+		if c.Domains80 == 0 && cumulative*5 >= total*4 {
+			c.Domains80, c.X80 = i+1, x(i+1)
+		}
+		if (i+1)%step == 0 || i == len(data)-1 {
+			fmt.Fprintf(&b, " %.1f,%.1f", x(i+1), y(float64(cumulative)/float64(total)))
+		}
+	}
+	c.Points = b.String()
+	return c
+}
@@ -0,0 +1,123 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+func TestWriteHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		res     customerimporter.Result
+		opts    HTMLOptions
+		want    []string
+		notWant []string
+	}{
+		{"Report", reportResult, HTMLOptions{Top: 2, Source: "customers.csv", Generated: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}, []string{
+			`<p class="meta">Source: customers.csv · Generated 2024-05-01T12:00:00Z</p>`,
+			`<div>Bad rows<b>2</b><span class="meta">16.67% of rows</span></div>`,
+			`<div>Customers counted<b>10</b></div>`,
+			"<h2>Top 2 domains</h2>",
+			`<text x="224" y="16" dx="390.0">6 (60.0%)</text>`,
+			"1 more domain is listed in the table below.",
+			"2 of 3 domains hold 80% of the customers.",
+			`<text x="212" y="16" text-anchor="end">.com</text>`,
+			`<text x="212" y="38" text-anchor="end">a-very-long-subdomain.example.o…</text>`,
+			`<text x="224" y="38" dx="195.0">3 (30.0%)</text>`,
+			"<tr><td class=\"n\">3</td><td>x.io</td><td class=\"n\">1</td><td class=\"n\">10.00%</td><td class=\"n\">100.00%</td></tr>",
+		}, []string{"http://", "https://", "src="}},
+		{"Escaped", customerimporter.Result{Data: []customerimporter.DomainData{{Domain: "<b>x</b>.com", CustomerQuantity: 1}}}, HTMLOptions{}, []string{
+			"<td>&lt;b&gt;x&lt;/b&gt;.com</td>",
+			"<h2>Top 1 domains</h2>",
+		}, []string{"<b>x</b>", `class="meta">Source`, "of rows"}},
+		{"Empty", customerimporter.Result{}, HTMLOptions{}, []string{
+			"<div>Unique domains<b>0</b></div>",
+			"<p>No domains were counted.</p>",
+		}, []string{"<svg", "<table>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteHTML(&b, tt.res, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(b.String(), s) {
+					t.Errorf("report is missing %q", s)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(b.String(), s) {
+					t.Errorf("report contains %q", s)
+				}
+			}
+			if t.Failed() {
+				t.Logf("report:\n%s", b.String())
+			}
+		})
+	}
+}
+
+func TestTLDBreakdown(t *testing.T) {
+	var data []customerimporter.DomainData
+	for _, tld := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+		data = append(data, customerimporter.DomainData{Domain: "x." + tld, CustomerQuantity: 1})
+	}
+	data = append(data,
+		customerimporter.DomainData{Domain: "mail.example.com", CustomerQuantity: 5},
+		customerimporter.DomainData{Domain: "other.com", CustomerQuantity: 2},
+		customerimporter.DomainData{Domain: "localhost", CustomerQuantity: 1},
+	)
+	got := tldBreakdown(data)
+	if len(got) != topTLDs+1 {
+		t.Fatalf("got %d TLDs, want %d: %v", len(got), topTLDs+1, got)
+	}
+	if got[0] != (customerimporter.DomainData{Domain: ".com", CustomerQuantity: 7}) {
+		t.Errorf("largest TLD = %v, want .com with 7", got[0])
+	}
+	if got[1] != (customerimporter.DomainData{Domain: ".a", CustomerQuantity: 1}) {
+		t.Errorf("ties are not sorted by name: %v", got[1])
+	}
+	if last := got[topTLDs]; last != (customerimporter.DomainData{Domain: "other (4 TLDs)", CustomerQuantity: 4}) {
+		t.Errorf("other = %v, want 4 TLDs with 4", last)
+	}
+}
+
+func TestParetoChart(t *testing.T) {
+	data := make([]customerimporter.DomainData, 2000)
+	for i := range data {
+		data[i] = customerimporter.DomainData{Domain: "d", CustomerQuantity: 1}
+	}
+	c := paretoChart(data, len(data))
+	if n := len(strings.Fields(c.Points)); n != maxParetoSteps+1 {
+		t.Errorf("%d points, want %d", n, maxParetoSteps+1)
+	}
+	if !strings.HasSuffix(c.Points, " 700.0,20.0") {
+		t.Errorf("line does not end at 100%%: %q", c.Points[len(c.Points)-20:])
+	}
+	if c.Domains80 != 1600 {
+		t.Errorf("Domains80 = %d, want 1600", c.Domains80)
+	}
+	if c := paretoChart(nil, 0); c.Points != "" || c.Domains80 != 0 {
+		t.Errorf("empty chart = %+v", c)
+	}
+}
+
+func TestExportResult_HTML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.html")
+	if err := NewCustomerExporter(path).WithSource("in.csv").ExportResult(reportResult); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "<!DOCTYPE html>") || !strings.Contains(string(b), "Source: in.csv · Generated ") {
+		t.Fatalf("report.html:\n%s", b)
+	}
+}
@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// jsonResult is the JSON result document. Domain entries use the CSV column
+// names.
+type jsonResult struct {
+	Stats   *customerimporter.Stats `json:"stats"`
+	Domains []jsonDomain            `json:"domains"`
+}
+
+type jsonDomain struct {
+	Domain            string `json:"domain"`
+	NumberOfCustomers int    `json:"number_of_customers"`
+}
+
+// WriteJSON writes res as {"stats": {...}, "domains": [{"domain": ..., "number_of_customers": ...}]}.
+func WriteJSON(w io.Writer, res customerimporter.Result) error {
+	doc := jsonResult{Stats: &res.Stats, Domains: make([]jsonDomain, len(res.Data))}
+	for i, d := range res.Data {
+		doc.Domains[i] = jsonDomain{Domain: d.Domain, NumberOfCustomers: d.CustomerQuantity}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return nil
+}
+
+// readJSON reads a document written by WriteJSON. Anything without a
+// "domains" list, such as a JSON array of customers, is ErrNotResult.
+func readJSON(r io.Reader) (customerimporter.Result, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return customerimporter.Result{}, fmt.Errorf("%w: %v", ErrNotResult, err)
+		}
+		if b[0] == '{' {
+			break
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			return customerimporter.Result{}, fmt.Errorf("%w: not a JSON object", ErrNotResult)
+		}
+		br.ReadByte()
+	}
+
+	var doc jsonResult
+	if err := json.NewDecoder(br).Decode(&doc); err != nil {
+		return customerimporter.Result{}, fmt.Errorf("decode json: %w", err)
+	}
+	if doc.Domains == nil {
+		return customerimporter.Result{}, fmt.Errorf("%w: no \"domains\" list", ErrNotResult)
+	}
+
+	res := customerimporter.Result{Data: make([]customerimporter.DomainData, len(doc.Domains))}
+	for i, d := range doc.Domains {
+		res.Data[i] = customerimporter.DomainData{Domain: d.Domain, CustomerQuantity: d.NumberOfCustomers}
+	}
+	if doc.Stats != nil {
+		res.Stats = *doc.Stats
+	} else {
+		res.Stats.UniqueDomains = len(res.Data)
+	}
+	return res, nil
+}
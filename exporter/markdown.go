@@ -0,0 +1,82 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// DefaultMarkdownTop is how many domains a Markdown report lists unless
+// MarkdownOptions.Top says otherwise.
+const DefaultMarkdownTop = 10
+
+type MarkdownOptions struct {
+	// Top is how many of the largest domains are listed; 0 lists all.
+	Top int
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "|", `\|`,
+)
+
+// WriteMarkdown writes res as a Markdown report for tickets and wikis: the
+// Stats, then the top domains with their share of all customers counted and
+// the cumulative share.
+func WriteMarkdown(w io.Writer, res customerimporter.Result, opts MarkdownOptions) error {
+	total := 0
+	for _, d := range res.Data {
+		total += d.CustomerQuantity
+	}
+	top := res.Data
+	if opts.Top > 0 && opts.Top < len(top) {
+		top = top[:opts.Top]
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Email domain report\n\n## Stats\n\n")
+	bw.WriteString("| Metric | Value |\n|---|---:|\n")
+	if res.Format != "" {
+		fmt.Fprintf(bw, "| Input format | %s |\n", markdownEscaper.Replace(res.Format))
+	}
+	s := res.Stats
+	fmt.Fprintf(bw, "| Total rows | %d |\n", s.TotalRows)
+	fmt.Fprintf(bw, "| Bad rows | %d (%s) |\n", s.BadRows, share(s.BadRows, s.TotalRows, 2))
+	fmt.Fprintf(bw, "| Malformed rows | %d |\n", s.MalformedRows)
+	fmt.Fprintf(bw, "| Customers counted | %d |\n", total)
+	fmt.Fprintf(bw, "| Unique domains | %d |\n", len(res.Data))
+
+	if len(top) == len(res.Data) {
+		bw.WriteString("\n## Domains\n\n")
+	} else {
+		fmt.Fprintf(bw, "\n## Top %d domains\n\n", len(top))
+	}
+	if len(top) == 0 {
+		bw.WriteString("No domains were counted.\n")
+		return bw.Flush()
+	}
+	bw.WriteString("| # | Domain | Customers | Share | Cumulative share |\n|--:|---|--:|--:|--:|\n")
+	cumulative := 0
+	for i, d := range top {
+		cumulative += d.CustomerQuantity
+		fmt.Fprintf(bw, "| %d | %s | %d | %s | %s |\n",
+			i+1, markdownEscaper.Replace(d.Domain), d.CustomerQuantity,
+			share(d.CustomerQuantity, total, 2), share(cumulative, total, 2))
+	}
+	if rest := len(res.Data) - len(top); rest > 0 {
+		fmt.Fprintf(bw, "\n%d more %s the other %d %s (%s).\n",
+			rest, plural(rest, "domain has", "domains have"),
+			total-cumulative, plural(total-cumulative, "customer", "customers"), share(total-cumulative, total, 2))
+	}
+	return bw.Flush()
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,76 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+const reportStats = "# Email domain report\n\n## Stats\n\n" +
+	"| Metric | Value |\n|---|---:|\n" +
+	"| Input format | csv |\n" +
+	"| Total rows | 12 |\n" +
+	"| Bad rows | 2 (16.67%) |\n" +
+	"| Malformed rows | 1 |\n" +
+	"| Customers counted | 10 |\n" +
+	"| Unique domains | 3 |\n"
+
+func TestWriteMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		res  customerimporter.Result
+		top  int
+		want string
+	}{
+		{"Top_2", reportResult, 2, reportStats +
+			"\n## Top 2 domains\n\n" +
+			"| # | Domain | Customers | Share | Cumulative share |\n|--:|---|--:|--:|--:|\n" +
+			"| 1 | example.com | 6 | 60.00% | 60.00% |\n" +
+			"| 2 | a-very-long-subdomain.example.org | 3 | 30.00% | 90.00% |\n" +
+			"\n1 more domain has the other 1 customer (10.00%).\n"},
+		{"All", reportResult, 0, reportStats +
+			"\n## Domains\n\n" +
+			"| # | Domain | Customers | Share | Cumulative share |\n|--:|---|--:|--:|--:|\n" +
+			"| 1 | example.com | 6 | 60.00% | 60.00% |\n" +
+			"| 2 | a-very-long-subdomain.example.org | 3 | 30.00% | 90.00% |\n" +
+			"| 3 | x.io | 1 | 10.00% | 100.00% |\n"},
+		{"Escaped", customerimporter.Result{Data: []customerimporter.DomainData{{Domain: "odd_|*name", CustomerQuantity: 1}}}, 5,
+			"# Email domain report\n\n## Stats\n\n| Metric | Value |\n|---|---:|\n" +
+				"| Total rows | 0 |\n| Bad rows | 0 (-) |\n| Malformed rows | 0 |\n| Customers counted | 1 |\n| Unique domains | 1 |\n" +
+				"\n## Domains\n\n" +
+				"| # | Domain | Customers | Share | Cumulative share |\n|--:|---|--:|--:|--:|\n" +
+				"| 1 | odd\\_\\|\\*name | 1 | 100.00% | 100.00% |\n"},
+		{"Empty", customerimporter.Result{}, 10,
+			"# Email domain report\n\n## Stats\n\n| Metric | Value |\n|---|---:|\n" +
+				"| Total rows | 0 |\n| Bad rows | 0 (-) |\n| Malformed rows | 0 |\n| Customers counted | 0 |\n| Unique domains | 0 |\n" +
+				"\n## Domains\n\nNo domains were counted.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteMarkdown(&b, tt.res, MarkdownOptions{Top: tt.top}); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Fatalf("report:\n%s\nwant:\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestExportResult_Markdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.md")
+	if err := NewCustomerExporter(path).WithTop(1).ExportResult(reportResult); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "## Top 1 domains\n") || !strings.Contains(string(b), "2 more domains") {
+		t.Fatalf("report.md:\n%s", b)
+	}
+}
@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// parquetRow mirrors the CSV columns.
+type parquetRow struct {
+	Domain            string `parquet:"domain"`
+	NumberOfCustomers int64  `parquet:"number_of_customers"`
+}
+
+// Parquet key-value metadata keys carrying the run Stats.
+const (
+	MetaTotalRows     = "total_rows"
+	MetaBadRows       = "bad_rows"
+	MetaUniqueDomains = "unique_domains"
+	MetaMalformedRows = "malformed_rows"
+)
+
+// WriteParquet writes one row per domain and stores res.Stats in the file's
+// key-value metadata.
+func WriteParquet(w io.Writer, res customerimporter.Result) error {
+	pw := parquet.NewGenericWriter[parquetRow](w,
+		parquet.KeyValueMetadata(MetaTotalRows, strconv.Itoa(res.Stats.TotalRows)),
+		parquet.KeyValueMetadata(MetaBadRows, strconv.Itoa(res.Stats.BadRows)),
+		parquet.KeyValueMetadata(MetaUniqueDomains, strconv.Itoa(res.Stats.UniqueDomains)),
+		parquet.KeyValueMetadata(MetaMalformedRows, strconv.Itoa(res.Stats.MalformedRows)),
+	)
+
+	const batch = 1024
+	rows := make([]parquetRow, 0, batch)
+	for i, d := range res.Data {
+		rows = append(rows, parquetRow{Domain: d.Domain, NumberOfCustomers: int64(d.CustomerQuantity)})
+		if len(rows) == batch || i == len(res.Data)-1 {
+			if _, err := pw.Write(rows); err != nil {
+				return fmt.Errorf("write rows: %w", err)
+			}
+			rows = rows[:0]
+		}
+	}
+
+	if err := pw.Close(); err != nil {
+		return fmt.Errorf("close parquet writer: %w", err)
+	}
+	return nil
+}
+
+// readParquet reads a file written by WriteParquet, restoring Stats from the
+// key-value metadata when present.
+func readParquet(r io.ReaderAt, size int64) (customerimporter.Result, error) {
+	var res customerimporter.Result
+
+	pf, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return res, fmt.Errorf("%w: %v", ErrNotResult, err)
+	}
+	for i, kind := range []parquet.Kind{parquet.ByteArray, parquet.Int64} {
+		leaf, ok := pf.Schema().Lookup(csvHeader[i])
+		if !ok || leaf.Node.Type().Kind() != kind {
+			return res, fmt.Errorf("%w: no %s column %q", ErrNotResult, kind, csvHeader[i])
+		}
+	}
+
+	pr := parquet.NewGenericReader[parquetRow](pf)
+	defer pr.Close()
+	rows := make([]parquetRow, 1024)
+	for {
+		n, err := pr.Read(rows)
+		for _, row := range rows[:n] {
+			if row.NumberOfCustomers < 0 {
+				return res, fmt.Errorf("domain %q: invalid count %d", row.Domain, row.NumberOfCustomers)
+			}
+			res.Data = append(res.Data, customerimporter.DomainData{Domain: row.Domain, CustomerQuantity: int(row.NumberOfCustomers)})
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("read rows: %w", err)
+		}
+	}
+
+	res.Stats.UniqueDomains = len(res.Data)
+	for key, dst := range map[string]*int{MetaTotalRows: &res.Stats.TotalRows, MetaBadRows: &res.Stats.BadRows, MetaMalformedRows: &res.Stats.MalformedRows} {
+		if v, ok := pf.Lookup(key); ok {
+			if n, err := strconv.Atoi(v); err == nil {
+				*dst = n
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,73 @@
+package exporter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+func TestWriteParquet_RoundTrip(t *testing.T) {
+	res := customerimporter.Result{
+		Data: []customerimporter.DomainData{
+			{Domain: "a.com", CustomerQuantity: 3},
+			{Domain: "b.com", CustomerQuantity: 1},
+		},
+		Stats: customerimporter.Stats{TotalRows: 5, BadRows: 1, MalformedRows: 1, UniqueDomains: 2},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, res); err != nil {
+		t.Fatalf("WriteParquet error: %v", err)
+	}
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open written file: %v", err)
+	}
+	for key, want := range map[string]string{MetaTotalRows: "5", MetaBadRows: "1", MetaUniqueDomains: "2", MetaMalformedRows: "1"} {
+		if got, ok := f.Lookup(key); !ok || got != want {
+			t.Fatalf("metadata %q got=(%q,%v) want %q", key, got, ok, want)
+		}
+	}
+
+	rows, err := parquet.Read[parquetRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("read rows: %v", err)
+	}
+	if len(rows) != 2 || rows[0] != (parquetRow{"a.com", 3}) || rows[1] != (parquetRow{"b.com", 1}) {
+		t.Fatalf("rows=%v", rows)
+	}
+}
+
+func TestExportResult_FormatFromExtension(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "nested", "result.parquet")
+	res := customerimporter.Result{Data: []customerimporter.DomainData{{Domain: "x.com", CustomerQuantity: 7}}}
+	if err := NewCustomerExporter(out).ExportResult(res); err != nil {
+		t.Fatalf("ExportResult error: %v", err)
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read out: %v", err)
+	}
+	if !bytes.HasPrefix(b, []byte("PAR1")) {
+		t.Fatalf("expected parquet magic, got %q", b[:min(len(b), 8)])
+	}
+
+	rows, err := parquet.Read[parquetRow](bytes.NewReader(b), int64(len(b)))
+	if err != nil || len(rows) != 1 || rows[0].Domain != "x.com" {
+		t.Fatalf("rows=%v err=%v", rows, err)
+	}
+}
+
+func TestWrite_UnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "xml", customerimporter.Result{}); err == nil {
+		t.Fatalf("expected error for unknown format, got nil")
+	}
+}
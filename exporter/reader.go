@@ -0,0 +1,128 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// ErrNotResult means a file is not in the layout this package writes, e.g. a
+// customer CSV rather than a domain,number_of_customers result.
+var ErrNotResult = errors.New("not an exported result")
+
+// ReadResultFile reads a result previously written by ExportResult, in the
+// format implied by the path's extension. For SQLite the latest run is read.
+func ReadResultFile(path string) (customerimporter.Result, error) {
+	format := FormatForPath(path)
+	if format == FormatSQLite {
+		// Read-only mode does not create the file, but its error would not
+		// tell a missing file from another database.
+		if _, err := os.Stat(path); err != nil {
+			return customerimporter.Result{}, err
+		}
+		res, err := readSQLite(path)
+		if err != nil {
+			return res, fmt.Errorf("read %s: %w", path, err)
+		}
+		return res, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return customerimporter.Result{}, err
+	}
+	defer f.Close()
+
+	var res customerimporter.Result
+	if format == FormatParquet {
+		var fi os.FileInfo
+		if fi, err = f.Stat(); err == nil {
+			res, err = readParquet(f, fi.Size())
+		}
+	} else {
+		res, err = ReadResult(f, format)
+	}
+	if err != nil {
+		return res, fmt.Errorf("read %s: %w", path, err)
+	}
+	return res, nil
+}
+
+// ReadResult decodes a result written by Write. Data comes back in file order;
+// formats that do not keep Stats only set Stats.UniqueDomains. Parquet input
+// is buffered in memory; SQLite needs ReadResultFile.
+func ReadResult(r io.Reader, format string) (customerimporter.Result, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV, "":
+		return readCSV(r)
+	case FormatJSON:
+		return readJSON(r)
+	case FormatParquet:
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return customerimporter.Result{}, err
+		}
+		return readParquet(bytes.NewReader(b), int64(len(b)))
+	case FormatSQLite:
+		return customerimporter.Result{}, fmt.Errorf("%w: %s", ErrFileRequired, format)
+	}
+	return customerimporter.Result{}, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+}
+
+func readCSV(r io.Reader) (customerimporter.Result, error) {
+	var res customerimporter.Result
+
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return res, fmt.Errorf("%w: empty file", ErrNotResult)
+	}
+	if err != nil {
+		return res, fmt.Errorf("%w: %v", ErrNotResult, err)
+	}
+	if len(header) != len(csvHeader) || !strings.EqualFold(strings.TrimSpace(header[0]), csvHeader[0]) ||
+		!strings.EqualFold(strings.TrimSpace(header[1]), csvHeader[1]) {
+		return res, fmt.Errorf("%w: header is %q, want %q", ErrNotResult, strings.Join(header, ","), strings.Join(csvHeader, ","))
+	}
+
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(rec[1]))
+		if err != nil || n < 0 {
+			line, _ := cr.FieldPos(1)
+			return res, fmt.Errorf("line %d: invalid count %q", line, rec[1])
+		}
+		res.Data = append(res.Data, customerimporter.DomainData{Domain: rec[0], CustomerQuantity: n})
+	}
+	res.Stats.UniqueDomains = len(res.Data)
+	return res, nil
+}
+
+// MergeFiles reads the results at paths with ReadResultFile and sums them with
+// customerimporter.Merge. Every file must be a result; the first that is not
+// fails the merge with ErrNotResult.
+func MergeFiles(paths ...string) (customerimporter.Result, error) {
+	results := make([]customerimporter.Result, 0, len(paths))
+	for _, path := range paths {
+		res, err := ReadResultFile(path)
+		if err != nil {
+			return customerimporter.Result{}, err
+		}
+		results = append(results, res)
+	}
+	return customerimporter.Merge(results...), nil
+}
@@ -0,0 +1,173 @@
+package exporter
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+func TestReadResult_RoundTrip(t *testing.T) {
+	res := customerimporter.Result{
+		Data: []customerimporter.DomainData{
+			{Domain: "a.com", CustomerQuantity: 3},
+			{Domain: "b.com", CustomerQuantity: 1},
+		},
+		Stats: customerimporter.Stats{TotalRows: 5, BadRows: 1, MalformedRows: 1, UniqueDomains: 2},
+	}
+
+	for _, format := range []string{FormatCSV, FormatJSON, FormatParquet} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, res); err != nil {
+			t.Fatalf("[%s] Write: %v", format, err)
+		}
+		got, err := ReadResult(&buf, format)
+		if err != nil {
+			t.Fatalf("[%s] ReadResult: %v", format, err)
+		}
+		if !reflect.DeepEqual(got.Data, res.Data) {
+			t.Errorf("[%s] Data = %+v, want %+v", format, got.Data, res.Data)
+		}
+		wantStats := customerimporter.Stats{UniqueDomains: 2}
+		switch format {
+		case FormatJSON:
+			wantStats = res.Stats
+		case FormatParquet:
+			wantStats = res.Stats
+		}
+		if got.Stats != wantStats {
+			t.Errorf("[%s] Stats = %+v, want %+v", format, got.Stats, wantStats)
+		}
+	}
+}
+
+func TestReadResult_NotResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		in     string
+	}{
+		{name: "Customer_CSV", format: FormatCSV, in: "first_name,email\nAda,ada@x.com\n"},
+		{name: "Empty_CSV", format: FormatCSV, in: ""},
+		{name: "JSON_array", format: FormatJSON, in: ` [{"email": "ada@x.com"}]`},
+		{name: "JSON_object_without_domains", format: FormatJSON, in: `{"customers": []}`},
+		{name: "Not_parquet", format: FormatParquet, in: "domain,number_of_customers\n"},
+	}
+	for _, tt := range tests {
+		_, err := ReadResult(strings.NewReader(tt.in), tt.format)
+		if !errors.Is(err, ErrNotResult) {
+			t.Errorf("[%s] error = %v, want ErrNotResult", tt.name, err)
+		}
+	}
+}
+
+func TestReadResult_Errors(t *testing.T) {
+	_, err := ReadResult(strings.NewReader("domain,number_of_customers\na.com,3\nb.com,many\n"), FormatCSV)
+	if err == nil || !strings.Contains(err.Error(), `line 3: invalid count "many"`) {
+		t.Fatalf("bad count error = %v", err)
+	}
+	if _, err := ReadResult(strings.NewReader(""), FormatSQLite); !errors.Is(err, ErrFileRequired) {
+		t.Fatalf("sqlite error = %v, want ErrFileRequired", err)
+	}
+	if _, err := ReadResult(strings.NewReader(""), "xml"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("xml error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestReadResultFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.json")
+	data := []customerimporter.DomainData{{Domain: "a.com", CustomerQuantity: 2}}
+	if err := NewCustomerExporter(path).ExportData(data); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	got, err := ReadResultFile(path)
+	if err != nil {
+		t.Fatalf("ReadResultFile: %v", err)
+	}
+	if !reflect.DeepEqual(got.Data, data) {
+		t.Fatalf("Data = %+v, want %+v", got.Data, data)
+	}
+	if _, err := ReadResultFile(filepath.Join(t.TempDir(), "missing.csv")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing file error = %v", err)
+	}
+}
+
+func TestReadResultFile_SQLiteLatestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.sqlite")
+	first := customerimporter.Result{Data: []customerimporter.DomainData{{Domain: "old.com", CustomerQuantity: 9}}}
+	second := customerimporter.Result{
+		Data: []customerimporter.DomainData{
+			{Domain: "a.com", CustomerQuantity: 3},
+			{Domain: "b.com", CustomerQuantity: 3},
+		},
+		Stats: customerimporter.Stats{TotalRows: 7, BadRows: 1, MalformedRows: 1, UniqueDomains: 2},
+	}
+	for _, res := range []customerimporter.Result{first, second} {
+		if err := NewCustomerExporter(path).ExportResult(res); err != nil {
+			t.Fatalf("export: %v", err)
+		}
+	}
+
+	got, err := ReadResultFile(path)
+	if err != nil {
+		t.Fatalf("ReadResultFile: %v", err)
+	}
+	if !reflect.DeepEqual(got.Data, second.Data) || got.Stats != second.Stats {
+		t.Fatalf("got %+v, want the latest run %+v", got, second)
+	}
+
+	other := filepath.Join(t.TempDir(), "other.db")
+	if err := os.WriteFile(other, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadResultFile(other); !errors.Is(err, ErrNotResult) {
+		t.Fatalf("other.db error = %v, want ErrNotResult", err)
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	dir := t.TempDir()
+	inputs := map[string]customerimporter.Result{
+		"north.csv":    {Data: []customerimporter.DomainData{{Domain: "x.com", CustomerQuantity: 2}, {Domain: "y.com", CustomerQuantity: 5}}},
+		"south.json":   {Data: []customerimporter.DomainData{{Domain: "x.com", CustomerQuantity: 3}}, Stats: customerimporter.Stats{TotalRows: 4, BadRows: 1}},
+		"east.parquet": {Data: []customerimporter.DomainData{{Domain: "z.com", CustomerQuantity: 1}}, Stats: customerimporter.Stats{TotalRows: 1}},
+		"west.sqlite":  {Data: []customerimporter.DomainData{{Domain: "z.com", CustomerQuantity: 4}}, Stats: customerimporter.Stats{TotalRows: 4}},
+	}
+	var paths []string
+	for name, res := range inputs {
+		path := filepath.Join(dir, name)
+		if err := NewCustomerExporter(path).ExportResult(res); err != nil {
+			t.Fatalf("export %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	got, err := MergeFiles(paths...)
+	if err != nil {
+		t.Fatalf("MergeFiles: %v", err)
+	}
+	want := []customerimporter.DomainData{
+		{Domain: "x.com", CustomerQuantity: 5},
+		{Domain: "y.com", CustomerQuantity: 5},
+		{Domain: "z.com", CustomerQuantity: 5},
+	}
+	if !reflect.DeepEqual(got.Data, want) {
+		t.Fatalf("Data = %+v, want %+v", got.Data, want)
+	}
+	if wantStats := (customerimporter.Stats{TotalRows: 9, BadRows: 1, UniqueDomains: 3}); got.Stats != wantStats {
+		t.Fatalf("Stats = %+v, want %+v", got.Stats, wantStats)
+	}
+
+	customers := filepath.Join(dir, "customers.csv")
+	if err := os.WriteFile(customers, []byte("email\nada@x.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MergeFiles(paths[0], customers); !errors.Is(err, ErrNotResult) || !strings.Contains(err.Error(), customers) {
+		t.Fatalf("customer file error = %v, want ErrNotResult naming the file", err)
+	}
+}
@@ -0,0 +1,150 @@
+package exporter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// sqliteSchema is applied on every export; existing tables are kept so each
+// run is appended next to the previous ones.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	source         TEXT    NOT NULL,
+	created_at     TEXT    NOT NULL,
+	total_rows     INTEGER NOT NULL,
+	bad_rows       INTEGER NOT NULL,
+	unique_domains INTEGER NOT NULL,
+	malformed_rows INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS domains (
+	run_id              INTEGER NOT NULL REFERENCES runs(id),
+	domain              TEXT    NOT NULL,
+	number_of_customers INTEGER NOT NULL,
+	PRIMARY KEY (run_id, domain)
+);`
+
+// WriteSQLite appends one run to the database at path, creating it if needed:
+// a row in runs with the source and Stats, and one row per domain in domains.
+// It returns the new run id.
+func WriteSQLite(path, source string, at time.Time, res customerimporter.Result) (int64, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return 0, fmt.Errorf("open sqlite %q: %w", path, err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		return 0, fmt.Errorf("create schema: %w", err)
+	}
+	// Databases written before malformed_rows was recorded lack the column.
+	ok, err := hasColumn(ctx, db, "runs", "malformed_rows")
+	if err == nil && !ok {
+		_, err = db.ExecContext(ctx, `ALTER TABLE runs ADD COLUMN malformed_rows INTEGER NOT NULL DEFAULT 0`)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("add malformed_rows: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	r, err := tx.ExecContext(ctx,
+		`INSERT INTO runs (source, created_at, total_rows, bad_rows, unique_domains, malformed_rows) VALUES (?, ?, ?, ?, ?, ?)`,
+		source, at.UTC().Format(time.RFC3339), res.Stats.TotalRows, res.Stats.BadRows, res.Stats.UniqueDomains, res.Stats.MalformedRows)
+	if err != nil {
+		return 0, fmt.Errorf("insert run: %w", err)
+	}
+	runID, err := r.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("insert run: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO domains (run_id, domain, number_of_customers) VALUES (?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("prepare domain insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, d := range res.Data {
+		if _, err := stmt.ExecContext(ctx, runID, d.Domain, d.CustomerQuantity); err != nil {
+			return 0, fmt.Errorf("insert domain %q: %w", d.Domain, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return runID, nil
+}
+
+// readSQLite reads the latest run of a database written by WriteSQLite. The
+// database is opened read-only.
+func readSQLite(path string) (customerimporter.Result, error) {
+	var res customerimporter.Result
+
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?mode=ro"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return res, fmt.Errorf("open sqlite %q: %w", path, err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	// Runs written before malformed_rows was recorded read as 0.
+	malformed := "0"
+	if ok, err := hasColumn(ctx, db, "runs", "malformed_rows"); err == nil && ok {
+		malformed = "malformed_rows"
+	}
+	var runID int64
+	err = db.QueryRowContext(ctx,
+		`SELECT id, total_rows, bad_rows, `+malformed+` FROM runs ORDER BY id DESC LIMIT 1`,
+	).Scan(&runID, &res.Stats.TotalRows, &res.Stats.BadRows, &res.Stats.MalformedRows)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return res, fmt.Errorf("%w: no runs", ErrNotResult)
+	case err != nil:
+		// Typically "no such table": some other database.
+		return res, fmt.Errorf("%w: %v", ErrNotResult, err)
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT domain, number_of_customers FROM domains WHERE run_id = ? ORDER BY number_of_customers DESC, domain`, runID)
+	if err != nil {
+		return res, fmt.Errorf("%w: %v", ErrNotResult, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d customerimporter.DomainData
+		if err := rows.Scan(&d.Domain, &d.CustomerQuantity); err != nil {
+			return res, fmt.Errorf("scan domain: %w", err)
+		}
+		if d.CustomerQuantity < 0 {
+			return res, fmt.Errorf("domain %q: invalid count %d", d.Domain, d.CustomerQuantity)
+		}
+		res.Data = append(res.Data, d)
+	}
+	if err := rows.Err(); err != nil {
+		return res, fmt.Errorf("read domains: %w", err)
+	}
+	res.Stats.UniqueDomains = len(res.Data)
+	return res, nil
+}
+
+// hasColumn reports whether table has the named column.
+func hasColumn(ctx context.Context, db *sql.DB, table, column string) (bool, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	return n > 0, err
+}
@@ -0,0 +1,131 @@
+package exporter
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+func TestWriteSQLite_AppendsRuns(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "results.db")
+	first := customerimporter.Result{
+		Data:  []customerimporter.DomainData{{Domain: "a.com", CustomerQuantity: 3}, {Domain: "b.com", CustomerQuantity: 1}},
+		Stats: customerimporter.Stats{TotalRows: 5, BadRows: 1, UniqueDomains: 2},
+	}
+	second := customerimporter.Result{
+		Data:  []customerimporter.DomainData{{Domain: "a.com", CustomerQuantity: 4}},
+		Stats: customerimporter.Stats{TotalRows: 4, UniqueDomains: 1},
+	}
+	at := time.Date(2025, 9, 24, 16, 58, 21, 0, time.UTC)
+
+	id1, err := WriteSQLite(out, "jan.csv", at, first)
+	if err != nil {
+		t.Fatalf("first WriteSQLite error: %v", err)
+	}
+	if err := NewCustomerExporter(out).WithSource("feb.csv").ExportResult(second); err != nil {
+		t.Fatalf("second export error: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var source, created string
+	var total, bad, unique int
+	err = db.QueryRow(`SELECT source, created_at, total_rows, bad_rows, unique_domains FROM runs WHERE id = ?`, id1).
+		Scan(&source, &created, &total, &bad, &unique)
+	if err != nil {
+		t.Fatalf("query run: %v", err)
+	}
+	if source != "jan.csv" || created != "2025-09-24T16:58:21Z" || total != 5 || bad != 1 || unique != 2 {
+		t.Fatalf("run row = %q %q %d %d %d", source, created, total, bad, unique)
+	}
+
+	var runs int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM runs`).Scan(&runs); err != nil || runs != 2 {
+		t.Fatalf("runs=%d err=%v; want 2", runs, err)
+	}
+
+	rows, err := db.Query(`SELECT r.source, d.domain, d.number_of_customers FROM domains d JOIN runs r ON r.id = d.run_id ORDER BY r.id, d.domain`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var src, dom string
+		var n int
+		if err := rows.Scan(&src, &dom, &n); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s:%s=%d", src, dom, n))
+	}
+	want := []string{"jan.csv:a.com=3", "jan.csv:b.com=1", "feb.csv:a.com=4"}
+	if len(got) != len(want) {
+		t.Fatalf("domains=%v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("domains=%v want %v", got, want)
+		}
+	}
+}
+
+// TestWriteSQLite_AddsMalformedRows appends to a database written before
+// runs had a malformed_rows column.
+func TestWriteSQLite_AddsMalformedRows(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "results.db")
+	db, err := sql.Open("sqlite", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+CREATE TABLE runs (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	source         TEXT    NOT NULL,
+	created_at     TEXT    NOT NULL,
+	total_rows     INTEGER NOT NULL,
+	bad_rows       INTEGER NOT NULL,
+	unique_domains INTEGER NOT NULL
+);
+CREATE TABLE domains (
+	run_id              INTEGER NOT NULL REFERENCES runs(id),
+	domain              TEXT    NOT NULL,
+	number_of_customers INTEGER NOT NULL,
+	PRIMARY KEY (run_id, domain)
+);
+INSERT INTO runs (source, created_at, total_rows, bad_rows, unique_domains) VALUES ('old.csv', '2025-01-01T00:00:00Z', 2, 1, 1);
+INSERT INTO domains VALUES (1, 'a.com', 1);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := ReadResultFile(out)
+	if err != nil || old.Stats != (customerimporter.Stats{TotalRows: 2, BadRows: 1, UniqueDomains: 1}) {
+		t.Fatalf("old run = %+v, %v", old, err)
+	}
+	res := customerimporter.Result{
+		Data:  []customerimporter.DomainData{{Domain: "a.com", CustomerQuantity: 2}},
+		Stats: customerimporter.Stats{TotalRows: 4, BadRows: 2, MalformedRows: 1, UniqueDomains: 1},
+	}
+	if _, err := WriteSQLite(out, "new.csv", time.Now(), res); err != nil {
+		t.Fatalf("WriteSQLite error: %v", err)
+	}
+	got, err := ReadResultFile(out)
+	if err != nil || got.Stats != res.Stats {
+		t.Fatalf("new run = %+v, %v; want stats %+v", got, err, res.Stats)
+	}
+}
+
+func TestWrite_SQLiteNeedsFile(t *testing.T) {
+	if err := Write(nil, FormatSQLite, customerimporter.Result{}); err == nil {
+		t.Fatalf("expected error writing sqlite to a stream, got nil")
+	}
+}
@@ -0,0 +1,111 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// ANSI escapes used by colored tables.
+const (
+	ansiBold  = "\033[1m"
+	ansiDim   = "\033[2m"
+	ansiReset = "\033[0m"
+)
+
+// minDomainWidth is the narrowest the domain column is truncated to, however
+// small TableOptions.Width is.
+const minDomainWidth = 10
+
+type TableOptions struct {
+	// Width truncates long domains so that rows fit in Width columns, e.g. the
+	// terminal width. Zero never truncates.
+	Width int
+	// Color makes the header bold and the rank and share columns dim.
+	Color bool
+}
+
+// WriteTable writes res as an aligned, human-readable table: rank, domain,
+// customers and share of all customers counted, followed by a line with the
+// Stats when there are any.
+func WriteTable(w io.Writer, res customerimporter.Result, opts TableOptions) error {
+	total := 0
+	for _, d := range res.Data {
+		total += d.CustomerQuantity
+	}
+
+	header := []string{"#", "DOMAIN", "CUSTOMERS", "SHARE"}
+	rows := make([][]string, len(res.Data))
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = len(h)
+	}
+	for i, d := range res.Data {
+		rows[i] = []string{strconv.Itoa(i + 1), d.Domain, strconv.Itoa(d.CustomerQuantity), share(d.CustomerQuantity, total, 1)}
+		for j, cell := range rows[i] {
+			widths[j] = max(widths[j], utf8.RuneCountInString(cell))
+		}
+	}
+	if opts.Width > 0 {
+		// Two spaces between columns.
+		others := widths[0] + widths[2] + widths[3] + 2*(len(widths)-1)
+		widths[1] = min(widths[1], max(opts.Width-others, minDomainWidth))
+	}
+
+	bw := bufio.NewWriter(w)
+	style := func(cell, code string) string {
+		if !opts.Color || code == "" {
+			return cell
+		}
+		return code + cell + ansiReset
+	}
+	line := func(cells []string, styles [4]string) {
+		for j, cell := range cells {
+			if j > 0 {
+				bw.WriteString("  ")
+			}
+			if j == 1 {
+				// The domain is left-aligned, the numbers right-aligned.
+				cell = truncate(cell, widths[j])
+				cell += strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+			} else {
+				cell = strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)) + cell
+			}
+			bw.WriteString(style(cell, styles[j]))
+		}
+		bw.WriteString("\n")
+	}
+	line(header, [4]string{ansiBold, ansiBold, ansiBold, ansiBold})
+	for _, r := range rows {
+		line(r, [4]string{ansiDim, "", "", ansiDim})
+	}
+	if s := res.Stats; s.TotalRows > 0 {
+		summary := fmt.Sprintf("%d %s, %d %s from %d rows (%d bad, %d malformed)",
+			len(res.Data), plural(len(res.Data), "domain", "domains"),
+			total, plural(total, "customer", "customers"), s.TotalRows, s.BadRows, s.MalformedRows)
+		bw.WriteString("\n" + style(summary, ansiDim) + "\n")
+	}
+	return bw.Flush()
+}
+
+// truncate shortens s to width runes, marking the cut with an ellipsis.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
+
+// share formats n as a percentage of total with the given decimals.
+func share(n, total, decimals int) string {
+	if total == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(n)*100/float64(total), 'f', decimals, 64) + "%"
+}
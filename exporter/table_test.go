@@ -0,0 +1,67 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+var reportResult = customerimporter.Result{
+	Data: []customerimporter.DomainData{
+		{Domain: "example.com", CustomerQuantity: 6},
+		{Domain: "a-very-long-subdomain.example.org", CustomerQuantity: 3},
+		{Domain: "x.io", CustomerQuantity: 1},
+	},
+	Stats:  customerimporter.Stats{TotalRows: 12, BadRows: 2, MalformedRows: 1, UniqueDomains: 3},
+	Format: "csv",
+}
+
+func TestWriteTable(t *testing.T) {
+	tests := []struct {
+		name string
+		res  customerimporter.Result
+		opts TableOptions
+		want string
+	}{
+		{"Aligned", reportResult, TableOptions{}, "" +
+			"#  DOMAIN                             CUSTOMERS  SHARE\n" +
+			"1  example.com                                6  60.0%\n" +
+			"2  a-very-long-subdomain.example.org          3  30.0%\n" +
+			"3  x.io                                       1  10.0%\n" +
+			"\n3 domains, 10 customers from 12 rows (2 bad, 1 malformed)\n"},
+		{"Truncated", reportResult, TableOptions{Width: 40}, "" +
+			"#  DOMAIN               CUSTOMERS  SHARE\n" +
+			"1  example.com                  6  60.0%\n" +
+			"2  a-very-long-subdom…          3  30.0%\n" +
+			"3  x.io                         1  10.0%\n" +
+			"\n3 domains, 10 customers from 12 rows (2 bad, 1 malformed)\n"},
+		{"Narrow_keeps_minimum", customerimporter.Result{Data: reportResult.Data[1:2]}, TableOptions{Width: 5}, "" +
+			"#  DOMAIN      CUSTOMERS   SHARE\n" +
+			"1  a-very-lo…          3  100.0%\n"},
+		{"Empty", customerimporter.Result{}, TableOptions{Width: 80}, "#  DOMAIN  CUSTOMERS  SHARE\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteTable(&b, tt.res, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Fatalf("table:\n%s\nwant:\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteTable_Color(t *testing.T) {
+	var b strings.Builder
+	if err := WriteTable(&b, customerimporter.Result{Data: reportResult.Data[2:]}, TableOptions{Color: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := "\033[1m#\033[0m  \033[1mDOMAIN\033[0m  \033[1mCUSTOMERS\033[0m  \033[1m SHARE\033[0m\n" +
+		"\033[2m1\033[0m  x.io            1  \033[2m100.0%\033[0m\n"
+	if b.String() != want {
+		t.Fatalf("table = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,469 @@
+// Package grpcserver implements the countpb.DomainCounter gRPC service on top
+// of the customer importer: Count imports the data a client streams in, and
+// WatchProgress reports on a running Count.
+package grpcserver
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/daveteshome/email-domain-counter/countpb"
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/metrics"
+)
+
+// finishedRunTTL is how long a finished count stays visible to
+// WatchProgress, for watchers that arrive late.
+const finishedRunTTL = time.Minute
+
+type Config struct {
+	// Import is the importer configuration every count starts from; the
+	// CountOptions of a stream override it. Path and StatePath are ignored.
+	Import customerimporter.Config
+	// MaxBadRows and MaxBadRatio fail a count with too many bad rows with
+	// FailedPrecondition; see customerimporter.CheckBadRows. A negative limit
+	// disables it.
+	MaxBadRows  int
+	MaxBadRatio float64
+	// MaxBytes caps the input of a count: the chunks of a stream, or the CSV
+	// its emails are written as. A larger input fails with
+	// ResourceExhausted. Zero or a negative value means no limit.
+	MaxBytes int64
+	// MaxConcurrent caps the counts running at once; further calls fail with
+	// ResourceExhausted straight away instead of queueing. Zero means no
+	// limit.
+	MaxConcurrent int
+	// Metrics, if set, records every count.
+	Metrics *metrics.Registry
+}
+
+// Service serves countpb.DomainCounter. Register it with
+// countpb.RegisterDomainCounterServer.
+type Service struct {
+	countpb.UnimplementedDomainCounterServer
+	cfg   Config
+	slots chan struct{}
+
+	mu   sync.Mutex
+	runs map[string]*run
+	// started is closed and replaced whenever a run is added.
+	started chan struct{}
+}
+
+func New(cfg Config) *Service {
+	s := &Service{cfg: cfg, runs: make(map[string]*run), started: make(chan struct{})}
+	if cfg.MaxConcurrent > 0 {
+		s.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return s
+}
+
+// Count imports the streamed input. It waits for the first payload message
+// to tell raw chunks from single emails, then imports while the rest of the
+// stream arrives.
+func (s *Service) Count(stream countpb.DomainCounter_CountServer) (err error) {
+	start := time.Now()
+	if !s.acquire() {
+		return status.Errorf(codes.ResourceExhausted, "%d counts are already running, retry later", cap(s.slots))
+	}
+	defer s.release()
+
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "the stream is empty; the first message must carry the options")
+	}
+	if err != nil {
+		return err
+	}
+	opts := first.GetOptions()
+	if opts == nil {
+		return status.Error(codes.InvalidArgument, "the first message must carry the options")
+	}
+	cfg, err := s.importConfig(opts)
+	if err != nil {
+		return err
+	}
+	if s.cfg.MaxBytes > 0 && opts.SizeBytes > s.cfg.MaxBytes {
+		return status.Errorf(codes.ResourceExhausted, "the input is larger than %d bytes", s.cfg.MaxBytes)
+	}
+
+	next, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if _, ok := next.GetPayload().(*countpb.CountRequest_Email); ok {
+		// feed writes the emails as a UTF-8 CSV; no option about the shape
+		// or encoding of an input file applies to it.
+		cfg.Format, cfg.EmailHeader, cfg.EmailColumn, cfg.NoHeader = customerimporter.FormatCSV, "email", 0, false
+		cfg.Encoding, cfg.Sheet, cfg.EmailPath, cfg.Query, cfg.Members = "", "", "", "", ""
+	}
+
+	if opts.Id != "" {
+		r, rerr := s.register(opts.Id)
+		if rerr != nil {
+			return rerr
+		}
+		cfg.Progress = r.update
+		defer func() { s.finish(opts.Id, r, err) }()
+	}
+
+	pr, pw := io.Pipe()
+	stop, fed := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(fed)
+		feed(stream, next, pw, s.cfg.MaxBytes, stop)
+	}()
+	res, err := customerimporter.New(cfg).ImportReaderContext(stream.Context(), pr)
+	// Stops the feeder if the import ended before the input did, and waits
+	// for it: the stream must not be used once Count has returned. A feeder
+	// blocked in Recv returns with the client's next message or with the
+	// end of the call.
+	close(stop)
+	pr.CloseWithError(errImportEnded)
+	<-fed
+	if err == nil {
+		err = customerimporter.CheckBadRows(res.Stats, s.cfg.MaxBadRows, s.cfg.MaxBadRatio)
+	}
+	if err != nil {
+		s.observe(res, 0, err)
+		err = statusError(err)
+		slog.Warn("grpc count failed", "id", opts.Id, "file", opts.Filename, "code", status.Code(err), "error", err)
+		return err
+	}
+
+	sendStart := time.Now()
+	if err := stream.SendAndClose(response(res)); err != nil {
+		s.observe(res, 0, err)
+		return err
+	}
+	s.observe(res, time.Since(sendStart), nil)
+	slog.Info("grpc count",
+		"id", opts.Id,
+		"file", opts.Filename,
+		"format", res.Format,
+		"total_rows", res.Stats.TotalRows,
+		"bad_rows", res.Stats.BadRows,
+		"unique_domains", res.Stats.UniqueDomains,
+		"duration", time.Since(start),
+	)
+	return nil
+}
+
+// acquire takes a place among the MaxConcurrent running counts, if there is
+// one free.
+func (s *Service) acquire() bool {
+	if s.slots == nil {
+		return true
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Service) release() {
+	if s.slots != nil {
+		<-s.slots
+	}
+}
+
+// observe records a finished count in the metrics, if enabled; err is the
+// error that failed it.
+func (s *Service) observe(res customerimporter.Result, send time.Duration, err error) {
+	switch {
+	case s.cfg.Metrics == nil:
+	case err != nil:
+		s.cfg.Metrics.ObserveFailure()
+	default:
+		s.cfg.Metrics.Observe(res, send)
+	}
+}
+
+// errImportEnded stops a feeder whose import has already returned.
+var errImportEnded = errors.New("import ended")
+
+// feed writes the payload of next and of the messages after it to w until
+// the client closes the stream or stop is closed. Emails are written as a
+// one-column CSV with an "email" header. More than maxBytes written, if
+// positive, fails the import with ResourceExhausted.
+func feed(stream countpb.DomainCounter_CountServer, next *countpb.CountRequest, w *io.PipeWriter, maxBytes int64, stop <-chan struct{}) {
+	lw := &limitWriter{w: w, max: maxBytes}
+	var emails *csv.Writer
+	if _, ok := next.GetPayload().(*countpb.CountRequest_Email); ok {
+		emails = csv.NewWriter(lw)
+		emails.Write([]string{"email"})
+	}
+	for next != nil {
+		var err error
+		switch p := next.Payload.(type) {
+		case *countpb.CountRequest_Chunk:
+			if emails != nil {
+				err = status.Error(codes.InvalidArgument, "chunk in a stream of emails")
+				break
+			}
+			_, err = lw.Write(p.Chunk)
+		case *countpb.CountRequest_Email:
+			if emails == nil {
+				err = status.Error(codes.InvalidArgument, "email in a stream of chunks")
+				break
+			}
+			emails.Write([]string{p.Email})
+			err = emails.Error()
+		case *countpb.CountRequest_Options:
+			err = status.Error(codes.InvalidArgument, "options are only allowed in the first message")
+		default:
+			err = status.Error(codes.InvalidArgument, "message without payload")
+		}
+		if err == nil {
+			select {
+			case <-stop:
+				err = errImportEnded
+			default:
+				next, err = stream.Recv()
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			w.CloseWithError(err)
+			return
+		}
+	}
+	if emails != nil {
+		emails.Flush()
+		if err := emails.Error(); err != nil {
+			w.CloseWithError(err)
+			return
+		}
+	}
+	w.Close()
+}
+
+// limitWriter fails the write that takes what was written past max, if max
+// is positive.
+type limitWriter struct {
+	w      io.Writer
+	n, max int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if l.n += int64(len(p)); l.max > 0 && l.n > l.max {
+		return 0, status.Errorf(codes.ResourceExhausted, "the input is larger than %d bytes", l.max)
+	}
+	return l.w.Write(p)
+}
+
+func (s *Service) importConfig(opts *countpb.CountOptions) (customerimporter.Config, error) {
+	cfg := s.cfg.Import
+	cfg.Path, cfg.StatePath, cfg.Size = opts.Filename, "", opts.SizeBytes
+	if opts.Format != "" {
+		cfg.Format = opts.Format
+	}
+	if opts.EmailHeader != "" {
+		cfg.EmailHeader = opts.EmailHeader
+	}
+	switch {
+	case opts.EmailColumn < 0:
+		return cfg, status.Error(codes.InvalidArgument, "email_column must be a positive position")
+	case opts.EmailColumn > 0:
+		cfg.EmailColumn = int(opts.EmailColumn)
+	}
+	cfg.NoHeader = cfg.NoHeader || opts.NoHeader
+	cfg.AllowSingleLabelDomain = cfg.AllowSingleLabelDomain || opts.AllowSingleLabelDomain
+	if opts.Encoding != "" {
+		cfg.Encoding = opts.Encoding
+	}
+	if opts.Sheet != "" {
+		cfg.Sheet = opts.Sheet
+	}
+	if opts.EmailPath != "" {
+		cfg.EmailPath = opts.EmailPath
+	}
+	if opts.Query != "" {
+		return cfg, status.Error(codes.InvalidArgument, "query cannot be set by clients; SQLite input uses the server's -query")
+	}
+	if opts.Members != "" {
+		cfg.Members = opts.Members
+	}
+	if len(opts.MboxHeaders) > 0 {
+		cfg.MboxHeaders = opts.MboxHeaders
+	}
+	switch opts.Malformed {
+	case countpb.Malformed_MALFORMED_COUNT:
+	case countpb.Malformed_MALFORMED_STRICT:
+		cfg.Malformed = customerimporter.MalformedStrict
+	case countpb.Malformed_MALFORMED_SKIP:
+		cfg.Malformed = customerimporter.MalformedSkip
+	default:
+		return cfg, status.Errorf(codes.InvalidArgument, "unknown malformed policy %d", opts.Malformed)
+	}
+	if cfg.NoHeader && cfg.EmailColumn == 0 {
+		return cfg, status.Error(codes.InvalidArgument, "no_header requires email_column")
+	}
+	return cfg, nil
+}
+
+// statusError maps an import error to a gRPC status.
+func statusError(err error) error {
+	if st, ok := status.FromError(err); ok {
+		return st.Err()
+	}
+	code := codes.InvalidArgument
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, customerimporter.ErrBadRowThreshold):
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, err.Error())
+}
+
+func response(res customerimporter.Result) *countpb.CountResponse {
+	out := &countpb.CountResponse{
+		Domains: make([]*countpb.DomainData, len(res.Data)),
+		Stats: &countpb.Stats{
+			TotalRows:     int64(res.Stats.TotalRows),
+			BadRows:       int64(res.Stats.BadRows),
+			MalformedRows: int64(res.Stats.MalformedRows),
+			UniqueDomains: int64(res.Stats.UniqueDomains),
+		},
+		InputFormat: res.Format,
+	}
+	for i, d := range res.Data {
+		out.Domains[i] = &countpb.DomainData{Domain: d.Domain, Customers: int64(d.CustomerQuantity)}
+	}
+	return out
+}
+
+// WatchProgress sends the progress of the count with the requested id each
+// time it changes.
+func (s *Service) WatchProgress(req *countpb.WatchProgressRequest, stream countpb.DomainCounter_WatchProgressServer) error {
+	if req.Id == "" {
+		return status.Error(codes.InvalidArgument, "id is required")
+	}
+	ctx := stream.Context()
+	r, err := s.wait(ctx, req.Id)
+	if err != nil {
+		return err
+	}
+	for {
+		p, done, err, changed := r.snapshot()
+		if err != nil {
+			return err
+		}
+		msg := &countpb.Progress{
+			BytesRead:      p.BytesRead,
+			TotalBytes:     p.TotalBytes,
+			Rows:           int64(p.Rows),
+			BadRows:        int64(p.BadRows),
+			ElapsedSeconds: p.Elapsed.Seconds(),
+			Done:           done,
+		}
+		if err := stream.Send(msg); err != nil || done {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-changed:
+		}
+	}
+}
+
+// register adds a running count under id. A finished count with the same id
+// is replaced.
+func (s *Service) register(id string) (*run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.runs[id]; ok && !r.finished() {
+		return nil, status.Errorf(codes.AlreadyExists, "a count with id %q is running", id)
+	}
+	r := &run{changed: make(chan struct{})}
+	s.runs[id] = r
+	close(s.started)
+	s.started = make(chan struct{})
+	return r, nil
+}
+
+// finish ends r with err and forgets it after finishedRunTTL.
+func (s *Service) finish(id string, r *run, err error) {
+	r.finish(err)
+	time.AfterFunc(finishedRunTTL, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.runs[id] == r {
+			delete(s.runs, id)
+		}
+	})
+}
+
+// wait returns the count with id, waiting for it to start until ctx is done.
+func (s *Service) wait(ctx context.Context, id string) (*run, error) {
+	for {
+		s.mu.Lock()
+		r, started := s.runs[id], s.started
+		s.mu.Unlock()
+		if r != nil {
+			return r, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-started:
+		}
+	}
+}
+
+// run is the progress of one count. changed is closed and replaced on every
+// update.
+type run struct {
+	mu      sync.Mutex
+	p       customerimporter.Progress
+	done    bool
+	err     error
+	changed chan struct{}
+}
+
+func (r *run) update(p customerimporter.Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.p = p
+	r.notify()
+}
+
+func (r *run) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done, r.err = true, err
+	r.notify()
+}
+
+func (r *run) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+func (r *run) finished() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done
+}
+
+func (r *run) snapshot() (customerimporter.Progress, bool, error, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.p, r.done, r.err, r.changed
+}
@@ -0,0 +1,430 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/daveteshome/email-domain-counter/countpb"
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/metrics"
+)
+
+const customersCSV = "name,email\nA,a@x.com\nB,b@y.com\nC,c@x.com\nD,broken\n"
+
+// dial serves a Service for cfg on an in-process listener and returns a
+// client connected to it.
+func dial(t *testing.T, cfg Config) countpb.DomainCounterClient {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	countpb.RegisterDomainCounterServer(s, New(cfg))
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return countpb.NewDomainCounterClient(conn)
+}
+
+func testConfig() Config {
+	return Config{Import: customerimporter.Config{EmailHeader: "email"}, MaxBadRows: -1, MaxBadRatio: -1}
+}
+
+func options(o *countpb.CountOptions) *countpb.CountRequest {
+	return &countpb.CountRequest{Payload: &countpb.CountRequest_Options{Options: o}}
+}
+
+func chunk(s string) *countpb.CountRequest {
+	return &countpb.CountRequest{Payload: &countpb.CountRequest_Chunk{Chunk: []byte(s)}}
+}
+
+func email(s string) *countpb.CountRequest {
+	return &countpb.CountRequest{Payload: &countpb.CountRequest_Email{Email: s}}
+}
+
+// chunks splits s into requests of n bytes.
+func chunks(s string, n int) []*countpb.CountRequest {
+	var reqs []*countpb.CountRequest
+	for len(s) > n {
+		reqs = append(reqs, chunk(s[:n]))
+		s = s[n:]
+	}
+	return append(reqs, chunk(s))
+}
+
+// count sends reqs on a Count stream and returns the response.
+func count(ctx context.Context, c countpb.DomainCounterClient, reqs ...*countpb.CountRequest) (*countpb.CountResponse, error) {
+	stream, err := c.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range reqs {
+		if err := stream.Send(req); err == io.EOF {
+			// The server ended the call; CloseAndRecv returns its status.
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func TestCount(t *testing.T) {
+	want := &countpb.CountResponse{
+		Domains: []*countpb.DomainData{{Domain: "x.com", Customers: 2}, {Domain: "y.com", Customers: 1}},
+		Stats:   &countpb.Stats{TotalRows: 4, BadRows: 1, UniqueDomains: 2},
+	}
+	tests := []struct {
+		name   string
+		reqs   []*countpb.CountRequest
+		format string
+	}{
+		{"CSV_chunks", append([]*countpb.CountRequest{options(&countpb.CountOptions{})}, chunks(customersCSV, 7)...), "csv"},
+		{"Emails", []*countpb.CountRequest{options(&countpb.CountOptions{EmailHeader: "ignored"}), email("a@x.com"), email("b@y.com"), email("c@x.com"), email(`"broken, really"`)}, "csv"},
+		{"NDJSON_by_filename", []*countpb.CountRequest{
+			options(&countpb.CountOptions{Filename: "events.ndjson"}),
+			chunk(`{"email":"a@x.com"}` + "\n" + `{"email":"b@y.com"}` + "\n"),
+			chunk(`{"email":"c@x.com"}` + "\n" + `{"email":"broken"}` + "\n"),
+		}, "ndjson"},
+		{"Headerless_column", []*countpb.CountRequest{
+			options(&countpb.CountOptions{NoHeader: true, EmailColumn: 2}),
+			chunk("A,a@x.com\nB,b@y.com\nC,c@x.com\nD,broken\n"),
+		}, "csv"},
+	}
+	c := dial(t, testConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := count(context.Background(), c, tt.reqs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want.InputFormat = tt.format
+			if !proto.Equal(got, want) {
+				t.Fatalf("response = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestCount_EmailsIgnoreFileOptions checks that options describing an input
+// file, from the server or the stream, do not apply to streamed emails.
+func TestCount_EmailsIgnoreFileOptions(t *testing.T) {
+	cfg := testConfig()
+	cfg.Import.Encoding = "utf-16"
+	c := dial(t, cfg)
+	got, err := count(context.Background(), c,
+		options(&countpb.CountOptions{Sheet: "Customers", EmailPath: "contact.email", Members: "*.csv"}),
+		email("a@x.com"), email("b@x.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*countpb.DomainData{{Domain: "x.com", Customers: 2}}
+	if len(got.Domains) != 1 || !proto.Equal(got.Domains[0], want[0]) || got.Stats.BadRows != 0 {
+		t.Fatalf("response = %v, want %v", got, want)
+	}
+}
+
+func TestCount_Errors(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxBadRows = 0
+	c := dial(t, cfg)
+	tolerant := options(&countpb.CountOptions{})
+
+	tests := []struct {
+		name string
+		reqs []*countpb.CountRequest
+		code codes.Code
+	}{
+		{"No_options", []*countpb.CountRequest{chunk(customersCSV)}, codes.InvalidArgument},
+		{"Empty_stream", nil, codes.InvalidArgument},
+		{"Options_twice", []*countpb.CountRequest{tolerant, chunk("email\n"), tolerant}, codes.InvalidArgument},
+		{"Mixed_payloads", []*countpb.CountRequest{tolerant, email("a@x.com"), chunk("b@y.com\n")}, codes.InvalidArgument},
+		{"No_header_without_column", []*countpb.CountRequest{options(&countpb.CountOptions{NoHeader: true}), chunk("a@x.com\n")}, codes.InvalidArgument},
+		{"Header_missing", []*countpb.CountRequest{tolerant, chunk("id,mail\n1,a@x.com\n")}, codes.InvalidArgument},
+		{"Strict_malformed", []*countpb.CountRequest{options(&countpb.CountOptions{Malformed: countpb.Malformed_MALFORMED_STRICT}), chunk("email\n\"a@x.com\n")}, codes.InvalidArgument},
+		{"Unsupported_format", []*countpb.CountRequest{options(&countpb.CountOptions{Format: "xml"}), chunk("<a/>")}, codes.InvalidArgument},
+		{"Query", []*countpb.CountRequest{options(&countpb.CountOptions{Format: "sqlite", Query: "ATTACH DATABASE '/etc/x.db' AS x; SELECT email FROM x.t"}), chunk("x")}, codes.InvalidArgument},
+		{"Bad_rows_exceeded", []*countpb.CountRequest{tolerant, chunk(customersCSV)}, codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := count(context.Background(), c, tt.reqs...)
+			if status.Code(err) != tt.code {
+				t.Fatalf("error = %v, want code %v", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestCount_Limits(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxBytes = int64(len(customersCSV))
+	c := dial(t, cfg)
+
+	tests := []struct {
+		name string
+		reqs []*countpb.CountRequest
+		code codes.Code
+	}{
+		{"At_limit", append([]*countpb.CountRequest{options(&countpb.CountOptions{})}, chunks(customersCSV, 5)...), codes.OK},
+		{"Chunks_over_limit", append([]*countpb.CountRequest{options(&countpb.CountOptions{})}, chunks(customersCSV+"E,e@z.com\n", 5)...), codes.ResourceExhausted},
+		{"Declared_size_over_limit", []*countpb.CountRequest{options(&countpb.CountOptions{SizeBytes: cfg.MaxBytes + 1}), chunk("email\n")}, codes.ResourceExhausted},
+		{"Emails_over_limit", []*countpb.CountRequest{options(&countpb.CountOptions{}), email(strings.Repeat("a", 5000) + "@x.com")}, codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := count(context.Background(), c, tt.reqs...)
+			if status.Code(err) != tt.code {
+				t.Fatalf("error = %v, want code %v", err, tt.code)
+			}
+		})
+	}
+}
+
+// TestCount_MaxConcurrent holds a count open and checks that a second one is
+// turned away until it ends.
+func TestCount_MaxConcurrent(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxConcurrent = 1
+	c := dial(t, cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	held, err := c.Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*countpb.CountRequest{options(&countpb.CountOptions{Id: "held"}), chunk("name,email\n")} {
+		if err := held.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The count is registered for WatchProgress once its input started.
+	watch, err := c.WatchProgress(ctx, &countpb.WatchProgressRequest{Id: "held"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watch.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := []*countpb.CountRequest{options(&countpb.CountOptions{}), chunk(customersCSV)}
+	if _, err := count(ctx, c, reqs...); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second count: error = %v, want ResourceExhausted", err)
+	}
+	if err := held.Send(chunk(strings.TrimPrefix(customersCSV, "name,email\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := held.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := count(ctx, c, reqs...); err != nil {
+		t.Fatalf("count after the first ended: %v", err)
+	}
+}
+
+func TestCount_Metrics(t *testing.T) {
+	cfg := testConfig()
+	cfg.Metrics = metrics.NewRegistry(0)
+	c := dial(t, cfg)
+
+	if _, err := count(context.Background(), c, options(&countpb.CountOptions{}), chunk(customersCSV)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := count(context.Background(), c, options(&countpb.CountOptions{}), chunk("id,mail\n1,a@x.com\n")); err == nil {
+		t.Fatal("count without an email header succeeded")
+	}
+	var b strings.Builder
+	if _, err := cfg.Metrics.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`edc_runs_total{outcome="success"} 1`, `edc_runs_total{outcome="failure"} 1`, "edc_rows_total 4", "edc_unique_domains 2"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics are missing %q:\n%s", want, b.String())
+		}
+	}
+}
+
+// TestWatchProgress watches a count that has not started yet and follows it
+// to the end.
+func TestWatchProgress(t *testing.T) {
+	cfg := testConfig()
+	cfg.Import.ProgressInterval = time.Millisecond
+	c := dial(t, cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	watch, err := c.WatchProgress(ctx, &countpb.WatchProgressRequest{Id: "nightly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := c.Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := append([]*countpb.CountRequest{options(&countpb.CountOptions{Id: "nightly", SizeBytes: int64(len(customersCSV))})}, chunks(customersCSV, 5)...)
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+
+	var last *countpb.Progress
+	for {
+		p, err := watch.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last != nil && last.Done {
+			t.Fatalf("progress %v after the final one", p)
+		}
+		last = p
+	}
+	want := &countpb.Progress{BytesRead: int64(len(customersCSV)), TotalBytes: int64(len(customersCSV)), Rows: 4, BadRows: 1, Done: true}
+	if last == nil {
+		t.Fatal("no progress received")
+	}
+	last.ElapsedSeconds = 0
+	if !proto.Equal(last, want) {
+		t.Fatalf("final progress = %v, want %v", last, want)
+	}
+
+	// A finished count can still be watched for a while.
+	late, err := c.WatchProgress(ctx, &countpb.WatchProgressRequest{Id: "nightly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := late.Recv(); err != nil || !p.Done {
+		t.Fatalf("late watcher got %v, %v", p, err)
+	}
+}
+
+func TestWatchProgress_Errors(t *testing.T) {
+	c := dial(t, testConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A failed count ends the watch with its error.
+	if _, err := count(ctx, c, options(&countpb.CountOptions{Id: "bad"}), chunk("id,mail\n1,a@x.com\n")); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("count error = %v", err)
+	}
+	watch, err := c.WatchProgress(ctx, &countpb.WatchProgressRequest{Id: "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watch.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("watch of a failed count = %v", err)
+	}
+
+	watch, err = c.WatchProgress(ctx, &countpb.WatchProgressRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watch.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("watch without id = %v", err)
+	}
+
+	short, cancelShort := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelShort()
+	watch, err = c.WatchProgress(short, &countpb.WatchProgressRequest{Id: "never"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watch.Recv(); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("watch of a count that never starts = %v", err)
+	}
+}
+
+// blockingStream is a Count stream whose Recv hands out msgs, then blocks
+// until release is closed. inRecv counts the calls in progress, and late
+// is set by a call made once returned is.
+type blockingStream struct {
+	grpc.ServerStream
+	msgs     []*countpb.CountRequest
+	release  chan struct{}
+	inRecv   atomic.Int32
+	returned atomic.Bool
+	late     atomic.Bool
+}
+
+func (s *blockingStream) Context() context.Context { return context.Background() }
+
+func (s *blockingStream) Recv() (*countpb.CountRequest, error) {
+	s.inRecv.Add(1)
+	defer s.inRecv.Add(-1)
+	if s.returned.Load() {
+		s.late.Store(true)
+	}
+	if len(s.msgs) > 0 {
+		m := s.msgs[0]
+		s.msgs = s.msgs[1:]
+		return m, nil
+	}
+	<-s.release
+	return nil, io.EOF
+}
+
+func (s *blockingStream) SendAndClose(*countpb.CountResponse) error { return nil }
+
+// TestCount_WaitsForFeeder fails the import on its header while the client
+// is still streaming and checks that Count does not return while the stream
+// is still being read.
+func TestCount_WaitsForFeeder(t *testing.T) {
+	stream := &blockingStream{
+		msgs:    []*countpb.CountRequest{options(&countpb.CountOptions{}), chunk("id,mail\n1,a@x.com\n")},
+		release: make(chan struct{}),
+	}
+	time.AfterFunc(50*time.Millisecond, func() { close(stream.release) })
+
+	err := New(testConfig()).Count(stream)
+	stream.returned.Store(true)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Count error = %v, want InvalidArgument", err)
+	}
+	if n := stream.inRecv.Load(); n != 0 {
+		t.Fatalf("Count returned with %d Recv calls in progress", n)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if stream.late.Load() {
+		t.Fatal("Recv was called after Count returned")
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	s := New(testConfig())
+	r, err := s.register("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.register("a"); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("second register of a running id = %v", err)
+	}
+	r.finish(nil)
+	if _, err := s.register("a"); err != nil {
+		t.Fatalf("register after the first finished: %v", err)
+	}
+}
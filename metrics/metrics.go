@@ -0,0 +1,239 @@
+// Package metrics exposes import results in the Prometheus text exposition
+// format, for scraping from the HTTP server or for the node_exporter textfile
+// collector after a batch run.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Outcomes counted in edc_runs_total.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// reasons are the bad row reasons that are always exported, so every series
+// exists from the first scrape.
+var reasons = []string{
+	customerimporter.RejectMissingEmail,
+	customerimporter.RejectInvalidEmail,
+	customerimporter.RejectInvalidDomain,
+	customerimporter.ReasonMalformed,
+}
+
+var phases = []string{"open", "parse", "sort", "export"}
+
+// Registry accumulates the results of import runs. Counters sum over every
+// observed run; the unique domain and top domain gauges describe the last
+// successful one. It is safe for concurrent use.
+type Registry struct {
+	topN int
+
+	mu       sync.Mutex
+	runs     map[string]int
+	rows     int
+	bytes    int64
+	badRows  map[string]int
+	phases   map[string]time.Duration
+	unique   int
+	top      []customerimporter.DomainData
+	lastRun  time.Time
+	observed bool
+}
+
+// NewRegistry returns an empty registry. topN > 0 adds a gauge per domain for
+// the topN largest domains of the last run.
+func NewRegistry(topN int) *Registry {
+	return &Registry{
+		topN:    topN,
+		runs:    make(map[string]int),
+		badRows: make(map[string]int),
+		phases:  make(map[string]time.Duration),
+	}
+}
+
+// Observe records a successful run. export is the time spent writing the
+// results, zero if they were not written.
+func (r *Registry) Observe(res customerimporter.Result, export time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs[OutcomeSuccess]++
+	r.rows += res.Stats.TotalRows
+	r.bytes += res.BytesRead
+	for reason, n := range res.BadRowsByReason {
+		r.badRows[reason] += n
+	}
+	r.phases["open"] += res.Timings.Open
+	r.phases["parse"] += res.Timings.Parse
+	r.phases["sort"] += res.Timings.Sort
+	r.phases["export"] += export
+	r.unique = res.Stats.UniqueDomains
+	if r.topN > 0 {
+		r.top = append(r.top[:0], res.Data[:min(r.topN, len(res.Data))]...)
+	}
+	r.lastRun = time.Now()
+	r.observed = true
+}
+
+// ObserveFailure records a run that failed.
+func (r *Registry) ObserveFailure() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs[OutcomeFailure]++
+	r.lastRun = time.Now()
+}
+
+// WriteTo writes the metrics in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	m := func(name, typ, help string) {
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	sample := func(name string, labels []string, v float64) {
+		cw.WriteString(name)
+		if len(labels) > 0 {
+			cw.WriteString("{")
+			for i := 0; i < len(labels); i += 2 {
+				if i > 0 {
+					cw.WriteString(",")
+				}
+				fmt.Fprintf(cw, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+			}
+			cw.WriteString("}")
+		}
+		fmt.Fprintf(cw, " %s\n", strconv.FormatFloat(v, 'g', -1, 64))
+	}
+
+	m("edc_runs_total", "counter", "Import runs by outcome.")
+	for _, outcome := range []string{OutcomeSuccess, OutcomeFailure} {
+		sample("edc_runs_total", []string{"outcome", outcome}, float64(r.runs[outcome]))
+	}
+	m("edc_rows_total", "counter", "Rows read from successful runs, bad rows included.")
+	sample("edc_rows_total", nil, float64(r.rows))
+	m("edc_bad_rows_total", "counter", "Bad rows of successful runs by reason.")
+	for _, reason := range mergeKeys(reasons, r.badRows) {
+		sample("edc_bad_rows_total", []string{"reason", labelValue(reason)}, float64(r.badRows[reason]))
+	}
+	m("edc_bytes_read_total", "counter", "Input bytes read by successful runs.")
+	sample("edc_bytes_read_total", nil, float64(r.bytes))
+	m("edc_phase_duration_seconds_total", "counter", "Time spent in each phase of successful runs.")
+	for _, phase := range phases {
+		sample("edc_phase_duration_seconds_total", []string{"phase", phase}, r.phases[phase].Seconds())
+	}
+	if r.observed {
+		m("edc_unique_domains", "gauge", "Distinct domains counted by the last successful run.")
+		sample("edc_unique_domains", nil, float64(r.unique))
+	}
+	if len(r.top) > 0 {
+		m("edc_domain_customers", "gauge", fmt.Sprintf("Customers of the %d largest domains of the last successful run.", r.topN))
+		for _, d := range r.top {
+			sample("edc_domain_customers", []string{"domain", d.Domain}, float64(d.CustomerQuantity))
+		}
+	}
+	if !r.lastRun.IsZero() {
+		m("edc_last_run_timestamp_seconds", "gauge", "Unix time the last run ended.")
+		sample("edc_last_run_timestamp_seconds", nil, float64(r.lastRun.UnixMilli())/1000)
+	}
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the metrics for Prometheus to scrape.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteTo(w)
+}
+
+// WriteTextfile replaces path with the metrics of r through a temporary file
+// and a rename, as the node_exporter textfile collector requires; it only
+// reads files ending in .prom.
+func WriteTextfile(path string, r *Registry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := r.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	return nil
+}
+
+// labelValue turns a reason such as "invalid address" into invalid_address.
+func labelValue(s string) string {
+	return strings.ReplaceAll(s, " ", "_")
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// mergeKeys returns fixed followed by the other keys of m, sorted.
+func mergeKeys(fixed []string, m map[string]int) []string {
+	keys := append([]string(nil), fixed...)
+	var extra []string
+	for k := range m {
+		known := false
+		for _, f := range fixed {
+			known = known || f == k
+		}
+		if !known {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	return append(keys, extra...)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+func (c *countingWriter) WriteString(s string) (int, error) {
+	return c.Write([]byte(s))
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+var result = customerimporter.Result{
+	Data: []customerimporter.DomainData{
+		{Domain: "x.com", CustomerQuantity: 5},
+		{Domain: "y.com", CustomerQuantity: 3},
+		{Domain: `we"ird.com`, CustomerQuantity: 1},
+	},
+	Stats:           customerimporter.Stats{TotalRows: 12, BadRows: 3, MalformedRows: 1, UniqueDomains: 3},
+	BadRowsByReason: map[string]int{customerimporter.RejectInvalidEmail: 2, customerimporter.ReasonMalformed: 1},
+	BytesRead:       2048,
+	Timings:         customerimporter.Timings{Open: time.Millisecond, Parse: 1500 * time.Millisecond, Sort: 2 * time.Millisecond},
+}
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	if err != nil || n != int64(b.Len()) {
+		t.Fatalf("WriteTo = %d, %v; wrote %d bytes", n, err, b.Len())
+	}
+	return b.String()
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(0)
+	empty := render(t, r)
+	for _, line := range []string{
+		`edc_runs_total{outcome="success"} 0`,
+		`edc_bad_rows_total{reason="missing_email"} 0`,
+		`edc_phase_duration_seconds_total{phase="export"} 0`,
+	} {
+		if !strings.Contains(empty, line+"\n") {
+			t.Errorf("empty registry lacks %q:\n%s", line, empty)
+		}
+	}
+	if strings.Contains(empty, "edc_unique_domains") || strings.Contains(empty, "edc_last_run") {
+		t.Errorf("gauges of the last run exported before any run:\n%s", empty)
+	}
+
+	r.Observe(result, 250*time.Millisecond)
+	r.Observe(result, 250*time.Millisecond)
+	r.ObserveFailure()
+	got := render(t, r)
+	for _, line := range []string{
+		"# TYPE edc_rows_total counter",
+		`edc_runs_total{outcome="success"} 2`,
+		`edc_runs_total{outcome="failure"} 1`,
+		"edc_rows_total 24",
+		`edc_bad_rows_total{reason="invalid_address"} 4`,
+		`edc_bad_rows_total{reason="malformed"} 2`,
+		`edc_bad_rows_total{reason="invalid_domain"} 0`,
+		"edc_bytes_read_total 4096",
+		`edc_phase_duration_seconds_total{phase="parse"} 3`,
+		`edc_phase_duration_seconds_total{phase="export"} 0.5`,
+		"# TYPE edc_unique_domains gauge",
+		"edc_unique_domains 3",
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, got)
+		}
+	}
+	if strings.Contains(got, "edc_domain_customers") {
+		t.Errorf("top domains exported without topN:\n%s", got)
+	}
+}
+
+func TestRegistry_TopDomains(t *testing.T) {
+	r := NewRegistry(2)
+	r.Observe(result, 0)
+	got := render(t, r)
+	if !strings.Contains(got, `edc_domain_customers{domain="x.com"} 5`+"\n") || !strings.Contains(got, `edc_domain_customers{domain="y.com"} 3`+"\n") {
+		t.Fatalf("top domains missing:\n%s", got)
+	}
+	if strings.Contains(got, "ird.com") {
+		t.Fatalf("more than 2 domains exported:\n%s", got)
+	}
+
+	r = NewRegistry(5)
+	r.Observe(result, 0)
+	if got := render(t, r); !strings.Contains(got, `edc_domain_customers{domain="we\"ird.com"} 1`) {
+		t.Fatalf("label not escaped:\n%s", got)
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edc.prom")
+	r := NewRegistry(0)
+	r.Observe(result, 0)
+	if err := WriteTextfile(path, r); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != render(t, r) {
+		t.Fatalf("textfile = %q", b)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewRegistry(0).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Header().Get("Content-Type") != ContentType || !strings.Contains(rec.Body.String(), "edc_rows_total 0") {
+		t.Fatalf("response %v %q", rec.Header(), rec.Body)
+	}
+}
@@ -0,0 +1,302 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/exporter"
+)
+
+// maxFieldBytes caps a multipart form field; only the upload may be large.
+const maxFieldBytes = 64 << 10
+
+// output is a result format a client can ask for in Accept.
+type output struct {
+	mediaType   string
+	contentType string
+	format      string
+}
+
+// outputs lists the result formats in the order they are preferred when the
+// client accepts several equally. SQLite needs a file and is not offered.
+var outputs = []output{
+	{"text/csv", "text/csv; charset=utf-8", exporter.FormatCSV},
+	{"application/json", "application/json", exporter.FormatJSON},
+	{"application/vnd.apache.parquet", "application/vnd.apache.parquet", exporter.FormatParquet},
+}
+
+// inputTypes maps upload media types to input formats, for uploads whose
+// file name does not tell.
+var inputTypes = map[string]string{
+	"text/csv":             customerimporter.FormatCSV,
+	"application/json":     customerimporter.FormatJSON,
+	"application/x-ndjson": customerimporter.FormatNDJSON,
+	"application/jsonl":    customerimporter.FormatNDJSON,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": customerimporter.FormatXLSX,
+	"application/vnd.apache.parquet":                                    customerimporter.FormatParquet,
+	"application/vnd.sqlite3":                                           customerimporter.FormatSQLite,
+	"application/mbox":                                                  customerimporter.FormatMbox,
+	"text/vcard":                                                        customerimporter.FormatVCard,
+	"text/x-ldif":                                                       customerimporter.FormatLDIF,
+	"application/zip":                                                   customerimporter.FormatZip,
+	"application/x-tar":                                                 customerimporter.FormatTar,
+	"application/gzip":                                                  customerimporter.FormatTarGz,
+}
+
+// handleCount imports the uploaded file and writes the result in the format
+// chosen by the Accept header. The upload is either the raw request body or
+// the first file part of a multipart/form-data body.
+func (s *Server) handleCount(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	out, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		writeError(w, &apiError{Status: http.StatusNotAcceptable, Code: "not_acceptable", Message: "results are available as text/csv, application/json or application/vnd.apache.parquet"})
+		return
+	}
+	if s.cfg.MaxBodyBytes > 0 {
+		if r.ContentLength > s.cfg.MaxBodyBytes {
+			writeError(w, &apiError{Status: http.StatusRequestEntityTooLarge, Code: "request_too_large", Message: fmt.Sprintf("request body is larger than %d bytes", s.cfg.MaxBodyBytes)})
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)
+	}
+	if !s.acquire() {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, &apiError{Status: http.StatusServiceUnavailable, Code: "busy", Message: fmt.Sprintf("%d imports are already running, retry later", cap(s.slots))})
+		return
+	}
+	defer s.release()
+
+	params := r.URL.Query()
+	body, name, mediaType, err := upload(r, params)
+	var cfg customerimporter.Config
+	if err == nil {
+		cfg, err = s.importConfig(params, name, mediaType)
+	}
+	if err != nil {
+		s.fail(w, r, name, err)
+		return
+	}
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "multipart/form-data" && r.ContentLength > 0 {
+		cfg.Size = r.ContentLength
+	}
+
+	res, err := customerimporter.New(cfg).ImportReaderContext(r.Context(), body)
+	if err == nil {
+		err = customerimporter.CheckBadRows(res.Stats, s.cfg.MaxBadRows, s.cfg.MaxBadRatio)
+	}
+	if err != nil {
+		s.observe(res, 0, err)
+		s.fail(w, r, name, err)
+		return
+	}
+
+	exportStart := time.Now()
+	if err := writeResult(w, r, out, res); err != nil {
+		s.observe(res, 0, err)
+		return
+	}
+	s.observe(res, time.Since(exportStart), nil)
+	slog.Info("count",
+		"remote", r.RemoteAddr,
+		"file", name,
+		"format", res.Format,
+		"output", out.format,
+		"total_rows", res.Stats.TotalRows,
+		"bad_rows", res.Stats.BadRows,
+		"unique_domains", res.Stats.UniqueDomains,
+		"duration", time.Since(start),
+	)
+}
+
+// writeResult sends res in the negotiated format, with the stats as headers.
+// It returns the error that cut the response short, already logged.
+func writeResult(w http.ResponseWriter, r *http.Request, out output, res customerimporter.Result) error {
+	h := w.Header()
+	h.Set("Content-Type", out.contentType)
+	h.Set("Vary", "Accept")
+	h.Set("X-Input-Format", res.Format)
+	h.Set("X-Total-Rows", strconv.Itoa(res.Stats.TotalRows))
+	h.Set("X-Bad-Rows", strconv.Itoa(res.Stats.BadRows))
+	h.Set("X-Malformed-Rows", strconv.Itoa(res.Stats.MalformedRows))
+	h.Set("X-Unique-Domains", strconv.Itoa(res.Stats.UniqueDomains))
+	if err := exporter.Write(w, out.format, res); err != nil {
+		// The status line is gone; all that is left is to log it.
+		slog.Error("failed writing response", "remote", r.RemoteAddr, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Server) fail(w http.ResponseWriter, r *http.Request, name string, err error) {
+	e := importError(err)
+	slog.Warn("count failed", "remote", r.RemoteAddr, "file", name, "status", e.Status, "code", e.Code, "error", err)
+	writeError(w, e)
+}
+
+// upload returns the uploaded data with its file name and media type. For a
+// multipart body the form fields ahead of the file part are added to params;
+// fields after it would need the upload buffered and are not read.
+func upload(r *http.Request, params url.Values) (io.Reader, string, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, params.Get("filename"), mediaType, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", "", badRequest("invalid_multipart", err.Error())
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, "", "", badRequest("missing_file", "the form has no file part")
+		}
+		if err != nil {
+			return nil, "", "", fmt.Errorf("read form: %w", err)
+		}
+		if part.FileName() != "" {
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			return part, part.FileName(), partType, nil
+		}
+		value, err := io.ReadAll(io.LimitReader(part, maxFieldBytes+1))
+		if err != nil {
+			return nil, "", "", fmt.Errorf("read form: %w", err)
+		}
+		if len(value) > maxFieldBytes {
+			return nil, "", "", badRequest("invalid_option", fmt.Sprintf("form field %q is longer than %d bytes", part.FormName(), maxFieldBytes))
+		}
+		params.Set(part.FormName(), string(value))
+	}
+}
+
+// importConfig applies the request options to the server defaults. Options
+// are named like the command line flags (email-header, format, strict, ...).
+// There is no query option: SQLite can ATTACH other databases on the server
+// and read its pragmas, so only the server's -query runs on an upload.
+func (s *Server) importConfig(params url.Values, name, mediaType string) (customerimporter.Config, error) {
+	cfg := s.cfg.Import
+	cfg.Path, cfg.StatePath = name, ""
+	var strict, tolerant bool
+	for key, values := range params {
+		v := values[len(values)-1]
+		var err error
+		switch key {
+		case "filename":
+		case "email-header":
+			cfg.EmailHeader = v
+		case "email-column":
+			cfg.EmailColumn, err = strconv.Atoi(v)
+			if err == nil && cfg.EmailColumn < 0 {
+				err = errors.New("must be a positive position")
+			}
+		case "no-header":
+			cfg.NoHeader, err = strconv.ParseBool(v)
+		case "allow-single-label-domain":
+			cfg.AllowSingleLabelDomain, err = strconv.ParseBool(v)
+		case "encoding":
+			cfg.Encoding = v
+		case "format":
+			cfg.Format = v
+		case "sheet":
+			cfg.Sheet = v
+		case "email-path":
+			cfg.EmailPath = v
+		case "mbox-headers":
+			cfg.MboxHeaders = strings.Split(v, ",")
+		case "members":
+			cfg.Members = v
+		case "strict":
+			strict, err = strconv.ParseBool(v)
+		case "tolerant":
+			tolerant, err = strconv.ParseBool(v)
+		default:
+			return cfg, badRequest("unknown_option", fmt.Sprintf("unknown option %q", key))
+		}
+		if err != nil {
+			return cfg, badRequest("invalid_option", fmt.Sprintf("invalid %s %q: %v", key, v, err))
+		}
+	}
+
+	switch {
+	case strict && tolerant:
+		return cfg, badRequest("invalid_option", "strict and tolerant are mutually exclusive")
+	case strict:
+		cfg.Malformed = customerimporter.MalformedStrict
+	case tolerant:
+		cfg.Malformed = customerimporter.MalformedSkip
+	}
+	if cfg.NoHeader && cfg.EmailColumn == 0 {
+		return cfg, badRequest("email_column_required", "no-header requires email-column")
+	}
+	if cfg.Format == "" {
+		if _, ok := customerimporter.FormatForPath(name); !ok {
+			cfg.Format = inputTypes[mediaType]
+		}
+	}
+	return cfg, nil
+}
+
+// negotiate picks the result format for an Accept header. A format's weight
+// comes from the most specific media range matching it; an empty header
+// accepts anything. ok is false when no format is acceptable.
+func negotiate(accept string) (output, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return outputs[0], true
+	}
+	type mediaRange struct {
+		typ, sub string
+		q        float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		typ, sub, _ := strings.Cut(mt, "/")
+		ranges = append(ranges, mediaRange{typ, sub, q})
+	}
+
+	best, bestQ := -1, 0.0
+	for i, out := range outputs {
+		typ, sub, _ := strings.Cut(out.mediaType, "/")
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch {
+			case r.typ == typ && r.sub == sub:
+				s = 2
+			case r.typ == typ && r.sub == "*":
+				s = 1
+			case r.typ == "*" && r.sub == "*":
+				s = 0
+			}
+			if s > specificity {
+				specificity, q = s, r.q
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	if best < 0 {
+		return output{}, false
+	}
+	return outputs[best], true
+}
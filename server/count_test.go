@@ -0,0 +1,203 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/exporter"
+)
+
+const customersCSV = "name,email\nA,a@x.com\nB,b@y.com\nC,c@x.com\nD,broken\n"
+
+var customersData = []customerimporter.DomainData{
+	{Domain: "x.com", CustomerQuantity: 2},
+	{Domain: "y.com", CustomerQuantity: 1},
+}
+
+func newTestServer() *Server {
+	return New(Config{
+		Import:      customerimporter.Config{EmailHeader: "email"},
+		MaxBadRows:  -1,
+		MaxBadRatio: -1,
+	})
+}
+
+// multipartBody builds a form with the given fields followed by a file part.
+func multipartBody(t *testing.T, fields [][2]string, filename, content string) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, f := range fields {
+		if err := mw.WriteField(f[0], f[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf, mw.FormDataContentType()
+}
+
+func zipOf(t *testing.T, name, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestCount(t *testing.T) {
+	form, formType := multipartBody(t, [][2]string{{"email-column", "2"}, {"no-header", "true"}}, "customers.txt", "A,a@x.com\nB,b@y.com\nC,c@x.com\nD,broken\n")
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		accept      string
+		body        string
+		wantType    string
+		wantFormat  string
+	}{
+		{name: "Raw_CSV_default_output", target: "/count", body: customersCSV, wantType: "text/csv; charset=utf-8", wantFormat: "csv"},
+		{name: "JSON_result", target: "/count", accept: "application/json", body: customersCSV, wantType: "application/json", wantFormat: "json"},
+		{name: "Parquet_result", target: "/count", accept: "application/vnd.apache.parquet", body: customersCSV, wantType: "application/vnd.apache.parquet", wantFormat: "parquet"},
+		{
+			name: "NDJSON_by_content_type", target: "/count", contentType: "application/x-ndjson",
+			body:     `{"email":"a@x.com"}` + "\n" + `{"email":"b@y.com"}` + "\n" + `{"email":"c@x.com"}` + "\n" + `{"email":"broken"}` + "\n",
+			wantType: "text/csv; charset=utf-8", wantFormat: "csv",
+		},
+		{
+			name: "Zip_spooled_by_filename", target: "/count?filename=bundle.zip&members=*.csv", contentType: "application/octet-stream",
+			body: zipOf(t, "in/customers.csv", customersCSV), accept: "application/json", wantType: "application/json", wantFormat: "json",
+		},
+		{name: "Multipart_with_fields", target: "/count", contentType: formType, body: form.String(), accept: "text/*", wantType: "text/csv; charset=utf-8", wantFormat: "csv"},
+	}
+
+	s := newTestServer()
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("[%s] status = %d: %s", tt.name, rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+			t.Fatalf("[%s] Content-Type = %q, want %q", tt.name, got, tt.wantType)
+		}
+		if rec.Header().Get("X-Total-Rows") != "4" || rec.Header().Get("X-Bad-Rows") != "1" || rec.Header().Get("X-Unique-Domains") != "2" {
+			t.Fatalf("[%s] stats headers = %v", tt.name, rec.Header())
+		}
+		got, err := exporter.ReadResult(rec.Body, tt.wantFormat)
+		if err != nil {
+			t.Fatalf("[%s] read result: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got.Data, customersData) {
+			t.Fatalf("[%s] data = %+v, want %+v", tt.name, got.Data, customersData)
+		}
+	}
+}
+
+func TestCount_Errors(t *testing.T) {
+	noFile := &bytes.Buffer{}
+	mw := multipart.NewWriter(noFile)
+	mw.WriteField("email-header", "email")
+	mw.Close()
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		accept      string
+		body        string
+		cfg         func(*Config)
+		status      int
+		code        string
+		line        int
+	}{
+		{name: "Missing_header", target: "/count", body: "id,mail\n1,a@x.com\n", status: http.StatusUnprocessableEntity, code: "email_header_missing"},
+		{name: "Malformed_row_strict", target: "/count?strict=true", body: "email\n\"a@x.com\n", status: http.StatusUnprocessableEntity, code: "malformed_row", line: 2},
+		{name: "Bad_rows_exceeded", target: "/count", body: customersCSV, cfg: func(c *Config) { c.MaxBadRows = 0 }, status: http.StatusUnprocessableEntity, code: "bad_rows_exceeded"},
+		{name: "Unsupported_format", target: "/count?format=docx", body: "x", status: http.StatusUnsupportedMediaType, code: "unsupported_format"},
+		{name: "Unsupported_encoding", target: "/count?encoding=ebcdic", body: customersCSV, status: http.StatusBadRequest, code: "unsupported_encoding"},
+		{name: "Unknown_option", target: "/count?emailheader=mail", body: customersCSV, status: http.StatusBadRequest, code: "unknown_option"},
+		{name: "Query_option", target: "/count?format=sqlite&query=" + url.QueryEscape("ATTACH DATABASE '/etc/x.db' AS x; SELECT email FROM x.t"), body: "x", status: http.StatusBadRequest, code: "unknown_option"},
+		{name: "Invalid_option", target: "/count?email-column=first", body: customersCSV, status: http.StatusBadRequest, code: "invalid_option"},
+		{name: "Strict_and_tolerant", target: "/count?strict=1&tolerant=1", body: customersCSV, status: http.StatusBadRequest, code: "invalid_option"},
+		{name: "No_header_without_column", target: "/count?no-header=true", body: customersCSV, status: http.StatusBadRequest, code: "email_column_required"},
+		{name: "Form_without_file", target: "/count", contentType: mw.FormDataContentType(), body: noFile.String(), status: http.StatusBadRequest, code: "missing_file"},
+		{name: "Not_acceptable", target: "/count", accept: "application/xml", body: customersCSV, status: http.StatusNotAcceptable, code: "not_acceptable"},
+	}
+
+	for _, tt := range tests {
+		cfg := Config{Import: customerimporter.Config{EmailHeader: "email"}, MaxBadRows: -1, MaxBadRatio: -1}
+		if tt.cfg != nil {
+			tt.cfg(&cfg)
+		}
+		req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		New(cfg).ServeHTTP(rec, req)
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorBody(t, rec, tt.status, tt.code)
+			if got.Line != tt.line {
+				t.Fatalf("line = %d, want %d", got.Line, tt.line)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", "csv", true},
+		{"*/*", "csv", true},
+		{"application/json", "json", true},
+		{"application/*", "json", true},
+		{"text/csv;q=0.5, application/json", "json", true},
+		{"application/json;q=0.2, */*;q=0.1", "json", true},
+		{"*/*;q=0.5, text/csv;q=0", "json", true},
+		{"application/vnd.apache.parquet, text/csv;q=0.9", "parquet", true},
+		{"text/html", "", false},
+		{"text/csv;q=0", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := negotiate(tt.accept)
+		if ok != tt.ok || got.format != tt.want {
+			t.Errorf("negotiate(%q) = %q, %v; want %q, %v", tt.accept, got.format, ok, tt.want, tt.ok)
+		}
+	}
+}
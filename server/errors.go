@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// apiError is the body of every error response, wrapped as
+// {"error": {"code": "...", "message": "..."}}. Code is stable for clients to
+// branch on; Message is for people.
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Line and Column locate a malformed row, as in customerimporter.RowError.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+func (e *apiError) Error() string { return e.Message }
+
+func writeError(w http.ResponseWriter, e *apiError) {
+	writeJSON(w, e.Status, struct {
+		Error *apiError `json:"error"`
+	}{e})
+}
+
+func badRequest(code, message string) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: code, Message: message}
+}
+
+// importError maps an import error to its response. Problems with the
+// request options are 400, an unreadable upload 415, and content the
+// importer rejected 422.
+func importError(err error) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	e := &apiError{Status: http.StatusUnprocessableEntity, Code: "invalid_input", Message: err.Error()}
+	var maxErr *http.MaxBytesError
+	var rowErr *customerimporter.RowError
+	switch {
+	case errors.As(err, &maxErr):
+		e.Status, e.Code = http.StatusRequestEntityTooLarge, "request_too_large"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		e.Status, e.Code = http.StatusServiceUnavailable, "canceled"
+	case errors.Is(err, customerimporter.ErrEmailHeaderMissing):
+		e.Code = "email_header_missing"
+	case errors.Is(err, customerimporter.ErrEmailColumnType):
+		e.Code = "email_column_type"
+	case errors.Is(err, customerimporter.ErrSheetNotFound):
+		e.Code = "sheet_not_found"
+	case errors.Is(err, customerimporter.ErrBadRowThreshold):
+		e.Code = "bad_rows_exceeded"
+	case errors.As(err, &rowErr):
+		e.Code, e.Line, e.Column = "malformed_row", rowErr.Line, rowErr.Column
+	case errors.Is(err, customerimporter.ErrUnsupportedFormat):
+		e.Status, e.Code = http.StatusUnsupportedMediaType, "unsupported_format"
+	case errors.Is(err, customerimporter.ErrEmailColumnMissing):
+		e.Status, e.Code = http.StatusBadRequest, "email_column_required"
+	case errors.Is(err, customerimporter.ErrUnsupportedEncoding):
+		e.Status, e.Code = http.StatusBadRequest, "unsupported_encoding"
+	case errors.Is(err, customerimporter.ErrInvalidJSONPath):
+		e.Status, e.Code = http.StatusBadRequest, "invalid_json_path"
+	case errors.Is(err, customerimporter.ErrQueryMissing):
+		e.Status, e.Code = http.StatusBadRequest, "query_required"
+	}
+	return e
+}
@@ -0,0 +1,341 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/exporter"
+)
+
+// Defaults for the job settings of Config left zero.
+const (
+	DefaultJobQueue     = 16
+	DefaultJobRetention = 24 * time.Hour
+)
+
+// Start loads the jobs of Config.JobsDir and starts the job workers and the
+// retention sweep. Without a JobsDir it does nothing and the job endpoints
+// answer 404. Call Stop to end them.
+func (s *Server) Start() error {
+	if s.cfg.JobsDir == "" {
+		return nil
+	}
+	st, err := openJobs(s.cfg.JobsDir, s.cfg.JobQueue)
+	if err != nil {
+		return err
+	}
+	s.jobs = st
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stop = cancel
+	for range s.cfg.JobWorkers {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.work(ctx)
+		}()
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.sweep(ctx)
+	}()
+	return nil
+}
+
+// Stop stops the job workers and waits for them. Running jobs are
+// interrupted and run again from the start by the next Start.
+func (s *Server) Stop() {
+	if s.stop != nil {
+		s.stop()
+		s.wg.Wait()
+	}
+}
+
+// work runs queued jobs until ctx is done.
+func (s *Server) work(ctx context.Context) {
+	for {
+		jobCtx, cancel := context.WithCancel(ctx)
+		j, ok := s.jobs.next(cancel)
+		if !ok {
+			cancel()
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.jobs.wake:
+			}
+			continue
+		}
+		s.runJob(jobCtx, j)
+		cancel()
+	}
+}
+
+func (s *Server) runJob(ctx context.Context, j job) {
+	start := time.Now()
+	slog.Info("job started", "job", j.ID, "file", j.File)
+	res, export, err := s.countJob(ctx, j)
+	if ctx.Err() != nil {
+		// Stopped by Stop or DELETE: the former resumes on restart, the
+		// latter removed the job already.
+		slog.Info("job interrupted", "job", j.ID)
+		return
+	}
+	os.Remove(s.jobs.path(j.ID, inputFile))
+	s.observe(res, export, err)
+	if err != nil {
+		e := importError(err)
+		slog.Warn("job failed", "job", j.ID, "code", e.Code, "error", err)
+		s.jobs.finish(j.ID, res.Format, nil, e)
+		return
+	}
+	s.jobs.finish(j.ID, res.Format, &res.Stats, nil)
+	slog.Info("job done",
+		"job", j.ID,
+		"file", j.File,
+		"format", res.Format,
+		"total_rows", res.Stats.TotalRows,
+		"bad_rows", res.Stats.BadRows,
+		"unique_domains", res.Stats.UniqueDomains,
+		"duration", time.Since(start),
+	)
+}
+
+// countJob imports a job's saved upload and saves the result as a JSON
+// result document, reporting how long saving took.
+func (s *Server) countJob(ctx context.Context, j job) (customerimporter.Result, time.Duration, error) {
+	cfg, err := s.importConfig(j.Params, j.File, j.MediaType)
+	if err != nil {
+		return customerimporter.Result{}, 0, err
+	}
+	// The upload is saved without its extension; pin the format its name
+	// implied.
+	if cfg.Format == "" {
+		cfg.Format = customerimporter.FormatCSV
+		if format, ok := customerimporter.FormatForPath(j.File); ok {
+			cfg.Format = format
+		}
+	}
+	cfg.Path = s.jobs.path(j.ID, inputFile)
+	cfg.Progress = func(p customerimporter.Progress) { s.jobs.progress(j.ID, p) }
+	cfg.ProgressInterval = time.Second
+
+	res, err := customerimporter.New(cfg).ImportDomainDataContext(ctx)
+	if err == nil {
+		err = customerimporter.CheckBadRows(res.Stats, s.cfg.MaxBadRows, s.cfg.MaxBadRatio)
+	}
+	if err != nil {
+		return res, 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return res, 0, err
+	}
+	start := time.Now()
+	if err := saveResult(s.jobs.path(j.ID, resultFile), res); err != nil {
+		return res, 0, fmt.Errorf("save result: %w", err)
+	}
+	return res, time.Since(start), nil
+}
+
+// saveResult writes res to path as a JSON result document through a
+// temporary file next to it. Unlike the exporter it never creates the
+// directory, so a job deleted meanwhile is not brought back with only its
+// result in it.
+func saveResult(path string, res customerimporter.Result) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// Removing fails harmlessly once the file has been renamed.
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := exporter.WriteJSON(f, res); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// sweep removes expired jobs at start and then periodically until ctx is
+// done.
+func (s *Server) sweep(ctx context.Context) {
+	interval := min(s.cfg.JobRetention, time.Minute)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		s.jobs.expire(time.Now().Add(-s.cfg.JobRetention))
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// handleSubmit saves the upload as a new job and answers 202 with the job.
+// Options are checked now, so a job fails later only for its content.
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	if !s.jobsEnabled(w) {
+		return
+	}
+	if s.cfg.MaxBodyBytes > 0 {
+		if r.ContentLength > s.cfg.MaxBodyBytes {
+			writeError(w, &apiError{Status: http.StatusRequestEntityTooLarge, Code: "request_too_large", Message: fmt.Sprintf("request body is larger than %d bytes", s.cfg.MaxBodyBytes)})
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)
+	}
+	if !s.jobs.reserve() {
+		w.Header().Set("Retry-After", "60")
+		writeError(w, &apiError{Status: http.StatusServiceUnavailable, Code: "queue_full", Message: fmt.Sprintf("%d jobs are waiting, retry later", s.jobs.capacity)})
+		return
+	}
+
+	j, err := s.saveUpload(r)
+	var queued job
+	if err == nil {
+		// A worker may pick the job up as soon as it is queued.
+		queued = *j
+		err = s.jobs.enqueue(j)
+	}
+	if err != nil {
+		s.jobs.unreserve()
+		if j != nil {
+			os.RemoveAll(s.jobs.path(j.ID, ""))
+		}
+		e := importError(err)
+		slog.Warn("job rejected", "remote", r.RemoteAddr, "status", e.Status, "code", e.Code, "error", err)
+		writeError(w, e)
+		return
+	}
+	slog.Info("job queued", "job", queued.ID, "remote", r.RemoteAddr, "file", queued.File, "size_bytes", queued.SizeBytes)
+	w.Header().Set("Location", "/jobs/"+queued.ID)
+	writeJSON(w, http.StatusAccepted, s.view(queued))
+}
+
+// saveUpload checks the request options and copies the upload into a new
+// job directory. The returned job is set whenever the directory exists.
+func (s *Server) saveUpload(r *http.Request) (*job, error) {
+	params := r.URL.Query()
+	body, name, mediaType, err := upload(r, params)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.importConfig(params, name, mediaType); err != nil {
+		return nil, err
+	}
+
+	j := &job{
+		ID:        newJobID(),
+		State:     JobQueued,
+		File:      name,
+		MediaType: mediaType,
+		Params:    params,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := os.Mkdir(s.jobs.path(j.ID, ""), 0o755); err != nil {
+		return nil, fmt.Errorf("create job: %w", err)
+	}
+	f, err := os.Create(s.jobs.path(j.ID, inputFile))
+	if err != nil {
+		return j, fmt.Errorf("save upload: %w", err)
+	}
+	j.SizeBytes, err = io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return j, fmt.Errorf("save upload: %w", err)
+	}
+	return j, nil
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if !s.jobsEnabled(w) {
+		return
+	}
+	j, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, jobNotFound(r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.view(j))
+}
+
+// handleResult sends a finished job's result in the format chosen by Accept.
+func (s *Server) handleResult(w http.ResponseWriter, r *http.Request) {
+	if !s.jobsEnabled(w) {
+		return
+	}
+	out, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		writeError(w, &apiError{Status: http.StatusNotAcceptable, Code: "not_acceptable", Message: "results are available as text/csv, application/json or application/vnd.apache.parquet"})
+		return
+	}
+	id := r.PathValue("id")
+	j, ok := s.jobs.get(id)
+	switch {
+	case !ok:
+		writeError(w, jobNotFound(id))
+		return
+	case j.State == JobFailed:
+		writeError(w, &apiError{Status: http.StatusConflict, Code: "job_failed", Message: "job failed: " + j.Error.Message})
+		return
+	case j.State != JobSucceeded:
+		writeError(w, &apiError{Status: http.StatusConflict, Code: "job_not_finished", Message: "job is " + j.State})
+		return
+	}
+
+	res, err := exporter.ReadResultFile(s.jobs.path(id, resultFile))
+	if err != nil {
+		slog.Error("failed reading job result", "job", id, "error", err)
+		writeError(w, &apiError{Status: http.StatusInternalServerError, Code: "internal", Message: "the job result cannot be read"})
+		return
+	}
+	res.Format = j.Format
+	writeResult(w, r, out, res)
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.jobsEnabled(w) {
+		return
+	}
+	id := r.PathValue("id")
+	if !s.jobs.remove(id) {
+		writeError(w, jobNotFound(id))
+		return
+	}
+	slog.Info("job deleted", "job", id, "remote", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// view is a job as GET /jobs/{id} shows it.
+func (s *Server) view(j job) job {
+	if j.State == JobSucceeded {
+		j.ResultURL = "/jobs/" + j.ID + "/result"
+	}
+	return j
+}
+
+func (s *Server) jobsEnabled(w http.ResponseWriter) bool {
+	if s.jobs == nil {
+		writeError(w, &apiError{Status: http.StatusNotFound, Code: "jobs_disabled", Message: "the job API is not enabled on this server"})
+		return false
+	}
+	return true
+}
+
+func jobNotFound(id string) *apiError {
+	return &apiError{Status: http.StatusNotFound, Code: "job_not_found", Message: fmt.Sprintf("no job %q", id)}
+}
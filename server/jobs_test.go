@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/exporter"
+)
+
+func newJobServer(t *testing.T, dir string, cfg Config) *Server {
+	t.Helper()
+	cfg.Import = customerimporter.Config{EmailHeader: "email"}
+	cfg.MaxBadRows, cfg.MaxBadRatio = -1, -1
+	cfg.JobsDir = dir
+	s := New(cfg)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func do(s *Server, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func submit(t *testing.T, s *Server, target, body string) job {
+	t.Helper()
+	rec := do(s, http.MethodPost, target, body)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("submit status = %d: %s", rec.Code, rec.Body)
+	}
+	var j job
+	if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil {
+		t.Fatal(err)
+	}
+	if j.State != JobQueued || rec.Header().Get("Location") != "/jobs/"+j.ID {
+		t.Fatalf("submitted job = %+v, Location %q", j, rec.Header().Get("Location"))
+	}
+	return j
+}
+
+// waitJob polls GET /jobs/{id} until the job has finished.
+func waitJob(t *testing.T, s *Server, id string) job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := do(s, http.MethodGet, "/jobs/"+id, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /jobs/%s = %d: %s", id, rec.Code, rec.Body)
+		}
+		var j job
+		if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil {
+			t.Fatal(err)
+		}
+		if j.State == JobSucceeded || j.State == JobFailed {
+			return j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job still %s", j.State)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobs_Lifecycle(t *testing.T) {
+	dir := t.TempDir()
+	s := newJobServer(t, dir, Config{})
+
+	j := submit(t, s, "/jobs?filename=customers.csv", customersCSV)
+	done := waitJob(t, s, j.ID)
+	if done.State != JobSucceeded || done.Format != "csv" || done.ResultURL != "/jobs/"+j.ID+"/result" {
+		t.Fatalf("finished job = %+v", done)
+	}
+	if want := (customerimporter.Stats{TotalRows: 4, BadRows: 1, UniqueDomains: 2}); *done.Stats != want {
+		t.Fatalf("stats = %+v, want %+v", *done.Stats, want)
+	}
+	if done.Progress == nil || done.Progress.Rows != 4 || done.Progress.BytesRead != int64(len(customersCSV)) {
+		t.Fatalf("progress = %+v", done.Progress)
+	}
+	if _, err := os.Stat(filepath.Join(dir, j.ID, inputFile)); !os.IsNotExist(err) {
+		t.Fatalf("upload kept after the job: %v", err)
+	}
+
+	for _, format := range []string{"csv", "json", "parquet"} {
+		accept := map[string]string{"csv": "text/csv", "json": "application/json", "parquet": "application/vnd.apache.parquet"}[format]
+		rec := do(s, http.MethodGet, done.ResultURL, "", "Accept", accept)
+		if rec.Code != http.StatusOK || rec.Header().Get("X-Total-Rows") != "4" {
+			t.Fatalf("[%s] GET result = %d %v: %s", format, rec.Code, rec.Header(), rec.Body)
+		}
+		got, err := exporter.ReadResult(rec.Body, format)
+		if err != nil {
+			t.Fatalf("[%s] read result: %v", format, err)
+		}
+		if !reflect.DeepEqual(got.Data, customersData) {
+			t.Fatalf("[%s] data = %+v", format, got.Data)
+		}
+	}
+
+	rec := do(s, http.MethodDelete, "/jobs/"+j.ID, "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d", rec.Code)
+	}
+	errorBody(t, do(s, http.MethodGet, "/jobs/"+j.ID, ""), http.StatusNotFound, "job_not_found")
+	if _, err := os.Stat(filepath.Join(dir, j.ID)); !os.IsNotExist(err) {
+		t.Fatalf("job directory kept after DELETE: %v", err)
+	}
+}
+
+func TestJobs_Errors(t *testing.T) {
+	s := newJobServer(t, t.TempDir(), Config{})
+
+	failed := waitJob(t, s, submit(t, s, "/jobs", "id,mail\n1,a@x.com\n").ID)
+	if failed.State != JobFailed || failed.Error == nil || failed.Error.Code != "email_header_missing" {
+		t.Fatalf("failed job = %+v", failed)
+	}
+	errorBody(t, do(s, http.MethodGet, "/jobs/"+failed.ID+"/result", ""), http.StatusConflict, "job_failed")
+
+	// Options are checked on submit.
+	errorBody(t, do(s, http.MethodPost, "/jobs?no-header=1", customersCSV), http.StatusBadRequest, "email_column_required")
+	errorBody(t, do(s, http.MethodPost, "/jobs?query=SELECT+file+AS+email+FROM+pragma_database_list", customersCSV), http.StatusBadRequest, "unknown_option")
+	errorBody(t, do(s, http.MethodGet, "/jobs/nope", ""), http.StatusNotFound, "job_not_found")
+	errorBody(t, do(s, http.MethodDelete, "/jobs/nope", ""), http.StatusNotFound, "job_not_found")
+
+	errorBody(t, do(New(Config{}), http.MethodPost, "/jobs", customersCSV), http.StatusNotFound, "jobs_disabled")
+}
+
+// TestJobs_QueueAndRestart fills the queue of a server whose workers are not
+// running, frees a place by deleting a job, then checks that a restarted
+// server runs the saved jobs.
+func TestJobs_QueueAndRestart(t *testing.T) {
+	dir := t.TempDir()
+	stopped := New(Config{Import: customerimporter.Config{EmailHeader: "email"}, JobsDir: dir, JobQueue: 2})
+	st, err := openJobs(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped.jobs = st
+
+	first := submit(t, stopped, "/jobs", customersCSV)
+	second := submit(t, stopped, "/jobs?tolerant=true", "email\n\"a@x.com\nb@y.com\n")
+	rec := do(stopped, http.MethodPost, "/jobs", customersCSV)
+	errorBody(t, rec, http.StatusServiceUnavailable, "queue_full")
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("queue_full without Retry-After")
+	}
+	errorBody(t, do(stopped, http.MethodGet, "/jobs/"+first.ID+"/result", ""), http.StatusConflict, "job_not_finished")
+
+	// Deleting a queued job frees its place at once.
+	if rec := do(stopped, http.MethodDelete, "/jobs/"+second.ID, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE queued job = %d", rec.Code)
+	}
+	second = submit(t, stopped, "/jobs?tolerant=true", "email\n\"a@x.com\nb@y.com\n")
+
+	s := newJobServer(t, dir, Config{})
+	if got := waitJob(t, s, first.ID); got.State != JobSucceeded || got.Stats.UniqueDomains != 2 {
+		t.Fatalf("first job after restart = %+v", got)
+	}
+	if got := waitJob(t, s, second.ID); got.State != JobSucceeded || got.Stats.MalformedRows != 1 {
+		t.Fatalf("second job after restart = %+v, want its tolerant option kept", got)
+	}
+
+	// Finished jobs survive another restart.
+	s.Stop()
+	s = newJobServer(t, dir, Config{})
+	if rec := do(s, http.MethodGet, "/jobs/"+first.ID+"/result", ""); rec.Code != http.StatusOK {
+		t.Fatalf("result after restart = %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestJobs_Retention(t *testing.T) {
+	dir := t.TempDir()
+	s := newJobServer(t, dir, Config{JobRetention: time.Hour})
+	j := waitJob(t, s, submit(t, s, "/jobs", customersCSV).ID)
+
+	s.jobs.expire(time.Now())
+	errorBody(t, do(s, http.MethodGet, "/jobs/"+j.ID, ""), http.StatusNotFound, "job_not_found")
+	if _, err := os.Stat(filepath.Join(dir, j.ID)); !os.IsNotExist(err) {
+		t.Fatalf("expired job directory kept: %v", err)
+	}
+}
+
+func TestSaveResult(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "job")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, resultFile)
+	res := customerimporter.Result{Data: []customerimporter.DomainData{{Domain: "a.com", CustomerQuantity: 2}}}
+	if err := saveResult(path, res); err != nil {
+		t.Fatal(err)
+	}
+	got, err := exporter.ReadResultFile(path)
+	if err != nil || !reflect.DeepEqual(got.Data, res.Data) {
+		t.Fatalf("saved result = %+v, %v", got, err)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, ".*")); len(names) != 0 {
+		t.Errorf("temporary files left: %v", names)
+	}
+
+	// A job deleted before its result is saved must stay deleted.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveResult(path, res); err == nil {
+		t.Error("saving into a deleted job succeeded")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("deleted job directory recreated: %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+)
+
+// Job states.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Files kept in a job's directory. The input is removed once the job ends.
+const (
+	jobFile    = "job.json"
+	inputFile  = "input"
+	resultFile = "result.json"
+)
+
+// job is the persisted state of an asynchronous count, and the body of
+// GET /jobs/{id}.
+type job struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	// File, MediaType and Params are the upload's name, Content-Type and
+	// options; the import config is rebuilt from them when the job runs.
+	File       string                  `json:"file,omitempty"`
+	MediaType  string                  `json:"media_type,omitempty"`
+	Params     url.Values              `json:"params,omitempty"`
+	Format     string                  `json:"format,omitempty"`
+	SizeBytes  int64                   `json:"size_bytes"`
+	CreatedAt  time.Time               `json:"created_at"`
+	StartedAt  *time.Time              `json:"started_at,omitempty"`
+	FinishedAt *time.Time              `json:"finished_at,omitempty"`
+	Progress   *jobProgress            `json:"progress,omitempty"`
+	Stats      *customerimporter.Stats `json:"stats,omitempty"`
+	Error      *apiError               `json:"error,omitempty"`
+	ResultURL  string                  `json:"result_url,omitempty"`
+}
+
+type jobProgress struct {
+	BytesRead      int64   `json:"bytes_read"`
+	TotalBytes     int64   `json:"total_bytes"`
+	Rows           int     `json:"rows"`
+	BadRows        int     `json:"bad_rows"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// jobStore keeps the jobs of a directory, one subdirectory per job, and the
+// queue of jobs waiting for a worker.
+type jobStore struct {
+	dir      string
+	capacity int
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	// queue holds the IDs of queued jobs in submission order; reserved counts
+	// submissions still uploading, which already hold a queue place.
+	queue    []string
+	reserved int
+	// cancel stops the import of a running job.
+	cancel map[string]func()
+	wake   chan struct{}
+}
+
+// openJobs loads the jobs saved in dir, creating it if needed. Jobs that were
+// queued or running when the server stopped are queued again.
+func openJobs(dir string, capacity int) (*jobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("jobs directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("jobs directory: %w", err)
+	}
+	st := &jobStore{
+		dir:      dir,
+		capacity: capacity,
+		jobs:     make(map[string]*job),
+		cancel:   make(map[string]func()),
+		wake:     make(chan struct{}, 1),
+	}
+	var resumed []*job
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name(), jobFile))
+		var j job
+		if err == nil {
+			err = json.Unmarshal(b, &j)
+		}
+		if err != nil || j.ID != entry.Name() {
+			slog.Warn("skipping unreadable job", "dir", filepath.Join(dir, entry.Name()), "error", err)
+			continue
+		}
+		if j.State == JobQueued || j.State == JobRunning {
+			j.State, j.StartedAt, j.Progress = JobQueued, nil, nil
+			resumed = append(resumed, &j)
+		}
+		st.jobs[j.ID] = &j
+	}
+	sort.Slice(resumed, func(a, b int) bool { return resumed[a].CreatedAt.Before(resumed[b].CreatedAt) })
+	for _, j := range resumed {
+		st.queue = append(st.queue, j.ID)
+	}
+	if len(resumed) > 0 {
+		slog.Info("resuming jobs", "count", len(resumed))
+		st.notify()
+	}
+	return st, nil
+}
+
+func newJobID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (st *jobStore) path(id string, name string) string {
+	return filepath.Join(st.dir, id, name)
+}
+
+// reserve takes a queue place for a submission, without waiting.
+func (st *jobStore) reserve() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.queue)+st.reserved >= st.capacity {
+		return false
+	}
+	st.reserved++
+	return true
+}
+
+// unreserve gives back the place of a submission that failed.
+func (st *jobStore) unreserve() {
+	st.mu.Lock()
+	st.reserved--
+	st.mu.Unlock()
+}
+
+// enqueue saves a submitted job and turns its reserved place into a queue
+// entry. On error the place stays reserved; see unreserve.
+func (st *jobStore) enqueue(j *job) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := st.save(j); err != nil {
+		return err
+	}
+	st.reserved--
+	st.jobs[j.ID] = j
+	st.queue = append(st.queue, j.ID)
+	st.notify()
+	return nil
+}
+
+func (st *jobStore) notify() {
+	select {
+	case st.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next marks the oldest queued job as running and returns a copy of it, or
+// false if the queue is empty.
+func (st *jobStore) next(cancel func()) (job, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for len(st.queue) > 0 {
+		id := st.queue[0]
+		st.queue = st.queue[1:]
+		j, ok := st.jobs[id]
+		if !ok {
+			continue
+		}
+		now := time.Now().UTC()
+		j.State, j.StartedAt, j.Progress = JobRunning, &now, &jobProgress{TotalBytes: j.SizeBytes}
+		if err := st.save(j); err != nil {
+			slog.Error("failed saving job", "job", id, "error", err)
+		}
+		st.cancel[id] = cancel
+		if len(st.queue) > 0 {
+			st.notify()
+		}
+		return *j, true
+	}
+	return job{}, false
+}
+
+func (st *jobStore) progress(id string, p customerimporter.Progress) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if j, ok := st.jobs[id]; ok && j.State == JobRunning {
+		j.Progress = &jobProgress{
+			BytesRead:      p.BytesRead,
+			TotalBytes:     p.TotalBytes,
+			Rows:           p.Rows,
+			BadRows:        p.BadRows,
+			ElapsedSeconds: p.Elapsed.Seconds(),
+		}
+	}
+}
+
+// finish records the outcome of a job. A job deleted while it ran is not
+// brought back.
+func (st *jobStore) finish(id, format string, stats *customerimporter.Stats, failure *apiError) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.cancel, id)
+	j, ok := st.jobs[id]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	j.FinishedAt, j.Format, j.Stats, j.Error = &now, format, stats, failure
+	j.State = JobSucceeded
+	if failure != nil {
+		j.State = JobFailed
+	}
+	if err := st.save(j); err != nil {
+		slog.Error("failed saving job", "job", id, "error", err)
+	}
+}
+
+// get returns a copy of a job.
+func (st *jobStore) get(id string) (job, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	j, ok := st.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// remove deletes a job and its files, stopping it first if it is running. A
+// queued job gives its queue place back at once.
+func (st *jobStore) remove(id string) bool {
+	st.mu.Lock()
+	_, ok := st.jobs[id]
+	delete(st.jobs, id)
+	st.queue = slices.DeleteFunc(st.queue, func(q string) bool { return q == id })
+	cancel := st.cancel[id]
+	delete(st.cancel, id)
+	st.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if cancel != nil {
+		cancel()
+	}
+	if err := os.RemoveAll(filepath.Join(st.dir, id)); err != nil {
+		slog.Error("failed removing job", "job", id, "error", err)
+	}
+	return true
+}
+
+// expire removes the jobs that finished before cutoff.
+func (st *jobStore) expire(cutoff time.Time) {
+	st.mu.Lock()
+	var old []string
+	for id, j := range st.jobs {
+		if j.FinishedAt != nil && j.FinishedAt.Before(cutoff) {
+			old = append(old, id)
+		}
+	}
+	st.mu.Unlock()
+	for _, id := range old {
+		if st.remove(id) {
+			slog.Info("job expired", "job", id)
+		}
+	}
+}
+
+// save writes j's state file through a temporary file and a rename, so a
+// crash leaves either the old or the new state. The caller holds st.mu.
+func (st *jobStore) save(j *job) error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("encode job: %w", err)
+	}
+	path := st.path(j.ID, jobFile)
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+jobFile+".*")
+	if err != nil {
+		return fmt.Errorf("save job: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save job: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save job: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("save job: %w", err)
+	}
+	return nil
+}
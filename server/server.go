@@ -0,0 +1,175 @@
+// Package server counts uploads over HTTP. POST /count reads a customer file
+// from the request body and answers with the per-domain counts; POST /jobs
+// does the same in the background for uploads too large to wait for, with
+// GET /jobs/{id} reporting progress. GET /healthz reports that the server is
+// up and, when enabled, GET /metrics exposes Prometheus metrics and GET / a
+// web UI for uploading a file and viewing the counts.
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/metrics"
+)
+
+// Defaults for Config fields left zero.
+const (
+	DefaultMaxBodyBytes  = 1 << 30
+	DefaultMaxConcurrent = 4
+)
+
+type Config struct {
+	// Import is the importer configuration every request starts from; query
+	// parameters and multipart fields override it per request. Path and
+	// StatePath are ignored.
+	Import customerimporter.Config
+	// MaxBadRows and MaxBadRatio reject a result with too many bad rows with
+	// 422; see customerimporter.CheckBadRows. A negative limit disables it.
+	MaxBadRows  int
+	MaxBadRatio float64
+	// MaxBodyBytes caps the size of a request body; larger uploads get 413.
+	// Zero means DefaultMaxBodyBytes, a negative value no limit.
+	MaxBodyBytes int64
+	// MaxConcurrent caps the imports running at once; requests beyond it get
+	// 503 straight away instead of queueing. Zero means DefaultMaxConcurrent.
+	MaxConcurrent int
+	// Version is reported by /healthz.
+	Version string
+	// Metrics enables GET /metrics and records every count and job in it.
+	Metrics *metrics.Registry
+	// UI serves the web UI at GET /.
+	UI bool
+
+	// JobsDir enables the job API (POST /jobs) and keeps the uploads, state
+	// and results of jobs, one subdirectory each, so they survive a restart.
+	JobsDir string
+	// JobQueue caps the jobs waiting to run; further submissions get 503.
+	// Zero means DefaultJobQueue.
+	JobQueue int
+	// JobWorkers is how many jobs run at once. Zero means one.
+	JobWorkers int
+	// JobRetention is how long a finished job and its result are kept.
+	// Zero means DefaultJobRetention.
+	JobRetention time.Duration
+}
+
+// Server is an http.Handler serving the count API. With Config.JobsDir,
+// Start must be called to run the submitted jobs.
+type Server struct {
+	cfg   Config
+	mux   *http.ServeMux
+	slots chan struct{}
+
+	jobs *jobStore
+	stop func()
+	wg   sync.WaitGroup
+}
+
+func New(cfg Config) *Server {
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = DefaultMaxConcurrent
+	}
+	if cfg.JobQueue <= 0 {
+		cfg.JobQueue = DefaultJobQueue
+	}
+	if cfg.JobWorkers <= 0 {
+		cfg.JobWorkers = 1
+	}
+	if cfg.JobRetention <= 0 {
+		cfg.JobRetention = DefaultJobRetention
+	}
+	s := &Server{
+		cfg:   cfg,
+		mux:   http.NewServeMux(),
+		slots: make(chan struct{}, cfg.MaxConcurrent),
+	}
+	s.mux.HandleFunc("POST /count", s.handleCount)
+	s.mux.HandleFunc("/count", methodNotAllowed(http.MethodPost))
+	s.mux.HandleFunc("POST /jobs", s.handleSubmit)
+	s.mux.HandleFunc("/jobs", methodNotAllowed(http.MethodPost))
+	s.mux.HandleFunc("GET /jobs/{id}", s.handleJob)
+	s.mux.HandleFunc("DELETE /jobs/{id}", s.handleDelete)
+	s.mux.HandleFunc("/jobs/{id}", methodNotAllowed(http.MethodGet, http.MethodHead, http.MethodDelete))
+	s.mux.HandleFunc("GET /jobs/{id}/result", s.handleResult)
+	s.mux.HandleFunc("/jobs/{id}/result", methodNotAllowed(http.MethodGet, http.MethodHead))
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("/healthz", methodNotAllowed(http.MethodGet, http.MethodHead))
+	if cfg.Metrics != nil {
+		s.mux.Handle("GET /metrics", cfg.Metrics)
+		s.mux.HandleFunc("/metrics", methodNotAllowed(http.MethodGet, http.MethodHead))
+	}
+	if cfg.UI {
+		s.mux.HandleFunc("GET /{$}", s.handleUI)
+	}
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, &apiError{Status: http.StatusNotFound, Code: "not_found", Message: "no such endpoint: " + r.URL.Path})
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// acquire takes an import slot without waiting; release gives it back.
+func (s *Server) acquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Server) release() { <-s.slots }
+
+// observe records a finished import in the metrics, if enabled; err is the
+// error that failed it.
+func (s *Server) observe(res customerimporter.Result, export time.Duration, err error) {
+	switch {
+	case s.cfg.Metrics == nil:
+	case err != nil:
+		s.cfg.Metrics.ObserveFailure()
+	default:
+		s.cfg.Metrics.Observe(res, export)
+	}
+}
+
+type health struct {
+	Status        string `json:"status"`
+	Version       string `json:"version,omitempty"`
+	ActiveImports int    `json:"active_imports"`
+	MaxConcurrent int    `json:"max_concurrent"`
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, health{
+		Status:        "ok",
+		Version:       s.cfg.Version,
+		ActiveImports: len(s.slots),
+		MaxConcurrent: cap(s.slots),
+	})
+}
+
+func methodNotAllowed(allowed ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, m := range allowed {
+			w.Header().Add("Allow", m)
+		}
+		writeError(w, &apiError{Status: http.StatusMethodNotAllowed, Code: "method_not_allowed", Message: r.Method + " is not allowed on " + r.URL.Path})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
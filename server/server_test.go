@@ -0,0 +1,129 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/daveteshome/email-domain-counter/customerimporter"
+	"github.com/daveteshome/email-domain-counter/metrics"
+)
+
+// errorBody decodes an error response and checks its status and code.
+func errorBody(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) apiError {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, status, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+	var body struct {
+		Error apiError `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error body %q: %v", rec.Body, err)
+	}
+	if body.Error.Code != code || body.Error.Message == "" {
+		t.Fatalf("error = %+v, want code %q with a message", body.Error, code)
+	}
+	return body.Error
+}
+
+func TestServer_Health(t *testing.T) {
+	s := New(Config{Version: "v1.2.3", MaxConcurrent: 2})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var got health
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (health{Status: "ok", Version: "v1.2.3", MaxConcurrent: 2}); got != want {
+		t.Fatalf("health = %+v, want %+v", got, want)
+	}
+}
+
+func TestServer_Routing(t *testing.T) {
+	tests := []struct {
+		method, path string
+		status       int
+		code         string
+		allow        string
+	}{
+		{http.MethodGet, "/count", http.StatusMethodNotAllowed, "method_not_allowed", "POST"},
+		{http.MethodPost, "/healthz", http.StatusMethodNotAllowed, "method_not_allowed", "GET"},
+		{http.MethodGet, "/nope", http.StatusNotFound, "not_found", ""},
+	}
+	s := New(Config{})
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		errorBody(t, rec, tt.status, tt.code)
+		if got := rec.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, got, tt.allow)
+		}
+	}
+}
+
+func TestServer_Limits(t *testing.T) {
+	s := New(Config{Import: customerimporter.Config{EmailHeader: "email"}, MaxBodyBytes: 16, MaxConcurrent: 1})
+	body := "email\na@x.com\nb@y.com\n"
+
+	// Declared too large: rejected before reading.
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/count", strings.NewReader(body)))
+	errorBody(t, rec, http.StatusRequestEntityTooLarge, "request_too_large")
+
+	// Streamed without a length: cut off while importing.
+	req := httptest.NewRequest(http.MethodPost, "/count", strings.NewReader(body))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	errorBody(t, rec, http.StatusRequestEntityTooLarge, "request_too_large")
+
+	// Every slot taken: turned away instead of queued.
+	s.slots <- struct{}{}
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/count", strings.NewReader("email\n")))
+	errorBody(t, rec, http.StatusServiceUnavailable, "busy")
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("busy response without Retry-After")
+	}
+	s.release()
+}
+
+func TestServer_Metrics(t *testing.T) {
+	errorBody(t, do(New(Config{}), http.MethodGet, "/metrics", ""), http.StatusNotFound, "not_found")
+
+	reg := metrics.NewRegistry(1)
+	s := newJobServer(t, t.TempDir(), Config{Metrics: reg})
+	if rec := do(s, http.MethodPost, "/count", customersCSV); rec.Code != http.StatusOK {
+		t.Fatalf("count = %d: %s", rec.Code, rec.Body)
+	}
+	do(s, http.MethodPost, "/count", "id,mail\n1,a@x.com\n")
+	waitJob(t, s, submit(t, s, "/jobs", customersCSV).ID)
+
+	rec := do(s, http.MethodGet, "/metrics", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != metrics.ContentType {
+		t.Fatalf("GET /metrics = %d %v", rec.Code, rec.Header())
+	}
+	for _, line := range []string{
+		`edc_runs_total{outcome="success"} 2`,
+		`edc_runs_total{outcome="failure"} 1`,
+		"edc_rows_total 8",
+		`edc_bad_rows_total{reason="invalid_address"} 2`,
+		"edc_bytes_read_total " + strconv.Itoa(2*len(customersCSV)),
+		`edc_domain_customers{domain="x.com"} 2`,
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, rec.Body)
+		}
+	}
+	errorBody(t, do(s, http.MethodPost, "/metrics", ""), http.StatusMethodNotAllowed, "method_not_allowed")
+}
@@ -0,0 +1,18 @@
+package server
+
+import (
+	"embed"
+	"net/http"
+)
+
+// uiFiles holds the single-page web UI. It posts the chosen file and options
+// to /count as a multipart form and draws the JSON result in the browser.
+//
+//go:embed ui/index.html
+var uiFiles embed.FS
+
+func (s *Server) handleUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; img-src 'self' blob:")
+	http.ServeFileFS(w, r, uiFiles, "ui/index.html")
+}
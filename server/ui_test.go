@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestUI(t *testing.T) {
+	errorBody(t, do(New(Config{}), http.MethodGet, "/", ""), http.StatusNotFound, "not_found")
+
+	s := New(Config{UI: true})
+	rec := do(s, http.MethodGet, "/", "")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("GET / = %d %v", rec.Code, rec.Header())
+	}
+	page := rec.Body.String()
+	if !strings.Contains(page, `fetch("count"`) {
+		t.Fatal("page does not post to /count")
+	}
+	errorBody(t, do(s, http.MethodGet, "/index.html", ""), http.StatusNotFound, "not_found")
+
+	// Every option field of the form must be one /count accepts.
+	form := page[strings.Index(page, "<form"):strings.Index(page, "</form>")]
+	names := regexp.MustCompile(`name="([^"]+)"`).FindAllStringSubmatch(form, -1)
+	values := regexp.MustCompile(`<option value="(\w+)">`).FindAllStringSubmatch(form, -1)
+	if len(names) == 0 || len(values) == 0 {
+		t.Fatal("no option fields found")
+	}
+	for _, m := range append(names, values...) {
+		params := url.Values{m[1]: {"1"}}
+		if _, err := s.importConfig(params, "in.csv", ""); err != nil && strings.Contains(err.Error(), "unknown option") {
+			t.Errorf("form field %q: %v", m[1], err)
+		}
+	}
+}